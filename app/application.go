@@ -2,7 +2,8 @@ package app
 
 import (
 	"context"
-	"csgo-parser-mongodb/util/elias"
+	"csgo-parser-mongodb/overview"
+	"csgo-parser-mongodb/zones"
 	"fmt"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -11,6 +12,8 @@ import (
 	"reflect"
 	"time"
 
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
 	dem "github.com/markus-wa/demoinfocs-golang"
 	"github.com/markus-wa/demoinfocs-golang/common"
 	"github.com/markus-wa/demoinfocs-golang/events"
@@ -43,9 +46,29 @@ type Application struct {
 	equipmentElements             map[int64]EquipmentElementStaticInfo
 	playersLoaded                 bool
 	saveGameStateFrameDenominator int
-	savePositionsFrameDenominator int
-	frameRate                     int
-	originalFramerate             int
+	// positions are saved frameRate times a second of game time, as clock tells
+	clock                         sampleClock
+	useTicks                      bool
+
+	// game states are stored in full every gameStateKeyframes snapshots and as diffs in between,
+	// always in full if it is 0
+	gameStateKeyframes      int
+	gameStatesSinceKeyframe int
+	lastGameState           *GameStateInfo
+
+	frameRate                     float64
+	originalFramerate             float64
+
+	// overviews of the maps by name, the one of the parsed map is stored into the header
+	overviews   overview.Registry
+	mapOverview *overview.Map
+	// whether positions are stored with their position on the radar too
+	radar bool
+	// zones of the maps by name, the ones of the parsed map name the places of events
+	zoneRegistry zones.Registry
+	mapZones     *zones.Map
+	// whether positions of players are stored with the name of their place too
+	callouts bool
 
 	implicitlyProcessedEvents map[EvType]bool
 
@@ -53,8 +76,22 @@ type Application struct {
 	savePositionsAsDeltas bool
 	playersLastPositions  map[int64]PlayerMovementInfo
 	eliasEncodeDeltas     bool
+	codecs                StreamCodecs
+	positionPrecision     int
+	sampling              AdaptiveSampling
+
+	// burning infernos and the molotovs and incendiaries that detonated without being matched to one yet
+	infernoTrackers map[int64]*infernoTracker
+	fireGrenades    []fireGrenade
+	// smokes up, by the entity ID of their grenade
+	activeSmokes map[int]*SmokeLifecycle
+	// seconds a tick takes, 0 if the header doesn't tell
+	tickTime float64
+	// ingame tick team metrics were last stored at, -1 before the first
+	lastTeamMetricsTick int
 
 	grenadesPositionsEncoded  []GrenadePositionInfoEncoded
+	grenadesPositionsInFlight map[int64]*GrenadeMovement
 	playersPositionsInRound   map[int64]*PlayerMovement // had to store pointers because go doesn't allow struct mutation when stored in a map
 	roundNumber               int
 	playerMovementEncodedData RoundMovement
@@ -100,8 +137,29 @@ const (
 	ClInfernos
 	ClProjectiles
 	ClReplays
+	ClInfernoLifecycles
+	ClSmokes
+	ClTeamMetrics
+	ClRoundSummaries
 )
 
+// DefaultCollectionNames are the names the parser stores its collections under.
+var DefaultCollectionNames = map[ClIndex]string {
+	ClEntities:		"entities",
+	ClEvents:		"events",
+	ClPlayers:		"players",
+	ClPositions:	"players_positions",
+	ClProjectiles:	"grenades_positions",
+	ClInfernos:		"current_infernos",
+	ClHeader:		"header",
+	ClGameState:	"game_states",
+	ClReplays:		"replays",
+	ClInfernoLifecycles:	"inferno_lifecycles",
+	ClSmokes:				"smokes",
+	ClTeamMetrics:			"team_metrics",
+	ClRoundSummaries:		"round_summaries",
+}
+
 const MAX_ROUNDS = 30
 
 func NewApplication(
@@ -110,8 +168,16 @@ func NewApplication(
 	dbName string,
 	collectionNames map[ClIndex]string,
 	eliasEncoding bool,
+	codecs StreamCodecs,
+	positionPrecision int,
+	sampling AdaptiveSampling,
 	gameStateFreq int,
-	frameRate int) Application {
+	gameStateKeyframes int,
+	frameRate float64,
+	overviews overview.Registry,
+	radar bool,
+	zoneRegistry zones.Registry,
+	callouts bool) Application {
 	return Application {
 		reader:							reader,
 		client:							client,
@@ -119,8 +185,16 @@ func NewApplication(
 		collectionNames:				collectionNames,
 		savePositionsAsDeltas:        	false, // doesn't help at all
 		saveGameStateFrameDenominator:	gameStateFreq,
+		gameStateKeyframes:				gameStateKeyframes,
 		frameRate:                    	frameRate,
 		eliasEncodeDeltas:				eliasEncoding,
+		codecs:							codecs,
+		positionPrecision:				positionPrecision,
+		sampling:						sampling,
+		overviews:						overviews,
+		radar:							radar,
+		zoneRegistry:					zoneRegistry,
+		callouts:						callouts,
 	}
 }
 
@@ -142,11 +216,19 @@ func (app *Application) Init() {
 	app.collections[ClEntities] = app.client.Database(app.dbName).Collection(app.collectionNames[ClEntities])
 	app.collections[ClGameState] = app.client.Database(app.dbName).Collection(app.collectionNames[ClGameState])
 	app.collections[ClReplays] = app.client.Database("meta_info").Collection(app.collectionNames[ClReplays])
+	app.collections[ClInfernoLifecycles] = app.client.Database(app.dbName).Collection(app.collectionNames[ClInfernoLifecycles])
+	app.collections[ClSmokes] = app.client.Database(app.dbName).Collection(app.collectionNames[ClSmokes])
+	app.collections[ClTeamMetrics] = app.client.Database(app.dbName).Collection(app.collectionNames[ClTeamMetrics])
+	app.collections[ClRoundSummaries] = app.client.Database(app.dbName).Collection(app.collectionNames[ClRoundSummaries])
 
 	app.collectionsForBulkInserting = []ClIndex {
 		ClEvents,
 		ClPositions,
 		ClInfernos,
+		ClInfernoLifecycles,
+		ClSmokes,
+		ClTeamMetrics,
+		ClRoundSummaries,
 		ClProjectiles,
 		ClPlayers,
 		ClEntities,
@@ -156,6 +238,10 @@ func (app *Application) Init() {
 		ClEvents,
 		ClPositions,
 		ClInfernos,
+		ClInfernoLifecycles,
+		ClSmokes,
+		ClTeamMetrics,
+		ClRoundSummaries,
 		ClProjectiles,
 		ClGameState,
 	}
@@ -166,6 +252,10 @@ func (app *Application) Init() {
 	app.playersLoaded = false
 	app.playersLastPositions = make(map[int64]PlayerMovementInfo)
 	app.playersPositionsInRound = make(map[int64]*PlayerMovement)
+	app.grenadesPositionsInFlight = make(map[int64]*GrenadeMovement)
+	app.infernoTrackers = make(map[int64]*infernoTracker)
+	app.activeSmokes = make(map[int]*SmokeLifecycle)
+	app.lastTeamMetricsTick = -1
 	app.playerMovementEncodedData = RoundMovement{
 		0,
 		make([]PlayerMovementInfoEncoded, 0, 20),
@@ -218,6 +308,10 @@ func (app *Application) Init() {
 		RoundMVPAnnouncement: true,
 
 		TeamSideSwitch: true,
+
+		RoundStart:       true,
+		RoundEnd:         true,
+		RoundEndOfficial: true,
 	}
 
 	app.gameStarted = false
@@ -449,11 +543,7 @@ func (app *Application) manualHandlerRegistering() {
 	})
 
 	app.parser.RegisterEventHandler(func(e events.GameHalfEnded) {
-		var data = EventInfo{
-			app.savedFrameNumber,
-			GameHalfEnded,
-			app.getMap(e),
-		}
+		var data = app.newEventInfo(GameHalfEnded, app.getMap(e))
 
 		model := mongo.NewInsertOneModel().SetDocument(data)
 		app.bulkInserts[ClEvents] = append(app.bulkInserts[ClEvents], model)
@@ -477,26 +567,21 @@ func (app *Application) manualHandlerRegistering() {
 		//	}
 		//}
 		fmt.Printf("%d\n", app.roundNumber)
+		// a summary per round, which commands set their fields on later
+		if !app.roundEnded {
+			app.roundEnded = true
+			model := mongo.NewInsertOneModel().SetDocument(RoundSummary{RoundNumber: app.roundNumber})
+			app.bulkInserts[ClRoundSummaries] = append(app.bulkInserts[ClRoundSummaries], model)
+		}
 	})
 
 	app.parser.RegisterEventHandler(func(e events.RoundStart){
+		// detonations that started no fire, e.g. in water
+		app.fireGrenades = nil
+		// smokes are gone with the round they were thrown in
+		app.saveActiveSmokes()
 		if app.eliasEncodeDeltas {
-			app.playerMovementEncodedData.RoundNumber = app.roundNumber
-
-			for k, v := range app.playersPositionsInRound {
-				if v.StartFrame == 0 {
-					continue // no movement
-				}
-				PMIE := app.encodePlayerMovement(k, v, true)
-				app.playerMovementEncodedData.PlayerMovements = append(app.playerMovementEncodedData.PlayerMovements, PMIE)
-			}
-
-			if len(app.playerMovementEncodedData.PlayerMovements) > 0 {
-				model := mongo.NewInsertOneModel().SetDocument(app.playerMovementEncodedData)
-				app.bulkInserts[ClPositions] = append(app.bulkInserts[ClPositions], model)
-			}
-
-			app.playerMovementEncodedData.PlayerMovements = app.playerMovementEncodedData.PlayerMovements[:0]
+			app.flushRoundMovement()
 		}
 		app.saveDataToMongo()
 		app.roundNumber++
@@ -518,24 +603,11 @@ func (app *Application) manualHandlerRegistering() {
 	if app.eliasEncodeDeltas {
 
 		app.parser.RegisterEventHandler(func(e events.GrenadeProjectileDestroy) {
-			X := make([]int, len(e.Projectile.Trajectory))
-			Y := make([]int, len(e.Projectile.Trajectory))
-			Z := make([]int, len(e.Projectile.Trajectory))
-			for i, v := range e.Projectile.Trajectory {
-				X[i] = int(v.X)
-				Y[i] = int(v.Y)
-				Z[i] = int(v.Z)
-			}
-			data := GrenadePositionInfoEncoded {
-				app.currentProjectiles[e.Projectile.UniqueID()].StartFrame,
-				app.savedFrameNumber,
-				e.Projectile.UniqueID(),
-				elias.EliasGammaNegative(elias.ArrayToDeltas(X)...),
-				elias.EliasGammaNegative(elias.ArrayToDeltas(Y)...),
-				elias.EliasGammaNegative(elias.ArrayToDeltas(Z)...),
+			if GM, ok := app.grenadesPositionsInFlight[e.Projectile.UniqueID()]; ok {
+				model := mongo.NewInsertOneModel().SetDocument(NewGrenadePositionInfoEncoded(e.Projectile.UniqueID(), GM, app.codecs))
+				app.bulkInserts[ClProjectiles] = append(app.bulkInserts[ClProjectiles], model)
+				delete(app.grenadesPositionsInFlight, e.Projectile.UniqueID())
 			}
-			model := mongo.NewInsertOneModel().SetDocument(data)
-			app.bulkInserts[ClProjectiles] = append(app.bulkInserts[ClProjectiles], model)
 		})
 
 		app.parser.RegisterEventHandler(func(e events.PlayerDisconnected) {
@@ -555,12 +627,99 @@ func (app *Application) manualHandlerRegistering() {
 		})
 	}
 
-	app.parser.RegisterEventHandler(func(e events.RankUpdate) {
-		var data = EventInfo{
-			app.savedFrameNumber,
-			RankUpdate,
-			app.getMap(e),
+	app.parser.RegisterEventHandler(func(e events.FireGrenadeStart) {
+		if app.parser.GameState().IsWarmupPeriod() {
+			return
+		}
+		thrower := int64(-1)
+		if e.Thrower != nil {
+			thrower = e.Thrower.SteamID
 		}
+		app.fireGrenades = append(app.fireGrenades, fireGrenade{thrower, e.GrenadeType, e.Position})
+	})
+
+	app.parser.RegisterEventHandler(func(e events.InfernoStart) {
+		if app.parser.GameState().IsWarmupPeriod() {
+			return
+		}
+		app.infernoTrackers[e.Inferno.UniqueID()] = newInfernoTracker(e.Inferno.UniqueID(), app.roundNumber, app.savedFrameNumber, app.parser.GameState().IngameTick())
+	})
+
+	app.parser.RegisterEventHandler(func(e events.InfernoExpired) {
+		t, ok := app.infernoTrackers[e.Inferno.UniqueID()]
+		if !ok {
+			return
+		}
+		t.finish(app.savedFrameNumber, app.parser.GameState().IngameTick(), app.tickTime)
+		app.fireGrenades = t.match(app.fireGrenades)
+		app.saveInfernoLifecycle(t)
+		delete(app.infernoTrackers, e.Inferno.UniqueID())
+	})
+
+	app.parser.RegisterEventHandler(func(e events.PlayerHurt) {
+		if e.Attacker == nil || len(app.infernoTrackers) == 0 {
+			return
+		}
+		weapon := common.MapEquipment(e.WeaponString)
+		if e.Weapon != nil {
+			weapon = e.Weapon.Weapon
+		}
+		if weapon != common.EqMolotov && weapon != common.EqIncendiary {
+			return
+		}
+		// the nearest fire of the attacker, or the nearest fire if none was matched to them
+		better := func(t, than *infernoTracker) bool {
+			if own := t.lifecycle.Thrower == e.Attacker.SteamID; own != (than.lifecycle.Thrower == e.Attacker.SteamID) {
+				return own
+			}
+			return t.lastCentroid.Sub(e.Player.Position).Norm() < than.lastCentroid.Sub(e.Player.Position).Norm()
+		}
+		var nearest *infernoTracker
+		for _, t := range app.infernoTrackers {
+			if nearest == nil || better(t, nearest) {
+				nearest = t
+			}
+		}
+		nearest.hurt(e.Player.SteamID, e.HealthDamage)
+	})
+
+	app.parser.RegisterEventHandler(func(e events.SmokeStart) {
+		if app.parser.GameState().IsWarmupPeriod() {
+			return
+		}
+		S := &SmokeLifecycle{
+			Projectile:		-1,
+			RoundNumber:	app.roundNumber,
+			StartFrame:		app.savedFrameNumber,
+			StartTick:		app.parser.GameState().IngameTick(),
+			ExpireFrame:	-1,
+			ExpireTick:		-1,
+			Thrower:		-1,
+			Position:		app.position(e.Position),
+			Callout:		app.callout(e.Position),
+		}
+		if GP, ok := app.parser.GameState().GrenadeProjectiles()[e.GrenadeEntityID]; ok {
+			S.Projectile = GP.UniqueID()
+		}
+		if e.Thrower != nil {
+			S.Thrower = e.Thrower.SteamID
+		}
+		app.activeSmokes[e.GrenadeEntityID] = S
+	})
+
+	app.parser.RegisterEventHandler(func(e events.SmokeExpired) {
+		S, ok := app.activeSmokes[e.GrenadeEntityID]
+		if !ok {
+			return
+		}
+		S.ExpireFrame, S.ExpireTick = app.savedFrameNumber, app.parser.GameState().IngameTick()
+		model := mongo.NewInsertOneModel().SetDocument(S)
+		app.bulkInserts[ClSmokes] = append(app.bulkInserts[ClSmokes], model)
+		delete(app.activeSmokes, e.GrenadeEntityID)
+	})
+
+	app.parser.RegisterEventHandler(func(e events.RankUpdate) {
+		var data = app.newEventInfo(RankUpdate, app.getMap(e))
 
 		model := mongo.NewInsertOneModel().SetDocument(data)
 		app.bulkInserts[ClEvents] = append(app.bulkInserts[ClEvents], model)
@@ -576,14 +735,18 @@ func (app *Application) manualHandlerRegistering() {
 
 		var data = struct {
 			FrameNumber int					`bson:"FrameNumber"`
+			Tick        int					`bson:"Tick"`
+			RoundNumber int					`bson:"RoundNumber"`
 			EventType   EvType				`bson:"EventType"`
 			Data        FlashExplodeInfo	`bson:"Data"`
 		}{
 			app.savedFrameNumber,
+			app.parser.GameState().IngameTick(),
+			app.roundNumber,
 			FlashExplode,
 			FlashExplodeInfo{
 				//app.currentProjectiles[e.GrenadeEntityID].UniqueID(), // doesn't matter, it's projectile ID, not item's
-				NewInt16Vector3(e.Position),
+				app.position(e.Position),
 			},
 		}
 
@@ -599,11 +762,14 @@ func (app *Application) manualHandlerRegistering() {
 			return
 		}
 
-		var data = EventInfo {
-			app.savedFrameNumber,
-			Kill,
-			app.getMap(e),
+		var data = app.newEventInfo(Kill, app.getMap(e))
+		// where the players were at the very tick of the kill, so positions can be interpolated up to it
+		data.Data["VictimMovement"] = app.movementInfo(e.Victim)
+		if e.Killer != nil {
+			data.Data["KillerMovement"] = app.movementInfo(e.Killer)
 		}
+		app.tagCallouts(data.Data, map[string]*common.Player{"Victim": e.Victim, "Killer": e.Killer})
+		data.Data["ThroughSmoke"] = e.Killer != nil && app.throughSmoke(e.Killer.Position, e.Victim.Position)
 
 		if app.eliasEncodeDeltas {
 			if PM, ok := app.playersPositionsInRound[e.Victim.SteamID]; ok && PM.EndFrame == 0 {
@@ -624,10 +790,14 @@ func (app *Application) manualHandlerRegistering() {
 
 		var data = struct {
 			FrameNumber int					`bson:"FrameNumber"`
+			Tick        int					`bson:"Tick"`
+			RoundNumber int					`bson:"RoundNumber"`
 			EventType   EvType				`bson:"EventType"`
 			Data        PlayerFlashedInfo	`bson:"Data"`
 		}{
 			app.savedFrameNumber,
+			app.parser.GameState().IngameTick(),
+			app.roundNumber,
 			PlayerFlashed,
 			PlayerFlashedInfo{
 				e.Attacker.SteamID,
@@ -649,28 +819,58 @@ func (app *Application) Parse() {
 	header, err := app.parser.ParseHeader()
 	checkError(err)
 	headerMap := app.getMap(header)
+	headerMap["SchemaVersion"] = SchemaVersion
+	headerMap["ParserVersion"] = ParserVersion
+	headerMap["PositionPrecision"] = app.positionPrecision
+	headerMap["ViewPrecision"] = app.codecs.ViewPrecision
+	if header.PlaybackTicks > 0 {
+		app.tickTime = header.PlaybackTime.Seconds() / float64(header.PlaybackTicks)
+	}
+	if OV, ok := app.overviews.Map(header.MapName); ok {
+		headerMap["Overview"] = OV
+		app.mapOverview = &OV
+	} else if app.radar {
+		fmt.Printf("No overview of %s, positions are stored without their positions on the radar.\n", header.MapName)
+	}
+	if Z, ok := app.zoneRegistry.Map(header.MapName); ok {
+		app.mapZones = &Z
+	} else if app.callouts {
+		fmt.Printf("No zones of %s, events and positions are stored without callouts.\n", header.MapName)
+	}
 	fmt.Println("Header:", headerMap)
-	app.originalFramerate = int(math.Round(float64(header.PlaybackFrames) / header.PlaybackTime.Seconds() / 16) * 16)
-	fmt.Printf("Original demo framerate: %d frames per second.\n", app.originalFramerate)
+	app.originalFramerate = header.FrameRate()
+	fmt.Printf("Original demo framerate: %.2f frames per second, tick rate: %.2f ticks per second.\n", app.originalFramerate, header.TickRate())
+	// the frame timing of demos can be irregular, so the positions are sampled by ingame tick, or by frame
+	// if the header doesn't tell the tick rate
+	app.clock, err = newSampleClock(app.frameRate, header.TickRate())
+	app.useTicks = err == nil
+	if !app.useTicks {
+		app.clock, err = newSampleClock(app.frameRate, app.originalFramerate)
+		if err != nil {
+			checkError(fmt.Errorf("demo header has no playback time to sample positions by: %v", err))
+		}
+	}
 	if app.originalFramerate < app.frameRate {
-		app.savePositionsFrameDenominator = 1
-		fmt.Printf("Requested framerate (%d) is greater than original. Setting framerate to %d.\n", app.frameRate, app.originalFramerate)
+		fmt.Printf("Requested framerate (%v) is greater than original. Saving players' and grenades' positions every frame.\n", app.frameRate)
 	} else {
-		app.savePositionsFrameDenominator = app.originalFramerate / app.frameRate
-		fmt.Printf("Saving players' and grenades' positions every %d frame(s).\n", app.savePositionsFrameDenominator)
+		fmt.Printf("Saving players' and grenades' positions %v times per second.\n", app.frameRate)
 	}
-
+	headerMap["SampleRate"] = math.Min(app.frameRate, app.originalFramerate)
 
 	_, err =  app.collections[ClHeader].InsertOne(context.TODO(), headerMap)
 	checkError(err)
 
 	if app.dbName != "test" {
 		_, err =  app.collections[ClReplays].InsertOne(context.TODO(), struct {
-			DBname		string
-			Timestamp	time.Time
+			DBname			string
+			Timestamp		time.Time
+			SchemaVersion	int
+			ParserVersion	string
 		}{
 			app.dbName,
 			time.Now(),
+			SchemaVersion,
+			ParserVersion,
 		})
 		checkError(err)
 	}
@@ -688,14 +888,32 @@ func (app *Application) Parse() {
 		reflectedEvent := reflect.ValueOf(e)
 
 		if evType := EvTypeIndex[reflectedEvent.Type().Name()]; app.implicitlyProcessedEvents[evType] {
-			var data = EventInfo {
-				app.savedFrameNumber,
-				evType,
-				map[string]interface{}{},
+			var data = app.newEventInfo(evType, app.getMap(e))
+			switch e := e.(type) {
+			case events.PlayerHurt:
+				app.tagCallouts(data.Data, map[string]*common.Player{"Player": e.Player, "Attacker": e.Attacker})
+			case events.BombPlantBegin:
+				app.tagBombCallout(data.Data, e.BombEvent)
+			case events.BombPlanted:
+				app.tagBombCallout(data.Data, e.BombEvent)
+			case events.BombDefused:
+				app.tagBombCallout(data.Data, e.BombEvent)
+			case events.GrenadeProjectileThrow:
+				app.tagProjectile(data.Data, e.Projectile)
+				if e.Projectile.Thrower != nil {
+					// where the thrower stood and aimed, for lineups
+					data.Data["ThrowerMovement"] = app.movementInfo(e.Projectile.Thrower)
+					app.tagCallouts(data.Data, map[string]*common.Player{"Thrower": e.Projectile.Thrower})
+				}
+			case events.GrenadeProjectileDestroy:
+				app.tagProjectile(data.Data, e.Projectile)
+				// projectiles are destroyed where they detonated
+				data.Data["Position"] = app.position(e.Projectile.Position)
+				if callout := app.callout(e.Projectile.Position); callout != "" {
+					data.Data["Callout"] = callout
+				}
 			}
 
-			data.Data = app.getMap(e)
-
 			model := mongo.NewInsertOneModel().SetDocument(data)
 			app.bulkInserts[ClEvents] = append(app.bulkInserts[ClEvents], model)
 
@@ -719,8 +937,17 @@ func (app *Application) Parse() {
 		//	break
 		//}
 
+		now := app.parser.CurrentFrame()
+		if app.useTicks {
+			now = app.parser.GameState().IngameTick()
+		}
+		sample := app.clock.due(now)
+		if sample {
+			app.savedFrameNumber++
+		}
+
 		//saving the whole game state
-		if app.parser.CurrentFrame() % (app.saveGameStateFrameDenominator * app.savePositionsFrameDenominator) == 0 {
+		if sample && app.savedFrameNumber % app.saveGameStateFrameDenominator == 0 {
 		//if true {
 			//saving the whole game state
 
@@ -744,22 +971,23 @@ func (app *Application) Parse() {
 
 			var data = GameStateInfo{
 				app.savedFrameNumber,
+				app.parser.GameState().IngameTick(),
+				app.roundNumber,
 				make([]PlayerStateInfo, 0, len(app.parser.GameState().Participants().Playing())),
 			}
 
 			for _, p := range app.parser.GameState().Participants().Playing() {
-				data.Players = append(data.Players, NewPlayerStateInfo(p))
+				PSI, err := NewPlayerStateInfo(p, app.positionPrecision, app.codecs.ViewPrecision)
+				checkError(err)
+				data.Players = append(data.Players, PSI)
 			}
 
-			model := mongo.NewInsertOneModel().SetDocument(data)
-			app.bulkInserts[ClGameState] = append(app.bulkInserts[ClGameState], model)
+			app.saveGameState(data)
 
 			//_, err :=  app.collections[ClGameState].InsertOne(context.TODO(), data)
 			//checkError(err)
 		}
-		if app.parser.CurrentFrame() % app.savePositionsFrameDenominator == 0 {
-
-			app.savedFrameNumber++
+		if sample {
 
 			playersPos := make([]PlayerMovementInfo, 0, len(app.parser.GameState().Participants().Playing()))
 			grenadesPos := make([]GrenadePositionInfo, 0, len(app.parser.GameState().GrenadeProjectiles()))
@@ -772,12 +1000,7 @@ func (app *Application) Parse() {
 						if app.savePositionsAsDeltas {
 							data = app.calculateDelta(v)
 						} else {
-							data = PlayerMovementInfo{
-								v.SteamID,
-								NewInt16Vector3(v.Position),
-								int16(v.ViewDirectionX),
-								int16(v.ViewDirectionY),
-							}
+							data = app.movementInfo(v)
 						}
 						playersPos = append(playersPos, data)
 					} else {
@@ -791,11 +1014,12 @@ func (app *Application) Parse() {
 							// probably reconnected
 							PM.StartFrame = app.savedFrameNumber
 						}
-						PM.PositionX = append(PM.PositionX, int(v.Position.X))
-						PM.PositionY = append(PM.PositionY, int(v.Position.Y))
-						PM.PositionZ = append(PM.PositionZ, int(v.Position.Z))
-						PM.ViewX = append(PM.ViewX, int(v.ViewDirectionX))
-						PM.ViewY = append(PM.ViewY, int(v.ViewDirectionY))
+						app.samplePlayer(PM, movementSample{
+							app.savedFrameNumber,
+							app.position(v.Position),
+							FixedAngle(v.ViewDirectionX, app.codecs.ViewPrecision),
+							FixedAngle(v.ViewDirectionY, app.codecs.ViewPrecision),
+						})
 					}
 				}
 			}
@@ -804,18 +1028,35 @@ func (app *Application) Parse() {
 				for _, v := range app.parser.GameState().GrenadeProjectiles() {
 					grenadesPos = append(grenadesPos, GrenadePositionInfo{
 						v.UniqueID(),
-						NewInt16Vector3(v.Position),
+						app.position(v.Position),
+						app.radarPoint(v.Position),
 					})
 				}
+			} else {
+				for _, v := range app.parser.GameState().GrenadeProjectiles() {
+					GM, ok := app.grenadesPositionsInFlight[v.UniqueID()]
+					if !ok {
+						GM = &GrenadeMovement{StartFrame: app.savedFrameNumber}
+						app.grenadesPositionsInFlight[v.UniqueID()] = GM
+					}
+					position := app.position(v.Position)
+					GM.PositionX = append(GM.PositionX, int(position.X))
+					GM.PositionY = append(GM.PositionY, int(position.Y))
+					GM.PositionZ = append(GM.PositionZ, int(position.Z))
+				}
 			}
 
 			for _, v := range app.parser.GameState().Infernos() {
+				hull := v.Active().ConvexHull2D()
 				currentInfernos = append(currentInfernos, InfernoInfo{
 					v.UniqueID(),
-					v.Active().ConvexHull2D(),
+					hull,
 				})
+				app.sampleInferno(v, hull)
 			}
 
+			app.sampleTeamMetrics()
+
 			if !app.eliasEncodeDeltas {
 				if len(playersPos) > 0 {
 					data := FramePositions{
@@ -863,6 +1104,24 @@ func (app *Application) Parse() {
 
 	fmt.Println("Parsing ended")
 
+	if app.eliasEncodeDeltas {
+		// the last round has no RoundStart after it, and some grenades may have never been destroyed
+		app.flushRoundMovement()
+		for k, v := range app.grenadesPositionsInFlight {
+			model := mongo.NewInsertOneModel().SetDocument(NewGrenadePositionInfoEncoded(k, v, app.codecs))
+			app.bulkInserts[ClProjectiles] = append(app.bulkInserts[ClProjectiles], model)
+		}
+		app.saveDataToMongo()
+	}
+
+	// fires still burning when the demo ended
+	for _, t := range app.infernoTrackers {
+		t.finish(-1, -1, app.tickTime)
+		app.saveInfernoLifecycle(t)
+	}
+	app.infernoTrackers = make(map[int64]*infernoTracker)
+	app.saveActiveSmokes()
+
 	for _, v := range app.equipmentElements {
 
 		model := mongo.NewInsertOneModel().SetDocument(v)
@@ -885,6 +1144,187 @@ func (app *Application) Parse() {
 
 }
 
+func (app *Application) newEventInfo(evType EvType, data map[string]interface{}) EventInfo {
+	return EventInfo{
+		app.savedFrameNumber,
+		app.parser.GameState().IngameTick(),
+		app.roundNumber,
+		evType,
+		data,
+	}
+}
+
+// saveGameState stores a snapshot in full or, between keyframes, as the changes since the snapshot before.
+func (app *Application) saveGameState(GSI GameStateInfo) {
+	var doc interface{} = GSI
+	if app.gameStateKeyframes > 0 && app.lastGameState != nil && app.gameStatesSinceKeyframe+1 < app.gameStateKeyframes {
+		diff, ok, err := DiffGameStates(*app.lastGameState, GSI)
+		checkError(err)
+		if ok {
+			doc = diff
+			app.gameStatesSinceKeyframe++
+		} else {
+			app.gameStatesSinceKeyframe = 0
+		}
+	} else {
+		app.gameStatesSinceKeyframe = 0
+	}
+	app.lastGameState = &GSI
+
+	model := mongo.NewInsertOneModel().SetDocument(doc)
+	app.bulkInserts[ClGameState] = append(app.bulkInserts[ClGameState], model)
+}
+
+// position converts a position to fixed point with the precision positions are stored with.
+// A coordinate that doesn't fit means the demo is broken, so parsing stops.
+func (app *Application) position(v r3.Vector) FixedVector3 {
+	fv, err := NewFixedVector3(v, app.positionPrecision)
+	checkError(err)
+	return fv
+}
+
+// movementInfo returns where the player is and looks at, as stored in positions.
+func (app *Application) movementInfo(player *common.Player) PlayerMovementInfo {
+	return PlayerMovementInfo{
+		player.SteamID,
+		app.position(player.Position),
+		app.viewAngle(player.ViewDirectionX),
+		app.viewAngle(player.ViewDirectionY),
+		app.radarPoint(player.Position),
+		app.positionCallout(player.Position),
+	}
+}
+
+// positionCallout returns the place a position is in if places of positions are stored, "" otherwise.
+func (app *Application) positionCallout(v r3.Vector) string {
+	if !app.callouts {
+		return ""
+	}
+	return app.callout(v)
+}
+
+// callout returns the name of the place of the map a position is in, "" if the map has no zone there.
+func (app *Application) callout(v r3.Vector) string {
+	if app.mapZones == nil {
+		return ""
+	}
+	callout, _ := app.mapZones.Callout(v)
+	return callout
+}
+
+// sampleInferno adds the hull a burning inferno covers to its lifecycle.
+// Infernos that started before parsing did, e.g. in the warmup, start at their first sample.
+func (app *Application) sampleInferno(inf *common.Inferno, hull []r2.Point) {
+	t, ok := app.infernoTrackers[inf.UniqueID()]
+	if !ok {
+		t = newInfernoTracker(inf.UniqueID(), app.roundNumber, app.savedFrameNumber, app.parser.GameState().IngameTick())
+		app.infernoTrackers[inf.UniqueID()] = t
+	}
+	var z float64
+	fires := inf.Active().Fires
+	for _, f := range fires {
+		z += f.Z / float64(len(fires))
+	}
+	t.sample(app.parser.GameState().IngameTick(), hull, z, app.tickTime)
+	app.fireGrenades = t.match(app.fireGrenades)
+}
+
+// saveInfernoLifecycle stores the lifecycle of an inferno that went out.
+func (app *Application) saveInfernoLifecycle(t *infernoTracker) {
+	centroid := t.centroid()
+	t.lifecycle.Centroid = app.position(centroid)
+	t.lifecycle.Callout = app.callout(centroid)
+	model := mongo.NewInsertOneModel().SetDocument(t.lifecycle)
+	app.bulkInserts[ClInfernoLifecycles] = append(app.bulkInserts[ClInfernoLifecycles], model)
+}
+
+// throughSmoke tells whether a smoke that is up hides players at the two positions from each other.
+func (app *Application) throughSmoke(from, to r3.Vector) bool {
+	for _, S := range app.activeSmokes {
+		if SmokeBlocks(S.Position.Vector(app.positionPrecision), from, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// saveActiveSmokes stores the smokes still up as not having faded.
+func (app *Application) saveActiveSmokes() {
+	for _, S := range app.activeSmokes {
+		model := mongo.NewInsertOneModel().SetDocument(S)
+		app.bulkInserts[ClSmokes] = append(app.bulkInserts[ClSmokes], model)
+	}
+	app.activeSmokes = make(map[int]*SmokeLifecycle)
+}
+
+// sampleTeamMetrics stores the TeamMetrics of the frame if teamMetricsInterval passed since they were last stored,
+// every saved frame if the header doesn't tell how long a tick takes.
+func (app *Application) sampleTeamMetrics() {
+	tick := app.parser.GameState().IngameTick()
+	if app.lastTeamMetricsTick >= 0 && app.tickTime > 0 && float64(tick-app.lastTeamMetricsTick)*app.tickTime < teamMetricsInterval {
+		return
+	}
+	app.lastTeamMetricsTick = tick
+	positions := make(map[common.Team][]r3.Vector, len(metricsTeams))
+	for _, team := range metricsTeams {
+		for _, p := range app.AliveMembers(team) {
+			positions[team] = append(positions[team], p.Position)
+		}
+	}
+	var bounds []r2.Point
+	if app.mapOverview != nil {
+		bounds = app.mapOverview.Bounds()
+	}
+	teams, err := NewTeamMetrics(positions, bounds, app.positionPrecision)
+	checkError(err)
+	data := FrameTeamMetrics{app.savedFrameNumber, tick, app.roundNumber, teams}
+	model := mongo.NewInsertOneModel().SetDocument(data)
+	app.bulkInserts[ClTeamMetrics] = append(app.bulkInserts[ClTeamMetrics], model)
+}
+
+// tagProjectile stores the weapon and the thrower of a projectile next to it.
+func (app *Application) tagProjectile(data map[string]interface{}, GP *common.GrenadeProjectile) {
+	data["Weapon"] = GP.Weapon
+	data["Thrower"] = int64(-1)
+	if GP.Thrower != nil {
+		data["Thrower"] = GP.Thrower.SteamID
+	}
+}
+
+// tagBombCallout stores the place of the player of a bomb event into its BombEvent document, where the player is.
+func (app *Application) tagBombCallout(data map[string]interface{}, BE events.BombEvent) {
+	if doc, ok := data["BombEvent"].(map[string]interface{}); ok {
+		app.tagCallouts(doc, map[string]*common.Player{"Player": BE.Player})
+	}
+}
+
+// tagCallouts stores the places the players of an event are in next to them, e.g. VictimCallout next to Victim.
+// Players missing from the event and places outside of every zone are left out.
+func (app *Application) tagCallouts(data map[string]interface{}, players map[string]*common.Player) {
+	for key, player := range players {
+		if player == nil {
+			continue
+		}
+		if callout := app.callout(player.Position); callout != "" {
+			data[key+"Callout"] = callout
+		}
+	}
+}
+
+// radarPoint returns the position on the map's radar if radar positions are stored, nil otherwise.
+func (app *Application) radarPoint(v r3.Vector) *overview.RadarPoint {
+	if !app.radar || app.mapOverview == nil {
+		return nil
+	}
+	p := app.mapOverview.ToRadar(v)
+	return &p
+}
+
+// viewAngle rounds a view angle down to the precision angles are stored with.
+func (app *Application) viewAngle(angle float32) float32 {
+	return AngleDegrees(FixedAngle(angle, app.codecs.ViewPrecision), app.codecs.ViewPrecision)
+}
+
 func (app *Application) calculateDelta(player *common.Player) PlayerMovementInfo {
 	PMI := PlayerMovementInfo{
 		SteamID: player.SteamID,
@@ -892,19 +1332,20 @@ func (app *Application) calculateDelta(player *common.Player) PlayerMovementInfo
 	if _, ok := app.playersLastPositions[PMI.SteamID]; !ok {
 		app.playersLastPositions[PMI.SteamID] = PlayerMovementInfo{
 			SteamID:	player.SteamID,
-			Position:	NewInt16Vector3(player.Position),
-			ViewX:		int16(player.ViewDirectionX),
-			ViewY:		int16(player.ViewDirectionY),
+			Position:	app.position(player.Position),
+			ViewX:		app.viewAngle(player.ViewDirectionX),
+			ViewY:		app.viewAngle(player.ViewDirectionY),
 		}
 		return app.playersLastPositions[PMI.SteamID]
 	} else {
-		PMI.Position = Int16Vector3{
-			int16(player.Position.X) - app.playersLastPositions[player.SteamID].Position.X,
-			int16(player.Position.Y) - app.playersLastPositions[player.SteamID].Position.Y,
-			int16(player.Position.X) - app.playersLastPositions[player.SteamID].Position.Z,
+		position := app.position(player.Position)
+		PMI.Position = FixedVector3{
+			position.X - app.playersLastPositions[player.SteamID].Position.X,
+			position.Y - app.playersLastPositions[player.SteamID].Position.Y,
+			position.Z - app.playersLastPositions[player.SteamID].Position.Z,
 		}
-		PMI.ViewX = int16(player.ViewDirectionX) - app.playersLastPositions[player.SteamID].ViewX
-		PMI.ViewY = int16(player.ViewDirectionY) - app.playersLastPositions[player.SteamID].ViewY
+		PMI.ViewX = app.viewAngle(player.ViewDirectionX) - app.playersLastPositions[player.SteamID].ViewX
+		PMI.ViewY = app.viewAngle(player.ViewDirectionY) - app.playersLastPositions[player.SteamID].ViewY
 	}
 	return PMI
 }
@@ -924,20 +1365,34 @@ func (app *Application) saveDataToMongo() {
 	//runtime.GC() // doesn't seem to be helpful at all -__-
 }
 
-func (app *Application) encodePlayerMovement(SteamID int64, playerMovement *PlayerMovement, reset bool) PlayerMovementInfoEncoded {
-	PMIE := PlayerMovementInfoEncoded {
-		playerMovement.StartFrame,
-		app.savedFrameNumber,
-		SteamID,
-		elias.EliasGammaNegative(elias.ArrayToDeltas(playerMovement.PositionX)...),
-		elias.EliasGammaNegative(elias.ArrayToDeltas(playerMovement.PositionY)...),
-		elias.EliasGammaNegative(elias.ArrayToDeltas(playerMovement.PositionZ)...),
-		elias.EliasGammaNegative(elias.ArrayToDeltas(playerMovement.ViewX)...),
-		elias.EliasGammaNegative(elias.ArrayToDeltas(playerMovement.ViewY)...),
+// samplePlayer adds where a player is and looks at in the current saved frame to their movement.
+// With adaptive sampling it is only added once the player has moved or turned far enough, or has gone unsampled
+// for long enough. The last observation left out is added along with a sample after a move, so the frames
+// in between interpolate to where the player stood, and when the movement is encoded, so it ends where the player was last.
+func (app *Application) samplePlayer(PM *PlayerMovement, s movementSample) {
+	if !app.sampling.enabled() || len(PM.PositionX) == 0 {
+		PM.add(s)
+		return
+	}
+	last := PM.last()
+	moved := app.sampling.moved(last, s, app.positionPrecision, app.codecs.ViewPrecision)
+	if !moved && s.frame-last.frame < app.sampling.MaxInterval {
+		PM.skipped = &s
+		return
 	}
-	if playerMovement.EndFrame == 0 { // player didn't get killed or disconnected
-		PMIE.EndFrame = app.savedFrameNumber
+	if moved && PM.skipped != nil {
+		PM.add(*PM.skipped)
+	}
+	PM.skipped = nil
+	PM.add(s)
+}
+
+func (app *Application) encodePlayerMovement(SteamID int64, playerMovement *PlayerMovement, reset bool) PlayerMovementInfoEncoded {
+	if playerMovement.skipped != nil {
+		playerMovement.add(*playerMovement.skipped)
+		playerMovement.skipped = nil
 	}
+	PMIE := NewPlayerMovementInfoEncoded(SteamID, playerMovement, app.savedFrameNumber, app.codecs)
 
 	if reset {
 		// reset movement data
@@ -946,14 +1401,36 @@ func (app *Application) encodePlayerMovement(SteamID int64, playerMovement *Play
 		playerMovement.EndFrame = 0
 		playerMovement.PositionX = playerMovement.PositionX[:0]
 		playerMovement.PositionY = playerMovement.PositionY[:0]
-		playerMovement.PositionZ = playerMovement.PositionX[:0]
-		playerMovement.ViewX = playerMovement.PositionX[:0]
+		playerMovement.PositionZ = playerMovement.PositionZ[:0]
+		playerMovement.ViewX = playerMovement.ViewX[:0]
 		playerMovement.ViewY = playerMovement.ViewY[:0]
+		playerMovement.Frames = playerMovement.Frames[:0]
 	}
 
 	return PMIE
 }
 
+// flushRoundMovement encodes the movement of the players still alive and stores it with the ones killed this round
+func (app *Application) flushRoundMovement() {
+	app.playerMovementEncodedData.RoundNumber = app.roundNumber
+
+	for k, v := range app.playersPositionsInRound {
+		if v.StartFrame == 0 {
+			continue // no movement
+		}
+		PMIE := app.encodePlayerMovement(k, v, true)
+		app.playerMovementEncodedData.PlayerMovements = append(app.playerMovementEncodedData.PlayerMovements, PMIE)
+	}
+
+	if len(app.playerMovementEncodedData.PlayerMovements) > 0 {
+		model := mongo.NewInsertOneModel().SetDocument(app.playerMovementEncodedData)
+		app.bulkInserts[ClPositions] = append(app.bulkInserts[ClPositions], model)
+	}
+
+	// the document isn't marshalled until the bulk write, so its array can't be reused
+	app.playerMovementEncodedData.PlayerMovements = make([]PlayerMovementInfoEncoded, 0, 20)
+}
+
 func (app *Application) getPlayerStats(player *common.Player) *PlayerRoundStats {
 	if app.playersStats[app.roundNumber-1] == nil {
 		app.playersStats[app.roundNumber-1] = make(map[int64]*PlayerRoundStats)
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"fmt"
+	"math"
+)
+
+// CheckSampleRate returns an error if positions can't be saved the given number of times per second.
+func CheckSampleRate(rate float64) error {
+	if !(rate > 0) || math.IsInf(rate, 1) {
+		return fmt.Errorf("invalid sample rate %v Hz, must be a positive number", rate)
+	}
+	return nil
+}
+
+// sampleClock picks the parsed frames positions are saved in, so they are saved at a steady rate in game time
+// whatever the frame rate and the frame timing of the demo. Time is split into slots of period ticks
+// (or frames, for demos whose header has no tick rate) and the first frame of every slot is sampled.
+// A clock faster than the demo samples every frame.
+type sampleClock struct {
+	period		float64
+	lastSlot	int64
+}
+
+// newSampleClock returns a clock sampling rate times a second of a time counted in units per second.
+func newSampleClock(rate, unitsPerSecond float64) (sampleClock, error) {
+	if err := CheckSampleRate(rate); err != nil {
+		return sampleClock{}, err
+	}
+	if !(unitsPerSecond > 0) || math.IsInf(unitsPerSecond, 1) {
+		return sampleClock{}, fmt.Errorf("invalid time base of %v per second", unitsPerSecond)
+	}
+	return sampleClock{unitsPerSecond / rate, math.MinInt64}, nil
+}
+
+// due reports whether the frame at the given time is the first one of its slot.
+func (c *sampleClock) due(time int) bool {
+	slot := int64(math.Floor(float64(time) / c.period))
+	if slot <= c.lastSlot {
+		return false
+	}
+	c.lastSlot = slot
+	return true
+}
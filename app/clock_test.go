@@ -0,0 +1,55 @@
+package app
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSampleClock(t *testing.T) {
+	for _, c := range []struct {
+		rate, tickRate  float64
+		ticks, expected int
+	}{
+		{32, 128, 1280, 320},
+		{20, 128, 1280, 200},
+		{102.4, 128, 1280, 1024},
+		{24, 64, 640, 240},
+		{128, 64, 640, 640},
+	} {
+		clock, err := newSampleClock(c.rate, c.tickRate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sampled := 0
+		for tick := 0; tick < c.ticks; tick++ {
+			if clock.due(tick) {
+				sampled++
+			}
+		}
+		if sampled != c.expected {
+			t.Error("sampling at ", c.rate, " Hz of ", c.tickRate, " ticks sampled ", sampled, " instead of ", c.expected)
+		}
+	}
+
+	// frames two ticks apart with a few dropped, sampled at 16 Hz of 64 ticks
+	clock, _ := newSampleClock(16, 64)
+	var sampled []int
+	for _, tick := range []int{0, 2, 4, 6, 8, 14, 16, 18, 20, 22, 30, 32, 34} {
+		if clock.due(tick) {
+			sampled = append(sampled, tick)
+		}
+	}
+	if expected := []int{0, 4, 8, 14, 16, 20, 30, 32}; !reflect.DeepEqual(sampled, expected) {
+		t.Error("sampling irregular frames failed, got ", sampled, " instead of ", expected)
+	}
+
+	for _, rate := range []float64{0, -32, math.NaN(), math.Inf(1)} {
+		if CheckSampleRate(rate) == nil {
+			t.Error("CheckSampleRate accepted ", rate)
+		}
+	}
+	if _, err := newSampleClock(32, 0); err == nil {
+		t.Error("newSampleClock accepted a demo without a tick rate")
+	}
+}
@@ -1,10 +1,15 @@
 package app
 
 import (
+	"csgo-parser-mongodb/overview"
+	"csgo-parser-mongodb/util/bitio"
+	"csgo-parser-mongodb/util/codec"
 	"csgo-parser-mongodb/util/elias"
+	"fmt"
 	"github.com/golang/geo/r2"
 	"github.com/golang/geo/r3"
 	"github.com/markus-wa/demoinfocs-golang/common"
+	"math"
 	"time"
 )
 
@@ -23,12 +28,53 @@ type FrameInfernos struct {
 	CurrentInfernos   []InfernoInfo         `bson:"CurrentInfernos"`
 }
 
-type Int16Vector3 struct {
-	X, Y, Z	int16
+// FixedVector3 is a position in fixed point, with the match's PositionPrecision bits after the point
+// (see the header). With the default precision of 0 it is in whole units.
+type FixedVector3 struct {
+	X, Y, Z	int32
 }
 
-func NewInt16Vector3(v r3.Vector) Int16Vector3 {
-	return Int16Vector3{int16(v.X), int16(v.Y), int16(v.Z)}
+// MaxPositionPrecision is the most bits after the point positions can be stored with.
+// It leaves room for coordinates up to 32768 units, twice as far as any map reaches.
+const MaxPositionPrecision = 16
+
+// CheckPositionPrecision returns an error if positions can't be stored with the given precision.
+func CheckPositionPrecision(precision int) error {
+	if precision < 0 || precision > MaxPositionPrecision {
+		return fmt.Errorf("unsupported position precision %d, must be between 0 and %d", precision, MaxPositionPrecision)
+	}
+	return nil
+}
+
+// FixedCoordinate converts a coordinate to fixed point with the given precision.
+// It is truncated toward zero, the way whole units have always been stored.
+func FixedCoordinate(x float64, precision int) (int32, error) {
+	scaled := math.Trunc(x * float64(int(1)<<uint(precision)))
+	if math.IsNaN(scaled) || scaled > math.MaxInt32 || scaled < math.MinInt32 {
+		return 0, fmt.Errorf("coordinate %v doesn't fit in fixed point with precision %d", x, precision)
+	}
+	return int32(scaled), nil
+}
+
+// NewFixedVector3 converts a position to fixed point with the given precision.
+func NewFixedVector3(v r3.Vector, precision int) (FixedVector3, error) {
+	var fv FixedVector3
+	var err error
+	for _, c := range []struct {
+		from	float64
+		to		*int32
+	}{{v.X, &fv.X}, {v.Y, &fv.Y}, {v.Z, &fv.Z}} {
+		if *c.to, err = FixedCoordinate(c.from, precision); err != nil {
+			return FixedVector3{}, err
+		}
+	}
+	return fv, nil
+}
+
+// Vector converts a fixed point position with the given precision back to world coordinates.
+func (fv FixedVector3) Vector(precision int) r3.Vector {
+	scale := float64(int(1) << uint(precision))
+	return r3.Vector{X: float64(fv.X) / scale, Y: float64(fv.Y) / scale, Z: float64(fv.Z) / scale}
 }
 
 type Int16Vector2 struct {
@@ -41,34 +87,186 @@ func NewInt16Vector2(v r2.Point) Int16Vector2 {
 
 type GrenadePositionInfo struct {
 	UniqueID	int64			`bson:"UniqueID"`
-	Position	Int16Vector3	`bson:"Position"`
+	Position	FixedVector3	`bson:"Position"`
+	// Radar is the position on the map's radar, stored only if asked for, see the -radar flag
+	Radar		*overview.RadarPoint	`bson:"Radar,omitempty"`
 }
 
 type GrenadePositionInfoEncoded struct {
 	StartFrame int
 	EndFrame   int
 	UniqueID   int64                    `bson:"UniqueID"`
+	Samples    int                      `bson:"Samples"`
+	Codec      codec.ID                 `bson:"Codec"`
+	Order      int                      `bson:"Order"`
 	PositionX  elias.BitArrayWithLength `bson:"X"`
 	PositionY  elias.BitArrayWithLength `bson:"Y"`
 	PositionZ  elias.BitArrayWithLength `bson:"Z"`
-}
-
+	// streams are keyframed every KeyframeInterval samples, not at all if it is 0
+	KeyframeInterval int              `bson:"KeyframeInterval"`
+	XKeyframes       []codec.Keyframe `bson:"XKeyframes,omitempty"`
+	YKeyframes       []codec.Keyframe `bson:"YKeyframes,omitempty"`
+	ZKeyframes       []codec.Keyframe `bson:"ZKeyframes,omitempty"`
+	// Approximated marks trajectories migrated from schema version 2, whose points were spread evenly
+	// over the frames the grenade flew as the frames they were taken in weren't stored
+	Approximated bool `bson:"Approximated,omitempty"`
+}
+
+// PlayerMovementInfo is where a player is and looks at. The view angles are in degrees,
+// yaw (ViewX) and pitch (ViewY) in [0, 360) as the game has them.
 type PlayerMovementInfo struct {
 	SteamID		int64			`bson:"SteamID"`
-	Position	Int16Vector3	`bson:"Position"`
-	ViewX		int16			`bson:"ViewX"`
-	ViewY		int16			`bson:"ViewY"`
+	Position	FixedVector3	`bson:"Position"`
+	ViewX		float32			`bson:"ViewX"`
+	ViewY		float32			`bson:"ViewY"`
+	// Radar is the position on the map's radar, stored only if asked for, see the -radar flag
+	Radar		*overview.RadarPoint	`bson:"Radar,omitempty"`
+	// Callout is the place of the map the player is in, stored only if asked for, see the -callouts flag
+	Callout		string			`bson:"Callout,omitempty"`
 }
 
 type PlayerMovementInfoEncoded struct {
-	StartFrame int
-	EndFrame   int
-	SteamID    int64                    `bson:"SteamID"`
+	StartFrame    int
+	EndFrame      int
+	SteamID       int64                    `bson:"SteamID"`
+	Samples       int                      `bson:"Samples"`
+	PositionCodec codec.ID                 `bson:"PositionCodec"`
+	ViewCodec     codec.ID                 `bson:"ViewCodec"`
+	PositionOrder int                      `bson:"PositionOrder"`
+	ViewOrder     int                      `bson:"ViewOrder"`
+	ViewPrecision int                      `bson:"ViewPrecision"`
 	PositionX  elias.BitArrayWithLength `bson:"X"`
 	PositionY  elias.BitArrayWithLength `bson:"Y"`
 	PositionZ  elias.BitArrayWithLength `bson:"Z"`
-	ViewX      elias.BitArrayWithLength `bson:"ViewXArray"`
-	ViewY      elias.BitArrayWithLength `bson:"ViewYArray"`
+	ViewX      elias.BitArrayWithLength `bson:"ViewX"`
+	ViewY      elias.BitArrayWithLength `bson:"ViewY"`
+	// streams are keyframed every KeyframeInterval samples, not at all if it is 0
+	KeyframeInterval int              `bson:"KeyframeInterval"`
+	XKeyframes       []codec.Keyframe `bson:"XKeyframes,omitempty"`
+	YKeyframes       []codec.Keyframe `bson:"YKeyframes,omitempty"`
+	ZKeyframes       []codec.Keyframe `bson:"ZKeyframes,omitempty"`
+	ViewXKeyframes   []codec.Keyframe `bson:"ViewXKeyframes,omitempty"`
+	ViewYKeyframes   []codec.Keyframe `bson:"ViewYKeyframes,omitempty"`
+	// the gaps between the frames of the samples less one, Elias gamma coded; left out if every frame was sampled.
+	// With keyframes, FramesKeyframes holds the frame of every KeyframeInterval-th sample and the bit
+	// the gap after it starts at, so the frames around any one can be decoded without the rest.
+	Frames          elias.BitArrayWithLength `bson:"Frames,omitempty"`
+	FramesKeyframes []codec.Keyframe         `bson:"FramesKeyframes,omitempty"`
+}
+
+// StreamCodecs picks the codec and the prediction order each kind of encoded stream is stored with.
+// View angles are stored in fixed point, with ViewPrecision bits after the point.
+// Every stream gets a keyframe every KeyframeInterval samples to be decoded from, none if it is 0.
+type StreamCodecs struct {
+	Position			codec.ID
+	View				codec.ID
+	Grenade				codec.ID
+	PositionOrder		int
+	ViewOrder			int
+	GrenadeOrder		int
+	ViewPrecision		int
+	KeyframeInterval	int
+}
+
+// DefaultStreamCodecs stores the deltas of every stream Elias gamma coded, view angles in whole degrees,
+// without keyframes.
+var DefaultStreamCodecs = StreamCodecs{codec.Gamma, codec.Gamma, codec.Gamma, 1, 1, 1, 0, 0}
+
+// DefaultKeyframeInterval is the number of samples between keyframes the parser stores streams with
+// unless told otherwise: two seconds of movement at the default frame rate.
+const DefaultKeyframeInterval = 64
+
+// MaxViewPrecision is the most bits after the point view angles can be stored with.
+// float32 angles below 360 degrees have no more than 15 of them.
+const MaxViewPrecision = 15
+
+// CheckViewPrecision returns an error if view angles can't be stored with the given precision.
+func CheckViewPrecision(precision int) error {
+	if precision < 0 || precision > MaxViewPrecision {
+		return fmt.Errorf("unsupported view angle precision %d, must be between 0 and %d", precision, MaxViewPrecision)
+	}
+	return nil
+}
+
+// FixedAngle converts an angle in degrees to fixed point with the given precision, rounding down
+// like the whole degrees the parser has always stored.
+func FixedAngle(angle float32, precision int) int {
+	return int(math.Floor(float64(angle) * float64(int(1)<<uint(precision))))
+}
+
+// AngleDegrees converts a fixed point angle back to degrees.
+func AngleDegrees(angle, precision int) float32 {
+	return float32(float64(angle) / float64(int(1)<<uint(precision)))
+}
+
+// fullTurn is a full circle in fixed point angles of the given precision.
+func fullTurn(precision int) int {
+	return 360 << uint(precision)
+}
+
+// encodeStream encodes the values of a stream, keyframed if an interval is given.
+func encodeStream(id codec.ID, order, interval int, values []int) (elias.BitArrayWithLength, []codec.Keyframe) {
+	c, err := codec.Get(id)
+	checkError(err)
+	checkError(codec.CheckOrder(order))
+	checkError(codec.CheckInterval(interval))
+	if interval == 0 {
+		return c.Encode(codec.Residuals(values, order)), nil
+	}
+	return codec.Keyframed{Codec: c, Order: order, Interval: interval}.Encode(values)
+}
+
+// NewPlayerMovementInfoEncoded encodes the movement a player has made up to endFrame.
+func NewPlayerMovementInfoEncoded(SteamID int64, playerMovement *PlayerMovement, endFrame int, codecs StreamCodecs) PlayerMovementInfoEncoded {
+	PMIE := PlayerMovementInfoEncoded{
+		StartFrame:			playerMovement.StartFrame,
+		EndFrame:			endFrame,
+		SteamID:			SteamID,
+		Samples:			len(playerMovement.PositionX),
+		PositionCodec:		codecs.Position,
+		ViewCodec:			codecs.View,
+		PositionOrder:		codecs.PositionOrder,
+		ViewOrder:			codecs.ViewOrder,
+		ViewPrecision:		codecs.ViewPrecision,
+		KeyframeInterval:	codecs.KeyframeInterval,
+	}
+	PMIE.PositionX, PMIE.XKeyframes = encodeStream(codecs.Position, codecs.PositionOrder, codecs.KeyframeInterval, playerMovement.PositionX)
+	PMIE.PositionY, PMIE.YKeyframes = encodeStream(codecs.Position, codecs.PositionOrder, codecs.KeyframeInterval, playerMovement.PositionY)
+	PMIE.PositionZ, PMIE.ZKeyframes = encodeStream(codecs.Position, codecs.PositionOrder, codecs.KeyframeInterval, playerMovement.PositionZ)
+	// turning across 0 would be a jump of almost a full turn, and so would looking up
+	// from slightly below the horizon, as pitch goes down from 360 above it
+	PMIE.ViewX, PMIE.ViewXKeyframes = encodeStream(codecs.View, codecs.ViewOrder, codecs.KeyframeInterval,
+		codec.UnwrapAngles(playerMovement.ViewX, fullTurn(codecs.ViewPrecision)))
+	PMIE.ViewY, PMIE.ViewYKeyframes = encodeStream(codecs.View, codecs.ViewOrder, codecs.KeyframeInterval,
+		codec.SignedAngles(playerMovement.ViewY, fullTurn(codecs.ViewPrecision)))
+	if !playerMovement.sampledEveryFrame() {
+		PMIE.Frames, PMIE.FramesKeyframes = encodeFrames(playerMovement.Frames, codecs.KeyframeInterval)
+	}
+	return PMIE
+}
+
+// encodeFrames encodes the frames of the samples as the gaps between them, with a keyframe every interval samples
+// if one is given. Elias gamma codes are read one after another, so keyframes are just offsets into the same codes.
+func encodeFrames(frames []int, interval int) (elias.BitArrayWithLength, []codec.Keyframe) {
+	gaps := make([]uint, 0, len(frames))
+	for i := 1; i < len(frames); i++ {
+		gaps = append(gaps, uint(frames[i]-frames[i-1]-1))
+	}
+	if interval == 0 {
+		return elias.EliasGamma(gaps...), nil
+	}
+	w := bitio.NewBitWriter(uint64(len(gaps)) * 4)
+	var keyframes []codec.Keyframe
+	for start := 0; start < len(frames); start += interval {
+		end := start + interval
+		if end > len(gaps) {
+			end = len(gaps)
+		}
+		codes := elias.EliasGamma(gaps[start:end]...)
+		keyframes = append(keyframes, codec.Keyframe{Offset: w.Len(), Value: frames[start]})
+		w.WriteStream(codes.Words(), 0, codes.Len())
+	}
+	return elias.FromBitWriter(w), keyframes
 }
 
 type GrenadeProjectileWithStartFrame struct {
@@ -76,15 +274,118 @@ type GrenadeProjectileWithStartFrame struct {
 	*common.GrenadeProjectile
 }
 
+// PlayerMovement is the movement of a player sampled since StartFrame, at every saved frame unless
+// Frames holds the frames the samples were taken in, see AdaptiveSampling.
+// View angles are in fixed point with ViewPrecision bits after the point, see FixedAngle.
 type PlayerMovement struct {
-	StartFrame	int
-	EndFrame	int
+	StartFrame		int
+	EndFrame		int
+	ViewPrecision	int
 	SteamID		int64	`bson:"SteamID"`
 	PositionX	[]int	`bson:"X"`
 	PositionY	[]int	`bson:"Y"`
 	PositionZ	[]int	`bson:"Z"`
-	ViewX		[]int	`bson:"ViewXArray"`
-	ViewY		[]int	`bson:"ViewYArray"`
+	ViewX		[]int	`bson:"ViewX"`
+	ViewY		[]int	`bson:"ViewY"`
+	Frames		[]int	`bson:"Frames"`
+
+	// the last observation adaptive sampling left out, see Application.samplePlayer
+	skipped	*movementSample
+}
+
+// movementSample is where a player is and looks at in a saved frame, as a PlayerMovement stores it.
+type movementSample struct {
+	frame			int
+	position		FixedVector3
+	viewX, viewY	int
+}
+
+func (PM *PlayerMovement) add(s movementSample) {
+	PM.Frames = append(PM.Frames, s.frame)
+	PM.PositionX = append(PM.PositionX, int(s.position.X))
+	PM.PositionY = append(PM.PositionY, int(s.position.Y))
+	PM.PositionZ = append(PM.PositionZ, int(s.position.Z))
+	PM.ViewX = append(PM.ViewX, s.viewX)
+	PM.ViewY = append(PM.ViewY, s.viewY)
+}
+
+func (PM *PlayerMovement) last() movementSample {
+	n := len(PM.PositionX) - 1
+	return movementSample{
+		PM.Frames[n],
+		FixedVector3{int32(PM.PositionX[n]), int32(PM.PositionY[n]), int32(PM.PositionZ[n])},
+		PM.ViewX[n],
+		PM.ViewY[n],
+	}
+}
+
+// sampledEveryFrame reports whether the movement has a sample in every frame from StartFrame on.
+func (PM *PlayerMovement) sampledEveryFrame() bool {
+	for i, frame := range PM.Frames {
+		if frame != PM.StartFrame+i {
+			return false
+		}
+	}
+	return true
+}
+
+// AdaptiveSampling samples a player's movement only when they have moved more than PositionThreshold units
+// or turned more than ViewThreshold degrees since their last sample, or MaxInterval saved frames have passed.
+// Stored movements then record the frames of their samples, the frames in between are interpolated.
+// A MaxInterval of 0 samples every saved frame.
+type AdaptiveSampling struct {
+	PositionThreshold	float64
+	ViewThreshold		float64
+	MaxInterval			int
+}
+
+// CheckAdaptiveSampling returns an error if movements can't be sampled with the given settings.
+func CheckAdaptiveSampling(s AdaptiveSampling) error {
+	if s.MaxInterval < 0 {
+		return fmt.Errorf("invalid adaptive sampling interval %d, must not be negative", s.MaxInterval)
+	}
+	if !(s.PositionThreshold >= 0) || !(s.ViewThreshold >= 0) {
+		return fmt.Errorf("invalid adaptive sampling thresholds %v and %v, must not be negative", s.PositionThreshold, s.ViewThreshold)
+	}
+	return nil
+}
+
+func (s AdaptiveSampling) enabled() bool {
+	return s.MaxInterval > 0
+}
+
+// moved reports whether a player has moved or turned beyond the thresholds from one sample to another.
+func (s AdaptiveSampling) moved(from, to movementSample, positionPrecision, viewPrecision int) bool {
+	distance := to.position.Vector(positionPrecision).Sub(from.position.Vector(positionPrecision)).Norm()
+	turn := fullTurn(viewPrecision)
+	return distance > s.PositionThreshold ||
+		math.Abs(float64(AngleDegrees(angleDifference(from.viewX, to.viewX, turn), viewPrecision))) > s.ViewThreshold ||
+		math.Abs(float64(AngleDegrees(angleDifference(from.viewY, to.viewY, turn), viewPrecision))) > s.ViewThreshold
+}
+
+// GrenadeMovement is the position of a flying grenade sampled at every saved frame since StartFrame.
+type GrenadeMovement struct {
+	StartFrame	int
+	PositionX	[]int
+	PositionY	[]int
+	PositionZ	[]int
+}
+
+// NewGrenadePositionInfoEncoded encodes the positions a grenade has been sampled at.
+func NewGrenadePositionInfoEncoded(UniqueID int64, grenadeMovement *GrenadeMovement, codecs StreamCodecs) GrenadePositionInfoEncoded {
+	GPIE := GrenadePositionInfoEncoded{
+		StartFrame:			grenadeMovement.StartFrame,
+		EndFrame:			grenadeMovement.StartFrame + len(grenadeMovement.PositionX) - 1,
+		UniqueID:			UniqueID,
+		Samples:			len(grenadeMovement.PositionX),
+		Codec:				codecs.Grenade,
+		Order:				codecs.GrenadeOrder,
+		KeyframeInterval:	codecs.KeyframeInterval,
+	}
+	GPIE.PositionX, GPIE.XKeyframes = encodeStream(codecs.Grenade, codecs.GrenadeOrder, codecs.KeyframeInterval, grenadeMovement.PositionX)
+	GPIE.PositionY, GPIE.YKeyframes = encodeStream(codecs.Grenade, codecs.GrenadeOrder, codecs.KeyframeInterval, grenadeMovement.PositionY)
+	GPIE.PositionZ, GPIE.ZKeyframes = encodeStream(codecs.Grenade, codecs.GrenadeOrder, codecs.KeyframeInterval, grenadeMovement.PositionZ)
+	return GPIE
 }
 
 type RoundMovement struct {
@@ -135,14 +436,19 @@ type PlayerStateInfo struct {
 	IsAlive					bool					`bson:"IsAlive"`
 	IsBlinded				bool					`bson:"IsBlinded"`
 	Money					int						`bson:"Money"`
-	LastAlivePosition		Int16Vector3			`bson:"LastAlivePosition"`
-	ViewX					int16					`bson:"ViewX"`
-	ViewY					int16					`bson:"ViewY"`
+	LastAlivePosition		FixedVector3			`bson:"LastAlivePosition"`
+	// view angles are in fixed point with the header's ViewPrecision bits after the point, see FixedAngle
+	ViewX					int						`bson:"ViewX"`
+	ViewY					int						`bson:"ViewY"`
 	Inventory				[]EquipmentInfo			`bson:"Inventory"`
 	AdditionalInfo			AdditionalPlayerInfo	`bson:"AdditionalInfo"`
 }
 
-func NewPlayerStateInfo(p *common.Player) PlayerStateInfo {
+func NewPlayerStateInfo(p *common.Player, positionPrecision, viewPrecision int) (PlayerStateInfo, error) {
+	lastAlivePosition, err := NewFixedVector3(p.LastAlivePosition, positionPrecision)
+	if err != nil {
+		return PlayerStateInfo{}, fmt.Errorf("last alive position of player %d: %v", p.SteamID, err)
+	}
 	PSI := PlayerStateInfo{
 		-1,
 		p.Team,
@@ -162,9 +468,9 @@ func NewPlayerStateInfo(p *common.Player) PlayerStateInfo {
 		p.IsAlive(),
 		p.IsBlinded(),
 		p.Money,
-		NewInt16Vector3(p.LastAlivePosition),
-		int16(p.ViewDirectionX),
-		int16(p.ViewDirectionY),
+		lastAlivePosition,
+		FixedAngle(p.ViewDirectionX, viewPrecision),
+		FixedAngle(p.ViewDirectionY, viewPrecision),
 		make([]EquipmentInfo, 0, 7),
 		NewAdditionalPlayerInfo(p.AdditionalPlayerInformation),
 	}
@@ -180,7 +486,7 @@ func NewPlayerStateInfo(p *common.Player) PlayerStateInfo {
 			PSI.HasBomb = true
 		}
 	}
-	return PSI
+	return PSI, nil
 }
 
 func NewPlayerStaticInfo(player common.Player) PlayerStaticInfo {
@@ -290,6 +596,8 @@ func NewPlayerStats(SteamID int64, Name string) *PlayerRoundStats {
 
 type GameStateInfo struct {
 	FrameNumber	int					`bson:"FrameNumber"`
+	Tick		int					`bson:"Tick"`
+	RoundNumber	int					`bson:"RoundNumber"`
 	Players		[]PlayerStateInfo	`bson:"Players"`
 }
 
@@ -313,11 +621,13 @@ type InfernoInfo struct {
 
 type FlashExplodeInfo struct {
 	//UniqueID	int64			`bson:"UniqueID"`
-	Position	Int16Vector3	`bson:"Position"`
+	Position	FixedVector3	`bson:"Position"`
 }
 
 type EventInfo struct {
 	FrameNumber int						`bson:"FrameNumber"`
+	Tick		int						`bson:"Tick"`
+	RoundNumber	int						`bson:"RoundNumber"`
 	EventType	EvType					`bson:"EventType"`
 	Data		map[string]interface{}	`bson:"Data, omitempty"`
 }
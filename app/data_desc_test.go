@@ -0,0 +1,33 @@
+package app
+
+import (
+	"github.com/golang/geo/r3"
+	"testing"
+)
+
+func TestFixedVector3(t *testing.T) {
+	v := r3.Vector{X: -1234.8, Y: 567.3125, Z: 16.06}
+	whole, err := NewFixedVector3(v, 0)
+	if expected := (FixedVector3{-1234, 567, 16}); err != nil || whole != expected {
+		t.Error("NewFixedVector3 failed in whole units, got ", whole, err, " instead of ", expected)
+	}
+	eighths, err := NewFixedVector3(v, 3)
+	if expected := (FixedVector3{-9878, 4538, 128}); err != nil || eighths != expected {
+		t.Error("NewFixedVector3 failed in eighths, got ", eighths, err, " instead of ", expected)
+	}
+	if back := eighths.Vector(3); back.Distance(v) > 0.25 {
+		t.Error("Vector failed, got ", back, " for ", v)
+	}
+
+	// beyond the range of int16, where positions used to overflow
+	far, err := NewFixedVector3(r3.Vector{X: 40000}, 0)
+	if err != nil || far.X != 40000 {
+		t.Error("NewFixedVector3 failed beyond int16, got ", far, err)
+	}
+	if _, err := NewFixedVector3(r3.Vector{Y: 40000}, MaxPositionPrecision); err == nil {
+		t.Error("NewFixedVector3 didn't fail on overflow")
+	}
+	if CheckPositionPrecision(MaxPositionPrecision+1) == nil {
+		t.Error("CheckPositionPrecision accepted an unsupported precision")
+	}
+}
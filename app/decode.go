@@ -0,0 +1,409 @@
+package app
+
+import (
+	"csgo-parser-mongodb/util/bitio"
+	"csgo-parser-mongodb/util/codec"
+	"csgo-parser-mongodb/util/elias"
+	"fmt"
+	"sort"
+)
+
+// DecodePlayerMovement restores the absolute position and view angle series of an encoded movement.
+// Sample i belongs to frame Frames[i] if the movement stores its frames, as adaptively sampled ones do,
+// and to frame StartFrame + i otherwise.
+func DecodePlayerMovement(PMIE PlayerMovementInfoEncoded) (PlayerMovement, error) {
+	positionCodec, viewCodec, err := playerCodecs(PMIE)
+	if err != nil {
+		return PlayerMovement{}, err
+	}
+	n := samples(PMIE.Samples, positionCodec, viewCodec)
+	turn := fullTurn(PMIE.ViewPrecision)
+	PM := PlayerMovement{
+		StartFrame:		PMIE.StartFrame,
+		EndFrame:		PMIE.EndFrame,
+		ViewPrecision:	PMIE.ViewPrecision,
+		SteamID:		PMIE.SteamID,
+	}
+	for _, stream := range []struct {
+		values		*[]int
+		c			codec.Codec
+		order		int
+		ba			elias.BitArrayWithLength
+		keyframes	[]codec.Keyframe
+	}{
+		{&PM.PositionX, positionCodec, PMIE.PositionOrder, PMIE.PositionX, PMIE.XKeyframes},
+		{&PM.PositionY, positionCodec, PMIE.PositionOrder, PMIE.PositionY, PMIE.YKeyframes},
+		{&PM.PositionZ, positionCodec, PMIE.PositionOrder, PMIE.PositionZ, PMIE.ZKeyframes},
+		{&PM.ViewX, viewCodec, PMIE.ViewOrder, PMIE.ViewX, PMIE.ViewXKeyframes},
+		{&PM.ViewY, viewCodec, PMIE.ViewOrder, PMIE.ViewY, PMIE.ViewYKeyframes},
+	} {
+		if *stream.values, err = decodeStream(stream.c, stream.order, PMIE.KeyframeInterval, stream.ba, stream.keyframes, n); err != nil {
+			return PlayerMovement{}, fmt.Errorf("movement of player %d: %v", PMIE.SteamID, err)
+		}
+	}
+	// older streams hold the angles as they are, which wrapping leaves alone
+	PM.ViewX = codec.WrapAngles(PM.ViewX, turn)
+	PM.ViewY = codec.WrapAngles(PM.ViewY, turn)
+	if PM.Frames, err = sampleFrames(PMIE, len(PM.PositionX)); err != nil {
+		return PlayerMovement{}, err
+	}
+	return PM, nil
+}
+
+// sampleFrames returns the frames the n samples of an encoded movement were taken in,
+// nil if every frame from StartFrame on was sampled.
+func sampleFrames(PMIE PlayerMovementInfoEncoded, n int) ([]int, error) {
+	if PMIE.Frames.IsZero() {
+		return nil, nil
+	}
+	gaps := elias.EliasGammaDecode(PMIE.Frames, false)
+	if len(gaps) != n-1 {
+		return nil, fmt.Errorf("movement of player %d has frames for %d samples, expected %d", PMIE.SteamID, len(gaps)+1, n)
+	}
+	frames := make([]int, n)
+	frames[0] = PMIE.StartFrame
+	for i, gap := range gaps {
+		frames[i+1] = frames[i] + gap + 1
+	}
+	return frames, nil
+}
+
+// framesAround returns the frames of the samples of an encoded movement the given frame lies among and the index
+// of the first of them, nil if every frame from StartFrame on was sampled. With keyframes these are the samples
+// of the segment the frame is in and the first one after it, otherwise all of them.
+func framesAround(PMIE PlayerMovementInfoEncoded, n, frame int) (int, []int, error) {
+	if len(PMIE.FramesKeyframes) == 0 {
+		frames, err := sampleFrames(PMIE, n)
+		return 0, frames, err
+	}
+	interval, keyframes := PMIE.KeyframeInterval, PMIE.FramesKeyframes
+	if interval <= 0 || len(keyframes) != (n+interval-1)/interval {
+		return 0, nil, fmt.Errorf("movement of player %d has %d frame keyframes for %d samples", PMIE.SteamID, len(keyframes), n)
+	}
+	segment := sort.Search(len(keyframes), func(s int) bool { return keyframes[s].Value > frame }) - 1
+	if segment < 0 {
+		segment = 0
+	}
+	from, to := keyframes[segment].Offset, PMIE.Frames.Len()
+	if segment+1 < len(keyframes) {
+		to = keyframes[segment+1].Offset
+	}
+	if from > to || to > PMIE.Frames.Len() {
+		return 0, nil, fmt.Errorf("movement of player %d has a frame keyframe pointing to bits %d to %d of %d", PMIE.SteamID, from, to, PMIE.Frames.Len())
+	}
+	w := bitio.NewBitWriter(to - from)
+	w.WriteStream(PMIE.Frames.Words(), from, to-from)
+	gaps := elias.EliasGammaDecode(elias.FromBitWriter(w), false)
+	// the gap to the first sample of the next segment ends this one
+	first := segment * interval
+	expected := n - 1 - first
+	if expected > interval {
+		expected = interval
+	}
+	if len(gaps) != expected {
+		return 0, nil, fmt.Errorf("movement of player %d has %d frames after sample %d, expected %d", PMIE.SteamID, len(gaps), first, expected)
+	}
+	frames := make([]int, len(gaps)+1)
+	frames[0] = keyframes[segment].Value
+	for i, gap := range gaps {
+		frames[i+1] = frames[i] + gap + 1
+	}
+	return first, frames, nil
+}
+
+// PlayerMovementAt returns where the player of an encoded movement is and looks at in the given frame,
+// interpolated between the samples around it if it wasn't sampled. It reports false if the frame is outside the movement.
+// Keyframed streams, the frames of the samples included, are decoded from the keyframe before the frame on,
+// others from StartFrame.
+func PlayerMovementAt(PMIE PlayerMovementInfoEncoded, frame int) (PlayerMovementInfo, bool, error) {
+	positionCodec, viewCodec, err := playerCodecs(PMIE)
+	if err != nil {
+		return PlayerMovementInfo{}, false, err
+	}
+	n := samples(PMIE.Samples, positionCodec, viewCodec)
+	if n < 0 {
+		// the number of samples was recorded before streams had keyframes, so older ones are decoded whole
+		PM, err := DecodePlayerMovement(PMIE)
+		if err != nil {
+			return PlayerMovementInfo{}, false, err
+		}
+		PMI, ok := PM.At(frame)
+		return PMI, ok, nil
+	}
+	first, frames, err := framesAround(PMIE, n, frame)
+	if err != nil {
+		return PlayerMovementInfo{}, false, err
+	}
+	i, num, den, ok := locateFrame(frames, PMIE.StartFrame, n, frame)
+	if !ok {
+		return PlayerMovementInfo{}, false, nil
+	}
+	i += first
+	count := 1
+	if num != 0 {
+		count = 2
+	}
+
+	turn := fullTurn(PMIE.ViewPrecision)
+	var values [5]int
+	for k, stream := range []struct {
+		c			codec.Codec
+		order		int
+		ba			elias.BitArrayWithLength
+		keyframes	[]codec.Keyframe
+	}{
+		{positionCodec, PMIE.PositionOrder, PMIE.PositionX, PMIE.XKeyframes},
+		{positionCodec, PMIE.PositionOrder, PMIE.PositionY, PMIE.YKeyframes},
+		{positionCodec, PMIE.PositionOrder, PMIE.PositionZ, PMIE.ZKeyframes},
+		{viewCodec, PMIE.ViewOrder, PMIE.ViewX, PMIE.ViewXKeyframes},
+		{viewCodec, PMIE.ViewOrder, PMIE.ViewY, PMIE.ViewYKeyframes},
+	} {
+		around, err := streamValuesAt(stream.c, stream.order, PMIE.KeyframeInterval, stream.ba, stream.keyframes, n, i, count)
+		if err != nil {
+			return PlayerMovementInfo{}, false, fmt.Errorf("movement of player %d in frame %d: %v", PMIE.SteamID, frame, err)
+		}
+		switch {
+		case count == 1:
+			values[k] = around[0]
+		case k < 3:
+			values[k] = interpolate(around[0], around[1], num, den)
+		default:
+			values[k] = interpolateAngle(around[0], around[1], num, den, turn)
+		}
+	}
+	angles := codec.WrapAngles(values[3:], turn)
+	return PlayerMovementInfo{
+		PMIE.SteamID,
+		FixedVector3{int32(values[0]), int32(values[1]), int32(values[2])},
+		AngleDegrees(angles[0], PMIE.ViewPrecision),
+		AngleDegrees(angles[1], PMIE.ViewPrecision),
+		nil,
+		"",
+	}, true, nil
+}
+
+// playerCodecs returns the codecs of the position and view angle streams of an encoded movement,
+// or an error if it can't be decoded.
+func playerCodecs(PMIE PlayerMovementInfoEncoded) (codec.Codec, codec.Codec, error) {
+	positionCodec, err := codec.Get(PMIE.PositionCodec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("movement of player %d: %v", PMIE.SteamID, err)
+	}
+	viewCodec, err := codec.Get(PMIE.ViewCodec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("movement of player %d: %v", PMIE.SteamID, err)
+	}
+	if err := checkOrders(PMIE.PositionOrder, PMIE.ViewOrder); err != nil {
+		return nil, nil, fmt.Errorf("movement of player %d: %v", PMIE.SteamID, err)
+	}
+	if err := CheckViewPrecision(PMIE.ViewPrecision); err != nil {
+		return nil, nil, fmt.Errorf("movement of player %d: %v", PMIE.SteamID, err)
+	}
+	if err := codec.CheckInterval(PMIE.KeyframeInterval); err != nil {
+		return nil, nil, fmt.Errorf("movement of player %d: %v", PMIE.SteamID, err)
+	}
+	return positionCodec, viewCodec, nil
+}
+
+// DecodeGrenadeMovement restores the position series of an encoded grenade.
+// Sample i belongs to frame StartFrame + i.
+func DecodeGrenadeMovement(GPIE GrenadePositionInfoEncoded) (GrenadeMovement, error) {
+	c, err := grenadeCodec(GPIE)
+	if err != nil {
+		return GrenadeMovement{}, err
+	}
+	n := samples(GPIE.Samples, c)
+	GM := GrenadeMovement{StartFrame: GPIE.StartFrame}
+	for _, stream := range []struct {
+		values		*[]int
+		ba			elias.BitArrayWithLength
+		keyframes	[]codec.Keyframe
+	}{
+		{&GM.PositionX, GPIE.PositionX, GPIE.XKeyframes},
+		{&GM.PositionY, GPIE.PositionY, GPIE.YKeyframes},
+		{&GM.PositionZ, GPIE.PositionZ, GPIE.ZKeyframes},
+	} {
+		if *stream.values, err = decodeStream(c, GPIE.Order, GPIE.KeyframeInterval, stream.ba, stream.keyframes, n); err != nil {
+			return GrenadeMovement{}, fmt.Errorf("trajectory of grenade %d: %v", GPIE.UniqueID, err)
+		}
+	}
+	return GM, nil
+}
+
+// GrenadePositionAt returns where an encoded grenade is in the given frame,
+// decoding keyframed streams from the keyframe before the frame on.
+func GrenadePositionAt(GPIE GrenadePositionInfoEncoded, frame int) (GrenadePositionInfo, error) {
+	c, err := grenadeCodec(GPIE)
+	if err != nil {
+		return GrenadePositionInfo{}, err
+	}
+	n, i := samples(GPIE.Samples, c), frame-GPIE.StartFrame
+	var values [3]int
+	for k, stream := range []struct {
+		ba			elias.BitArrayWithLength
+		keyframes	[]codec.Keyframe
+	}{
+		{GPIE.PositionX, GPIE.XKeyframes},
+		{GPIE.PositionY, GPIE.YKeyframes},
+		{GPIE.PositionZ, GPIE.ZKeyframes},
+	} {
+		value, err := streamValuesAt(c, GPIE.Order, GPIE.KeyframeInterval, stream.ba, stream.keyframes, n, i, 1)
+		if err != nil {
+			return GrenadePositionInfo{}, fmt.Errorf("trajectory of grenade %d in frame %d: %v", GPIE.UniqueID, frame, err)
+		}
+		values[k] = value[0]
+	}
+	return GrenadePositionInfo{GPIE.UniqueID, FixedVector3{int32(values[0]), int32(values[1]), int32(values[2])}, nil}, nil
+}
+
+func grenadeCodec(GPIE GrenadePositionInfoEncoded) (codec.Codec, error) {
+	c, err := codec.Get(GPIE.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("trajectory of grenade %d: %v", GPIE.UniqueID, err)
+	}
+	if err := checkOrders(GPIE.Order); err != nil {
+		return nil, fmt.Errorf("trajectory of grenade %d: %v", GPIE.UniqueID, err)
+	}
+	if err := codec.CheckInterval(GPIE.KeyframeInterval); err != nil {
+		return nil, fmt.Errorf("trajectory of grenade %d: %v", GPIE.UniqueID, err)
+	}
+	return c, nil
+}
+
+// samples returns the number of values to decode from the streams of a document.
+// Documents stored before the number was recorded are all gamma coded, which is decoded up to the end.
+// Streams of other codecs recording none are empty.
+func samples(n int, codecs ...codec.Codec) int {
+	if n != 0 {
+		return n
+	}
+	for _, c := range codecs {
+		if c.ID() != codec.Gamma {
+			return 0
+		}
+	}
+	return -1
+}
+
+// decodeStream decodes n values of a stream, or all of them if n < 0. Streams without keyframes
+// have interval 0 and are one sequence of residuals; documents with keyframes always record n.
+func decodeStream(c codec.Codec, order, interval int, ba elias.BitArrayWithLength, keyframes []codec.Keyframe, n int) ([]int, error) {
+	if interval == 0 {
+		values, err := c.Decode(ba, n)
+		if err != nil {
+			return nil, err
+		}
+		return codec.Restore(values, order), nil
+	}
+	return codec.Keyframed{Codec: c, Order: order, Interval: interval}.Decode(ba, keyframes, n)
+}
+
+// streamValuesAt decodes count values of a stream from value i on, from the keyframe before if it has keyframes.
+func streamValuesAt(c codec.Codec, order, interval int, ba elias.BitArrayWithLength, keyframes []codec.Keyframe, n, i, count int) ([]int, error) {
+	if interval > 0 {
+		return codec.Keyframed{Codec: c, Order: order, Interval: interval}.DecodeRange(ba, keyframes, n, i, i+count)
+	}
+	values, err := decodeStream(c, order, interval, ba, keyframes, n)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i+count > len(values) {
+		return nil, fmt.Errorf("samples %d to %d out of range, the stream has %d", i, i+count, len(values))
+	}
+	return values[i : i+count], nil
+}
+
+func checkOrders(orders ...int) error {
+	for _, order := range orders {
+		if err := codec.CheckOrder(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func minLength(arrays ...[]int) int {
+	if len(arrays) == 0 {
+		return 0
+	}
+	l := len(arrays[0])
+	for _, a := range arrays[1:] {
+		if len(a) < l {
+			l = len(a)
+		}
+	}
+	return l
+}
+
+// RoundMovementFrames puts the decoded movements of a round together into per frame positions,
+// the shape positions are stored in without Elias encoding. Players are sorted by SteamID.
+// Frames adaptive sampling left out are interpolated.
+func RoundMovementFrames(RM RoundMovement) ([]FramePositions, error) {
+	byFrame := make(map[int][]PlayerMovementInfo)
+	for _, PMIE := range RM.PlayerMovements {
+		PM, err := DecodePlayerMovement(PMIE)
+		if err != nil {
+			return nil, fmt.Errorf("round %d: %v", RM.RoundNumber, err)
+		}
+		PM = PM.Interpolated()
+		for i := 0; i < minLength(PM.PositionX, PM.PositionY, PM.PositionZ, PM.ViewX, PM.ViewY); i++ {
+			frame := PM.StartFrame + i
+			byFrame[frame] = append(byFrame[frame], PlayerMovementInfo{
+				PM.SteamID,
+				FixedVector3{int32(PM.PositionX[i]), int32(PM.PositionY[i]), int32(PM.PositionZ[i])},
+				AngleDegrees(PM.ViewX[i], PM.ViewPrecision),
+				AngleDegrees(PM.ViewY[i], PM.ViewPrecision),
+				nil,
+				"",
+			})
+		}
+	}
+
+	frames := make([]int, 0, len(byFrame))
+	for frame := range byFrame {
+		frames = append(frames, frame)
+	}
+	sort.Ints(frames)
+
+	result := make([]FramePositions, 0, len(frames))
+	for _, frame := range frames {
+		positions := byFrame[frame]
+		sort.Slice(positions, func(i, j int) bool { return positions[i].SteamID < positions[j].SteamID })
+		result = append(result, FramePositions{frame, positions})
+	}
+	return result, nil
+}
+
+// GrenadeFrames puts decoded grenade movements together into per frame positions,
+// the shape positions are stored in without Elias encoding. Grenades are sorted by UniqueID.
+func GrenadeFrames(GPIEs []GrenadePositionInfoEncoded) ([]FrameProjectiles, error) {
+	byFrame := make(map[int][]GrenadePositionInfo)
+	for _, GPIE := range GPIEs {
+		GM, err := DecodeGrenadeMovement(GPIE)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < minLength(GM.PositionX, GM.PositionY, GM.PositionZ); i++ {
+			frame := GM.StartFrame + i
+			byFrame[frame] = append(byFrame[frame], GrenadePositionInfo{
+				GPIE.UniqueID,
+				FixedVector3{int32(GM.PositionX[i]), int32(GM.PositionY[i]), int32(GM.PositionZ[i])},
+				nil,
+			})
+		}
+	}
+
+	frames := make([]int, 0, len(byFrame))
+	for frame := range byFrame {
+		frames = append(frames, frame)
+	}
+	sort.Ints(frames)
+
+	result := make([]FrameProjectiles, 0, len(frames))
+	for _, frame := range frames {
+		positions := byFrame[frame]
+		sort.Slice(positions, func(i, j int) bool { return positions[i].UniqueID < positions[j].UniqueID })
+		result = append(result, FrameProjectiles{frame, positions})
+	}
+	return result, nil
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"csgo-parser-mongodb/util/codec"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"testing"
+)
+
+var testCodecs = []StreamCodecs{
+	DefaultStreamCodecs,
+	{codec.Rice, codec.Varint, codec.Delta, 2, 1, 2, 2, DefaultKeyframeInterval},
+	{codec.EliasFano, codec.Delta, codec.Gamma, 0, 2, 1, 8, 2},
+	{codec.Gamma, codec.Rice, codec.Rice, 1, 2, 2, 4, 1},
+}
+
+type sample struct {
+	SteamID int64
+	X, Y, Z int
+	VX, VY  float32
+}
+
+func samplesToFrames(samples map[int][]sample, precision int) []FramePositions {
+	var frames []FramePositions
+	for frame := 1; frame <= len(samples); frame++ {
+		FP := FramePositions{FrameNumber: frame}
+		for _, s := range samples[frame] {
+			FP.PlayersPositions = append(FP.PlayersPositions, PlayerMovementInfo{
+				s.SteamID, FixedVector3{int32(s.X), int32(s.Y), int32(s.Z)}, AngleDegrees(FixedAngle(s.VX, precision), precision), AngleDegrees(FixedAngle(s.VY, precision), precision), nil, "",
+			})
+		}
+		frames = append(frames, FP)
+	}
+	return frames
+}
+
+func TestPlayerMovementRoundTrip(t *testing.T) {
+	// frames 1..6, player 2 dies after frame 3
+	samples := map[int][]sample{
+		1: {{1, -1200, 300, 16, 359.75, 0}, {2, 40, 40, -8, 90, 350}},
+		2: {{1, -1190, 301, 16, 0.5, 2.25}, {2, 41, 38, -8, 92, 359.5}},
+		3: {{1, -1180, 305, 17, 3, 5}, {2, 45, 30, -7, 120, 0.125}},
+		4: {{1, -1170, 310, 17, 10, 8}},
+		5: {{1, -1170, 310, 17, 10, 8}},
+		6: {{1, -1100, 290, 40, 180, 89}},
+	}
+
+	for _, codecs := range testCodecs {
+		movements := map[int64]*PlayerMovement{}
+		for frame := 1; frame <= len(samples); frame++ {
+			for _, s := range samples[frame] {
+				PM, ok := movements[s.SteamID]
+				if !ok {
+					PM = &PlayerMovement{StartFrame: frame}
+					movements[s.SteamID] = PM
+				}
+				PM.PositionX = append(PM.PositionX, s.X)
+				PM.PositionY = append(PM.PositionY, s.Y)
+				PM.PositionZ = append(PM.PositionZ, s.Z)
+				PM.ViewX = append(PM.ViewX, FixedAngle(s.VX, codecs.ViewPrecision))
+				PM.ViewY = append(PM.ViewY, FixedAngle(s.VY, codecs.ViewPrecision))
+			}
+		}
+
+		RM := RoundMovement{1, []PlayerMovementInfoEncoded{
+			NewPlayerMovementInfoEncoded(2, movements[2], 3, codecs),
+			NewPlayerMovementInfoEncoded(1, movements[1], 6, codecs),
+		}}
+		// as stored in and read back from MongoDB
+		data, err := bson.Marshal(RM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		RM = RoundMovement{}
+		if err := bson.Unmarshal(data, &RM); err != nil {
+			t.Fatal(err)
+		}
+		res, err := RoundMovementFrames(RM)
+		expected := samplesToFrames(samples, codecs.ViewPrecision)
+		if err != nil || !reflect.DeepEqual(res, expected) {
+			t.Error("RoundMovementFrames failed with ", codecs, ", got ", res, err, " instead of ", expected)
+		}
+
+		for _, PMIE := range RM.PlayerMovements {
+			for frame := PMIE.StartFrame; frame < PMIE.StartFrame+PMIE.Samples; frame++ {
+				PMI, ok, err := PlayerMovementAt(PMIE, frame)
+				found := false
+				for _, e := range expected[frame-1].PlayersPositions {
+					found = found || reflect.DeepEqual(e, PMI)
+				}
+				if err != nil || !ok || !found {
+					t.Error("PlayerMovementAt failed with ", codecs, " in frame ", frame, ", got ", PMI, err)
+				}
+			}
+			if _, ok, err := PlayerMovementAt(PMIE, PMIE.StartFrame+PMIE.Samples); ok || err != nil {
+				t.Error("PlayerMovementAt found a sample past the last one with ", codecs)
+			}
+		}
+	}
+}
+
+func TestEncodePlayerMovementReset(t *testing.T) {
+	app := Application{codecs: DefaultStreamCodecs}
+	PM := &PlayerMovement{StartFrame: 1}
+	for round := 0; round < 3; round++ {
+		if round > 0 {
+			PM.StartFrame = app.savedFrameNumber + 1
+		}
+		var expected []int
+		for i := 0; i < 5; i++ {
+			app.savedFrameNumber++
+			v := round*100 + i
+			expected = append(expected, v)
+			PM.PositionX = append(PM.PositionX, v)
+			PM.PositionY = append(PM.PositionY, -v)
+			PM.PositionZ = append(PM.PositionZ, 2*v)
+			PM.ViewX = append(PM.ViewX, v%360)
+			PM.ViewY = append(PM.ViewY, 3)
+		}
+		decoded, err := DecodePlayerMovement(app.encodePlayerMovement(7, PM, true))
+		if err != nil || !reflect.DeepEqual(decoded.PositionX, expected) || decoded.PositionZ[4] != 2*expected[4] || decoded.ViewY[0] != 3 {
+			t.Errorf("round %d decoded wrong: %v", round, decoded)
+		}
+		if PM.StartFrame != 0 || len(PM.PositionX) != 0 || len(PM.ViewX) != 0 {
+			t.Errorf("round %d movement wasn't reset: %v", round, *PM)
+		}
+	}
+}
+
+func TestGrenadeMovementRoundTrip(t *testing.T) {
+	GMs := map[int64]*GrenadeMovement{
+		11: {3, []int{0, 10, 20}, []int{5, 5, 6}, []int{64, 80, 70}},
+		12: {4, []int{-300, -310}, []int{900, 905}, []int{0, 3}},
+	}
+	var encoded []GrenadePositionInfoEncoded
+	for id, GM := range GMs {
+		encoded = append(encoded, NewGrenadePositionInfoEncoded(id, GM, StreamCodecs{Grenade: codec.Rice, GrenadeOrder: 2}))
+	}
+	expected := []FrameProjectiles{
+		{3, []GrenadePositionInfo{{11, FixedVector3{0, 5, 64}, nil}}},
+		{4, []GrenadePositionInfo{{11, FixedVector3{10, 5, 80}, nil}, {12, FixedVector3{-300, 900, 0}, nil}}},
+		{5, []GrenadePositionInfo{{11, FixedVector3{20, 6, 70}, nil}, {12, FixedVector3{-310, 905, 3}, nil}}},
+	}
+	if res, err := GrenadeFrames(encoded); err != nil || !reflect.DeepEqual(res, expected) {
+		t.Error("GrenadeFrames failed, got ", res, err, " instead of ", expected)
+	}
+
+	keyframed := NewGrenadePositionInfoEncoded(11, GMs[11], StreamCodecs{Grenade: codec.Delta, GrenadeOrder: 2, KeyframeInterval: 2})
+	if GPI, err := GrenadePositionAt(keyframed, 5); err != nil || !reflect.DeepEqual(GPI, expected[2].GrenadesPositions[0]) {
+		t.Error("GrenadePositionAt failed, got ", GPI, err, " instead of ", expected[2].GrenadesPositions[0])
+	}
+	if _, err := GrenadePositionAt(keyframed, 2); err == nil {
+		t.Error("GrenadePositionAt didn't fail before the first sample")
+	}
+	keyframed.YKeyframes = keyframed.YKeyframes[:1]
+	if _, err := DecodeGrenadeMovement(keyframed); err == nil {
+		t.Error("DecodeGrenadeMovement didn't fail on missing keyframes")
+	}
+
+	// documents stored before codecs were recorded are gamma coded and have no number of samples
+	legacy := NewGrenadePositionInfoEncoded(11, GMs[11], DefaultStreamCodecs)
+	legacy.Samples = 0
+	if GM, err := DecodeGrenadeMovement(legacy); err != nil || !reflect.DeepEqual(GM, *GMs[11]) {
+		t.Error("DecodeGrenadeMovement failed on a legacy document, got ", GM, err, " instead of ", *GMs[11])
+	}
+
+	empty := NewGrenadePositionInfoEncoded(13, &GrenadeMovement{StartFrame: 6}, StreamCodecs{Grenade: codec.Rice})
+	if GM, err := DecodeGrenadeMovement(empty); err != nil || len(GM.PositionX) != 0 {
+		t.Error("DecodeGrenadeMovement failed on an empty rice document, got ", GM, err)
+	}
+
+	// rice streams can't be decoded up to the end, as the zeros padding them read as further values
+	uncounted := NewGrenadePositionInfoEncoded(11, GMs[11], StreamCodecs{Grenade: codec.Rice})
+	uncounted.Samples = -1
+	if _, err := DecodeGrenadeMovement(uncounted); err == nil {
+		t.Error("DecodeGrenadeMovement didn't fail on a rice document without its number of samples")
+	}
+
+	unknown := NewGrenadePositionInfoEncoded(11, GMs[11], DefaultStreamCodecs)
+	unknown.Codec = codec.ID(100)
+	if _, err := GrenadeFrames([]GrenadePositionInfoEncoded{unknown}); err == nil {
+		t.Error("GrenadeFrames didn't fail on an unknown codec")
+	}
+	unknown.Codec, unknown.Order = codec.Gamma, 3
+	if _, err := GrenadeFrames([]GrenadePositionInfoEncoded{unknown}); err == nil {
+		t.Error("GrenadeFrames didn't fail on an unknown prediction order")
+	}
+}
+
+func TestViewAngleWraparound(t *testing.T) {
+	// turning right across 0 and looking up across the horizon
+	PM := &PlayerMovement{
+		StartFrame:	1,
+		PositionX:	[]int{0, 0, 0, 0, 0, 0},
+		PositionY:	[]int{0, 0, 0, 0, 0, 0},
+		PositionZ:	[]int{0, 0, 0, 0, 0, 0},
+		ViewX:		[]int{356, 358, 0, 2, 4, 6},
+		ViewY:		[]int{4, 2, 0, 358, 356, 354},
+	}
+	PMIE := NewPlayerMovementInfoEncoded(1, PM, 6, DefaultStreamCodecs)
+	// the angles change by 2 degrees every frame, which takes 5 bits a delta
+	if bits := PMIE.ViewX.Len() + PMIE.ViewY.Len(); bits > 2*(17+5*5) {
+		t.Error("view angles turning across 0 take ", bits, " bits")
+	}
+	decoded, err := DecodePlayerMovement(PMIE)
+	if err != nil || !reflect.DeepEqual(decoded.ViewX, PM.ViewX) || !reflect.DeepEqual(decoded.ViewY, PM.ViewY) {
+		t.Error("view angles failed, got ", decoded.ViewX, decoded.ViewY, err, " instead of ", PM.ViewX, PM.ViewY)
+	}
+}
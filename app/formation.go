@@ -0,0 +1,148 @@
+package app
+
+import (
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"math"
+)
+
+// TeamMetrics is how the alive players of a team stand: where they are as a group, how far apart
+// and how much of the map they control.
+type TeamMetrics struct {
+	Team			common.Team		`bson:"Team"`
+	Alive			int				`bson:"Alive"`
+	// Centroid is the mean position of the alive players, the origin if none is
+	Centroid		FixedVector3	`bson:"Centroid"`
+	// MeanDistance is the mean distance between two alive players of the team, 0 with fewer than two alive
+	MeanDistance	float64			`bson:"MeanDistance"`
+	// SpreadX and SpreadY are the standard deviations of the positions along the axes of the map
+	SpreadX			float64			`bson:"SpreadX"`
+	SpreadY			float64			`bson:"SpreadY"`
+	// Territory is the share of the map nearer to a player of the team than to any other player alive, seen
+	// from above: the Voronoi cells of its players clipped to the bounds of the radar image.
+	// -1 if the parser had no overview of the map.
+	Territory		float64			`bson:"Territory"`
+}
+
+// FrameTeamMetrics is the TeamMetrics of both teams in a saved frame, the terrorists first.
+type FrameTeamMetrics struct {
+	FrameNumber	int				`bson:"FrameNumber"`
+	Tick		int				`bson:"Tick"`
+	RoundNumber	int				`bson:"RoundNumber"`
+	Teams		[]TeamMetrics	`bson:"Teams"`
+}
+
+// teamMetricsInterval is how many seconds of game time apart team metrics are stored.
+const teamMetricsInterval = 1.0
+
+// metricsTeams are the teams TeamMetrics are taken of, in the order they are stored.
+var metricsTeams = []common.Team{common.TeamTerrorists, common.TeamCounterTerrorists}
+
+// NewTeamMetrics measures both teams from the positions of their alive players. bounds is the area of the map
+// as a convex polygon, counterclockwise, nil if it isn't known.
+func NewTeamMetrics(positions map[common.Team][]r3.Vector, bounds []r2.Point, precision int) ([]TeamMetrics, error) {
+	var sites []r2.Point
+	var owners []common.Team
+	for _, team := range metricsTeams {
+		for _, v := range positions[team] {
+			sites = append(sites, r2.Point{X: v.X, Y: v.Y})
+			owners = append(owners, team)
+		}
+	}
+	territory := make(map[common.Team]float64, len(metricsTeams))
+	if len(bounds) > 0 {
+		total, _ := hullAreaCentroid(bounds)
+		for i, cell := range VoronoiCells(sites, bounds) {
+			if len(cell) > 0 && total > 0 {
+				area, _ := hullAreaCentroid(cell)
+				territory[owners[i]] += area / total
+			}
+		}
+	}
+
+	res := make([]TeamMetrics, len(metricsTeams))
+	for i, team := range metricsTeams {
+		players := positions[team]
+		TM := TeamMetrics{Team: team, Alive: len(players), Territory: -1}
+		if len(bounds) > 0 {
+			TM.Territory = territory[team]
+		}
+		var centroid r3.Vector
+		for _, v := range players {
+			centroid = centroid.Add(v)
+		}
+		if len(players) > 0 {
+			centroid = centroid.Mul(1 / float64(len(players)))
+			for _, v := range players {
+				TM.SpreadX += (v.X - centroid.X) * (v.X - centroid.X)
+				TM.SpreadY += (v.Y - centroid.Y) * (v.Y - centroid.Y)
+			}
+			TM.SpreadX = math.Sqrt(TM.SpreadX / float64(len(players)))
+			TM.SpreadY = math.Sqrt(TM.SpreadY / float64(len(players)))
+		}
+		var err error
+		if TM.Centroid, err = NewFixedVector3(centroid, precision); err != nil {
+			return nil, err
+		}
+		if pairs := len(players) * (len(players) - 1) / 2; pairs > 0 {
+			for a := range players {
+				for b := a + 1; b < len(players); b++ {
+					TM.MeanDistance += players[a].Sub(players[b]).Norm()
+				}
+			}
+			TM.MeanDistance /= float64(pairs)
+		}
+		res[i] = TM
+	}
+	return res, nil
+}
+
+// VoronoiCells returns the cell of every site: the part of bounds, a convex polygon, nearer to it than to
+// any other site, counterclockwise if bounds is. Of sites at the same point the first one gets the cell
+// and the others an empty one.
+func VoronoiCells(sites []r2.Point, bounds []r2.Point) [][]r2.Point {
+	res := make([][]r2.Point, len(sites))
+	for i, s := range sites {
+		cell := bounds
+		for j, o := range sites {
+			if i == j {
+				continue
+			}
+			if s == o {
+				if j < i {
+					cell = nil
+					break
+				}
+				continue
+			}
+			// keeps the side of the perpendicular bisector s is on
+			normal := o.Sub(s)
+			cell = clipHalfPlane(cell, normal, normal.Dot(s.Add(o).Mul(0.5)))
+			if len(cell) == 0 {
+				break
+			}
+		}
+		res[i] = cell
+	}
+	return res
+}
+
+// clipHalfPlane cuts a convex polygon to the half-plane of the points p with normal·p <= offset.
+func clipHalfPlane(polygon []r2.Point, normal r2.Point, offset float64) []r2.Point {
+	var res []r2.Point
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		dp, dq := normal.Dot(p)-offset, normal.Dot(q)-offset
+		if dp <= 0 {
+			res = append(res, p)
+		}
+		if dp < 0 && dq > 0 || dp > 0 && dq < 0 {
+			res = append(res, p.Add(q.Sub(p).Mul(dp/(dp-dq))))
+		}
+	}
+	if len(res) < 3 {
+		return nil
+	}
+	return res
+}
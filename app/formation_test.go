@@ -0,0 +1,60 @@
+package app
+
+import (
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"math"
+	"testing"
+)
+
+func TestVoronoiCells(t *testing.T) {
+	bounds := []r2.Point{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 100}, {X: 0, Y: 100}}
+	cells := VoronoiCells([]r2.Point{{X: 25, Y: 50}, {X: 75, Y: 50}, {X: 75, Y: 50}}, bounds)
+	var areas []float64
+	for _, c := range cells {
+		area := 0.0
+		if len(c) > 0 {
+			area, _ = hullAreaCentroid(c)
+		}
+		areas = append(areas, area)
+	}
+	if len(areas) != 3 || math.Abs(areas[0]-5000) > 1e-9 || math.Abs(areas[1]-5000) > 1e-9 || areas[2] != 0 {
+		t.Error("VoronoiCells failed, got areas ", areas, " instead of [5000 5000 0]")
+	}
+	if cells := VoronoiCells([]r2.Point{{X: 500, Y: 500}}, bounds); len(cells) != 1 || len(cells[0]) != 4 {
+		t.Error("VoronoiCells failed on a site out of bounds, got ", cells)
+	}
+}
+
+func TestNewTeamMetrics(t *testing.T) {
+	bounds := []r2.Point{{X: 0, Y: 0}, {X: 400, Y: 0}, {X: 400, Y: 100}, {X: 0, Y: 100}}
+	positions := map[common.Team][]r3.Vector{
+		common.TeamTerrorists:        {{X: 50, Y: 50}},
+		common.TeamCounterTerrorists: {{X: 200, Y: 20, Z: 8}, {X: 300, Y: 80}},
+	}
+	res, err := NewTeamMetrics(positions, bounds, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].Team != common.TeamTerrorists || res[1].Team != common.TeamCounterTerrorists {
+		t.Fatal("NewTeamMetrics failed to order the teams, got ", res)
+	}
+	T, CT := res[0], res[1]
+	if T.Alive != 1 || T.MeanDistance != 0 || T.SpreadX != 0 || T.Centroid != (FixedVector3{50, 50, 0}) {
+		t.Error("NewTeamMetrics failed on a lone player, got ", T)
+	}
+	if CT.Alive != 2 || math.Abs(CT.MeanDistance-math.Sqrt(100*100+60*60+8*8)) > 1e-9 ||
+		CT.SpreadX != 50 || CT.SpreadY != 30 || CT.Centroid != (FixedVector3{250, 50, 4}) {
+		t.Error("NewTeamMetrics failed, got ", CT)
+	}
+	// the terrorist is nearest to what is left of x = 118 + y/5, the bisector with the first counter-terrorist
+	if math.Abs(T.Territory-0.32) > 1e-9 || math.Abs(CT.Territory-0.68) > 1e-9 {
+		t.Error("NewTeamMetrics failed to share the territory, got ", T.Territory, " and ", CT.Territory)
+	}
+
+	res, err = NewTeamMetrics(map[common.Team][]r3.Vector{}, nil, 0)
+	if err != nil || len(res) != 2 || res[0].Territory != -1 || res[1].Alive != 0 {
+		t.Error("NewTeamMetrics failed without players and bounds, got ", res, err)
+	}
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GameStateDiff is a game state snapshot stored as the changes since the snapshot before it,
+// which may itself be a diff. Snapshots are stored in full every so many, see the -gamestatekeyframes flag,
+// so reading a match from any full snapshot on restores every one after it.
+type GameStateDiff struct {
+	FrameNumber	int					`bson:"FrameNumber"`
+	Tick		int					`bson:"Tick"`
+	RoundNumber	int					`bson:"RoundNumber"`
+	Changed		[]PlayerStateDiff	`bson:"Changed"`
+	// SteamIDs of the players who were in the snapshot before and are no longer playing
+	Left		[]int64				`bson:"Left"`
+}
+
+// PlayerStateDiff holds the fields of a PlayerStateInfo that changed, with their new values
+// under the same keys. The fields of a player who wasn't in the snapshot before are all there.
+type PlayerStateDiff struct {
+	SteamID	int64		`bson:"SteamID"`
+	Fields	bson.Raw	`bson:"Fields"`
+}
+
+// DiffGameStates returns the changes from prev to cur. Players are matched up by SteamID,
+// which bots don't have, so snapshots with more than one player of the same SteamID can't be diffed
+// and false is returned for them.
+func DiffGameStates(prev, cur GameStateInfo) (GameStateDiff, bool, error) {
+	diff := GameStateDiff{FrameNumber: cur.FrameNumber, Tick: cur.Tick, RoundNumber: cur.RoundNumber}
+	before, ok := playersBySteamID(prev.Players)
+	if !ok {
+		return GameStateDiff{}, false, nil
+	}
+	after, ok := playersBySteamID(cur.Players)
+	if !ok {
+		return GameStateDiff{}, false, nil
+	}
+
+	for _, PSI := range cur.Players {
+		fields, err := changedFields(before[PSI.SteamID], PSI)
+		if err != nil {
+			return GameStateDiff{}, false, err
+		}
+		if fields != nil {
+			diff.Changed = append(diff.Changed, PlayerStateDiff{PSI.SteamID, fields})
+		}
+	}
+	for _, PSI := range prev.Players {
+		if _, ok := after[PSI.SteamID]; !ok {
+			diff.Left = append(diff.Left, PSI.SteamID)
+		}
+	}
+	return diff, true, nil
+}
+
+// Apply restores the snapshot diff was made from prev to. Players keep the order they had in prev,
+// players who joined follow in the order they were diffed in.
+func (diff GameStateDiff) Apply(prev GameStateInfo) (GameStateInfo, error) {
+	GSI := GameStateInfo{diff.FrameNumber, diff.Tick, diff.RoundNumber, make([]PlayerStateInfo, 0, len(prev.Players))}
+	changed := make(map[int64]bson.Raw, len(diff.Changed))
+	for _, PSD := range diff.Changed {
+		changed[PSD.SteamID] = PSD.Fields
+	}
+	left := make(map[int64]bool, len(diff.Left))
+	for _, SteamID := range diff.Left {
+		left[SteamID] = true
+	}
+
+	for _, PSI := range prev.Players {
+		if left[PSI.SteamID] {
+			continue
+		}
+		if fields, ok := changed[PSI.SteamID]; ok {
+			var err error
+			if PSI, err = applyFields(&PSI, fields); err != nil {
+				return GameStateInfo{}, fmt.Errorf("game state in frame %d: %v", diff.FrameNumber, err)
+			}
+			delete(changed, PSI.SteamID)
+		}
+		GSI.Players = append(GSI.Players, PSI)
+	}
+	for _, PSD := range diff.Changed {
+		if _, ok := changed[PSD.SteamID]; !ok {
+			continue
+		}
+		PSI, err := applyFields(nil, PSD.Fields)
+		if err != nil {
+			return GameStateInfo{}, fmt.Errorf("game state in frame %d: %v", diff.FrameNumber, err)
+		}
+		GSI.Players = append(GSI.Players, PSI)
+	}
+	return GSI, nil
+}
+
+func playersBySteamID(players []PlayerStateInfo) (map[int64]*PlayerStateInfo, bool) {
+	bySteamID := make(map[int64]*PlayerStateInfo, len(players))
+	for i := range players {
+		if _, ok := bySteamID[players[i].SteamID]; ok {
+			return nil, false
+		}
+		bySteamID[players[i].SteamID] = &players[i]
+	}
+	return bySteamID, true
+}
+
+// changedFields returns the fields of cur that differ from prev as a document, nil if none do.
+// Every field is returned if there is no prev.
+func changedFields(prev *PlayerStateInfo, cur PlayerStateInfo) (bson.Raw, error) {
+	after, err := bson.Marshal(cur)
+	if err != nil {
+		return nil, err
+	}
+	if prev == nil {
+		return after, nil
+	}
+	before, err := bson.Marshal(*prev)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := bson.Raw(after).Elements()
+	if err != nil {
+		return nil, err
+	}
+
+	var fields bson.D
+	for _, e := range elements {
+		old, err := bson.Raw(before).LookupErr(e.Key())
+		if err == nil && old.Type == e.Value().Type && bytes.Equal(old.Value, e.Value().Value) {
+			continue
+		}
+		fields = append(fields, bson.E{Key: e.Key(), Value: e.Value()})
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return bson.Marshal(fields)
+}
+
+// applyFields returns prev with the given fields replaced, or the player the fields describe if there is no prev.
+func applyFields(prev *PlayerStateInfo, fields bson.Raw) (PlayerStateInfo, error) {
+	var PSI PlayerStateInfo
+	if prev == nil {
+		err := bson.Unmarshal(fields, &PSI)
+		return PSI, err
+	}
+	before, err := bson.Marshal(*prev)
+	if err != nil {
+		return PlayerStateInfo{}, err
+	}
+	elements, err := bson.Raw(before).Elements()
+	if err != nil {
+		return PlayerStateInfo{}, err
+	}
+
+	var doc bson.D
+	for _, e := range elements {
+		value := e.Value()
+		if changed, err := fields.LookupErr(e.Key()); err == nil {
+			value = changed
+		}
+		doc = append(doc, bson.E{Key: e.Key(), Value: value})
+	}
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return PlayerStateInfo{}, err
+	}
+	err = bson.Unmarshal(data, &PSI)
+	return PSI, err
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"testing"
+)
+
+func TestGameStateDiff(t *testing.T) {
+	alice := PlayerStateInfo{SteamID: 1, Hp: 100, Money: 800, IsAlive: true,
+		Inventory: []EquipmentInfo{{UniqueID: 5, OwnerID: 1, AmmoInMagazine: 20}}}
+	bob := PlayerStateInfo{SteamID: 2, Hp: 100, Armor: 100, LastAlivePosition: FixedVector3{1, 2, 3}}
+	carol := PlayerStateInfo{SteamID: 3, Hp: 90, Inventory: []EquipmentInfo{}}
+
+	hurt := alice
+	hurt.Hp, hurt.Inventory = 73, []EquipmentInfo{{UniqueID: 5, OwnerID: 1, AmmoInMagazine: 12}}
+	moved := bob
+	moved.LastAlivePosition.Z = -4
+
+	states := []GameStateInfo{
+		{10, 640, 2, []PlayerStateInfo{alice, bob}},
+		{11, 704, 2, []PlayerStateInfo{hurt, bob}},
+		{12, 768, 2, []PlayerStateInfo{moved, carol, hurt}},
+		{13, 832, 3, []PlayerStateInfo{carol}},
+		{14, 896, 3, []PlayerStateInfo{carol}},
+	}
+	for i := 1; i < len(states); i++ {
+		diff, ok, err := DiffGameStates(states[i-1], states[i])
+		if !ok || err != nil {
+			t.Fatal("DiffGameStates failed on state ", i, ": ", ok, err)
+		}
+		// as stored in and read back from MongoDB
+		data, err := bson.Marshal(diff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff = GameStateDiff{}
+		if err := bson.Unmarshal(data, &diff); err != nil {
+			t.Fatal(err)
+		}
+		res, err := diff.Apply(states[i-1])
+		players := map[int64]PlayerStateInfo{}
+		for _, PSI := range res.Players {
+			players[PSI.SteamID] = PSI
+		}
+		expected := map[int64]PlayerStateInfo{}
+		for _, PSI := range states[i].Players {
+			expected[PSI.SteamID] = PSI
+		}
+		if err != nil || res.FrameNumber != states[i].FrameNumber || res.Tick != states[i].Tick || res.RoundNumber != states[i].RoundNumber ||
+			!reflect.DeepEqual(players, expected) {
+			t.Error("Apply failed on state ", i, ", got ", res, err, " instead of ", states[i])
+		}
+	}
+
+	diff, _, _ := DiffGameStates(states[0], states[1])
+	if len(diff.Changed) != 1 || len(diff.Left) != 0 {
+		t.Fatal("DiffGameStates stored unchanged players, got ", diff)
+	}
+	if keys, _ := diff.Changed[0].Fields.Elements(); len(keys) != 2 {
+		t.Error("DiffGameStates stored unchanged fields, got ", diff.Changed[0].Fields)
+	}
+	if diff, _, _ := DiffGameStates(states[3], states[4]); len(diff.Changed) != 0 || len(diff.Left) != 0 {
+		t.Error("DiffGameStates found changes between equal states, got ", diff)
+	}
+
+	bots := GameStateInfo{Players: []PlayerStateInfo{{SteamID: -1}, {SteamID: -1}}}
+	if _, ok, _ := DiffGameStates(states[0], bots); ok {
+		t.Error("DiffGameStates diffed players of the same SteamID")
+	}
+}
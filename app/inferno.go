@@ -0,0 +1,160 @@
+package app
+
+import (
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"math"
+)
+
+// InfernoLifecycle sums up the fire of a molotov or incendiary from when it started burning to when it went out,
+// so it can be judged without going through its convex hulls frame by frame.
+type InfernoLifecycle struct {
+	UniqueID	int64					`bson:"UniqueID"`
+	RoundNumber	int						`bson:"RoundNumber"`
+	StartFrame	int						`bson:"StartFrame"`
+	StartTick	int						`bson:"StartTick"`
+	// -1 if the demo ended before the fire went out
+	ExpireFrame	int						`bson:"ExpireFrame"`
+	ExpireTick	int						`bson:"ExpireTick"`
+	// the player who threw the grenade, -1 and EqUnknown if no detonation could be matched to the fire
+	Thrower		int64					`bson:"Thrower"`
+	Weapon		common.EquipmentElement	`bson:"Weapon"`
+	// MaxArea is the largest area of the sampled convex hulls in square units, AreaTime the area
+	// integrated over the time the fire burned in square units times seconds
+	MaxArea		float64					`bson:"MaxArea"`
+	AreaTime	float64					`bson:"AreaTime"`
+	// Centroid is where the fire burned, the centroids of the hulls weighted by their area and time
+	Centroid	FixedVector3			`bson:"Centroid"`
+	// place the centroid is in, "" if the parser had no zones of it
+	Callout		string					`bson:"Callout"`
+	// players the fire hurt, in the order they were first hurt
+	Damaged		[]InfernoDamage			`bson:"Damaged"`
+}
+
+// InfernoDamage is the health a fire took from a player.
+type InfernoDamage struct {
+	Player			int64	`bson:"Player"`
+	HealthDamage	int		`bson:"HealthDamage"`
+	Hits			int		`bson:"Hits"`
+}
+
+// fireGrenade is a molotov or incendiary that detonated and waits to be matched to the fire it started.
+type fireGrenade struct {
+	thrower		int64
+	weapon		common.EquipmentElement
+	position	r3.Vector
+}
+
+// infernoTracker puts an InfernoLifecycle together sample by sample.
+// Each sampled area counts for the time up to the next sample or the end of the fire.
+type infernoTracker struct {
+	lifecycle		InfernoLifecycle
+	matched			bool
+	lastTick		int
+	lastArea		float64
+	lastCentroid	r3.Vector
+	// sum of the centroids weighted by area and time
+	weighted		r3.Vector
+	damaged			map[int64]int
+}
+
+func newInfernoTracker(uniqueID int64, round, frame, tick int) *infernoTracker {
+	return &infernoTracker{
+		lifecycle: InfernoLifecycle{
+			UniqueID:		uniqueID,
+			RoundNumber:	round,
+			StartFrame:		frame,
+			StartTick:		tick,
+			ExpireFrame:	-1,
+			ExpireTick:		-1,
+			Thrower:		-1,
+		},
+		lastTick:	-1,
+		damaged:	make(map[int64]int),
+	}
+}
+
+// advance integrates the last sampled area up to tick, tickTime being the seconds a tick takes.
+func (t *infernoTracker) advance(tick int, tickTime float64) {
+	if t.lastTick >= 0 && tick > t.lastTick {
+		w := t.lastArea * float64(tick-t.lastTick) * tickTime
+		t.lifecycle.AreaTime += w
+		t.weighted = t.weighted.Add(t.lastCentroid.Mul(w))
+	}
+	t.lastTick = tick
+}
+
+// sample adds the hull the fire covered at a tick, with z the mean height of its fires.
+func (t *infernoTracker) sample(tick int, hull []r2.Point, z float64, tickTime float64) {
+	t.advance(tick, tickTime)
+	if len(hull) == 0 {
+		t.lastArea = 0
+		return
+	}
+	area, centroid := hullAreaCentroid(hull)
+	t.lastArea, t.lastCentroid = area, r3.Vector{X: centroid.X, Y: centroid.Y, Z: z}
+	t.lifecycle.MaxArea = math.Max(t.lifecycle.MaxArea, area)
+}
+
+// match takes the detonation nearest to where the fire burns as the grenade that started it
+// and returns the detonations left.
+func (t *infernoTracker) match(pending []fireGrenade) []fireGrenade {
+	if t.matched || len(pending) == 0 || t.lastTick < 0 {
+		return pending
+	}
+	best := 0
+	for i, g := range pending {
+		if g.position.Sub(t.lastCentroid).Norm() < pending[best].position.Sub(t.lastCentroid).Norm() {
+			best = i
+		}
+	}
+	t.lifecycle.Thrower, t.lifecycle.Weapon = pending[best].thrower, pending[best].weapon
+	t.matched = true
+	return append(pending[:best], pending[best+1:]...)
+}
+
+func (t *infernoTracker) hurt(player int64, damage int) {
+	i, ok := t.damaged[player]
+	if !ok {
+		i = len(t.lifecycle.Damaged)
+		t.damaged[player] = i
+		t.lifecycle.Damaged = append(t.lifecycle.Damaged, InfernoDamage{Player: player})
+	}
+	t.lifecycle.Damaged[i].HealthDamage += damage
+	t.lifecycle.Damaged[i].Hits++
+}
+
+// finish ends the fire at the given frame and tick, or at the last sample if frame is -1.
+func (t *infernoTracker) finish(frame, tick int, tickTime float64) {
+	if frame >= 0 {
+		t.advance(tick, tickTime)
+		t.lifecycle.ExpireFrame, t.lifecycle.ExpireTick = frame, tick
+	}
+}
+
+// centroid returns where the fire burned, the last sampled centroid if no time passed.
+func (t *infernoTracker) centroid() r3.Vector {
+	if t.lifecycle.AreaTime == 0 {
+		return t.lastCentroid
+	}
+	return t.weighted.Mul(1 / t.lifecycle.AreaTime)
+}
+
+// hullAreaCentroid returns the area and centroid of a polygon. Polygons without area have the mean of
+// their points as centroid.
+func hullAreaCentroid(hull []r2.Point) (float64, r2.Point) {
+	var area2 float64
+	var c, mean r2.Point
+	for i, p := range hull {
+		q := hull[(i+1)%len(hull)]
+		cross := p.Cross(q)
+		area2 += cross
+		c = c.Add(p.Add(q).Mul(cross))
+		mean = mean.Add(p)
+	}
+	if area2 == 0 {
+		return 0, mean.Mul(1 / float64(len(hull)))
+	}
+	return math.Abs(area2) / 2, c.Mul(1 / (3 * area2))
+}
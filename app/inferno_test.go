@@ -0,0 +1,80 @@
+package app
+
+import (
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestHullAreaCentroid(t *testing.T) {
+	area, centroid := hullAreaCentroid([]r2.Point{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 4, Y: 2}, {X: 0, Y: 2}})
+	if area != 8 || centroid != (r2.Point{X: 2, Y: 1}) {
+		t.Error("hullAreaCentroid failed, got ", area, centroid)
+	}
+	// clockwise
+	area, centroid = hullAreaCentroid([]r2.Point{{X: 0, Y: 0}, {X: 0, Y: 3}, {X: 3, Y: 0}})
+	if area != 4.5 || centroid != (r2.Point{X: 1, Y: 1}) {
+		t.Error("hullAreaCentroid failed on a clockwise triangle, got ", area, centroid)
+	}
+	area, centroid = hullAreaCentroid([]r2.Point{{X: 0, Y: 0}, {X: 2, Y: 2}})
+	if area != 0 || centroid != (r2.Point{X: 1, Y: 1}) {
+		t.Error("hullAreaCentroid failed on a line, got ", area, centroid)
+	}
+}
+
+func TestInfernoTracker(t *testing.T) {
+	square := func(x, side float64) []r2.Point {
+		return []r2.Point{{X: x, Y: 0}, {X: x + side, Y: 0}, {X: x + side, Y: side}, {X: x, Y: side}}
+	}
+	const tickTime = 1.0 / 64
+	tracker := newInfernoTracker(7, 3, 100, 6400)
+	tracker.sample(6400, square(0, 10), 5, tickTime)
+	pending := tracker.match([]fireGrenade{
+		{1, common.EqMolotov, r3.Vector{X: 500, Y: 500}},
+		{2, common.EqIncendiary, r3.Vector{X: 4, Y: 6}},
+	})
+	// a second later the fire spread to the right
+	tracker.sample(6464, square(0, 20), 5, tickTime)
+	tracker.hurt(9, 8)
+	tracker.hurt(8, 4)
+	tracker.hurt(9, 7)
+	tracker.finish(150, 6592, tickTime)
+
+	if len(pending) != 1 || pending[0].thrower != 1 {
+		t.Error("match failed to take the nearest detonation, left ", pending)
+	}
+	if res := tracker.match(pending); len(res) != 1 {
+		t.Error("match failed to keep a matched fire, left ", res)
+	}
+	expected := InfernoLifecycle{
+		UniqueID:    7,
+		RoundNumber: 3,
+		StartFrame:  100,
+		StartTick:   6400,
+		ExpireFrame: 150,
+		ExpireTick:  6592,
+		Thrower:     2,
+		Weapon:      common.EqIncendiary,
+		MaxArea:     400,
+		// 100 for a second and 400 for two seconds
+		AreaTime: 900,
+		Damaged:  []InfernoDamage{{9, 15, 2}, {8, 4, 1}},
+	}
+	if !reflect.DeepEqual(tracker.lifecycle, expected) {
+		t.Error("infernoTracker failed, got ", tracker.lifecycle, " instead of ", expected)
+	}
+	// (5, 5) weighted by 100 and (10, 10) by 800
+	if res, expected := tracker.centroid(), (r3.Vector{X: 85.0 / 9, Y: 85.0 / 9, Z: 5}); res.Sub(expected).Norm() > 1e-9 {
+		t.Error("centroid failed, got ", res, " instead of ", expected)
+	}
+
+	unfinished := newInfernoTracker(8, 3, 100, 6400)
+	unfinished.sample(6400, square(0, 10), 0, tickTime)
+	unfinished.finish(-1, -1, tickTime)
+	if l := unfinished.lifecycle; l.ExpireFrame != -1 || l.AreaTime != 0 || l.Thrower != -1 || math.Abs(unfinished.centroid().X-5) > 1e-9 {
+		t.Error("infernoTracker failed on a fire that didn't go out, got ", l)
+	}
+}
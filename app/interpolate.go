@@ -0,0 +1,105 @@
+package app
+
+import "sort"
+
+// angleDifference returns how far angle b is from a the short way around a full turn, in [-turn/2, turn/2).
+func angleDifference(a, b, turn int) int {
+	d := ((b-a)%turn + turn) % turn
+	if d >= turn/2 {
+		d -= turn
+	}
+	return d
+}
+
+// interpolate returns the value num/den of the way from a to b, rounded to the nearest.
+func interpolate(a, b, num, den int) int {
+	d := (b - a) * num
+	if d < 0 {
+		return a - (-d+den/2)/den
+	}
+	return a + (d+den/2)/den
+}
+
+// interpolateAngle interpolates between two angles the short way around a full turn, into [0, turn).
+func interpolateAngle(a, b, num, den, turn int) int {
+	v := interpolate(a, a+angleDifference(a, b, turn), num, den)
+	return (v%turn + turn) % turn
+}
+
+// locateFrame finds the samples a frame lies between, given the frames of the samples or, if frames is nil,
+// n samples taken at every frame from start on. It returns the index of the last sample in or before the frame
+// and how far the frame is on the way to the next one, as num/den; num is 0 for a sampled frame.
+// It reports false if the frame is before the first sample or after the last.
+func locateFrame(frames []int, start, n, frame int) (i, num, den int, ok bool) {
+	if frames == nil {
+		i = frame - start
+		return i, 0, 1, i >= 0 && i < n
+	}
+	i = sort.SearchInts(frames, frame+1) - 1
+	switch {
+	case i < 0:
+		return 0, 0, 1, false
+	case frames[i] == frame:
+		return i, 0, 1, true
+	case i+1 >= len(frames):
+		return 0, 0, 1, false
+	}
+	return i, frame - frames[i], frames[i+1] - frames[i], true
+}
+
+// At returns where the player is and looks at in the given frame, interpolated linearly between the samples
+// around it if it wasn't sampled. It reports false if the frame is outside the movement.
+func (PM PlayerMovement) At(frame int) (PlayerMovementInfo, bool) {
+	i, num, den, ok := locateFrame(PM.Frames, PM.StartFrame, minLength(PM.PositionX, PM.PositionY, PM.PositionZ, PM.ViewX, PM.ViewY), frame)
+	if !ok {
+		return PlayerMovementInfo{}, false
+	}
+	at := func(values []int) int {
+		if num == 0 {
+			return values[i]
+		}
+		return interpolate(values[i], values[i+1], num, den)
+	}
+	turn := fullTurn(PM.ViewPrecision)
+	angleAt := func(values []int) float32 {
+		if num == 0 {
+			return AngleDegrees(values[i], PM.ViewPrecision)
+		}
+		return AngleDegrees(interpolateAngle(values[i], values[i+1], num, den, turn), PM.ViewPrecision)
+	}
+	return PlayerMovementInfo{
+		PM.SteamID,
+		FixedVector3{int32(at(PM.PositionX)), int32(at(PM.PositionY)), int32(at(PM.PositionZ))},
+		angleAt(PM.ViewX),
+		angleAt(PM.ViewY),
+		nil,
+		"",
+	}, true
+}
+
+// Interpolated returns the movement with a sample in every frame from its first sample to its last,
+// the ones that weren't sampled interpolated linearly.
+func (PM PlayerMovement) Interpolated() PlayerMovement {
+	if len(PM.Frames) == 0 {
+		return PM
+	}
+	res := PlayerMovement{StartFrame: PM.Frames[0], EndFrame: PM.EndFrame, ViewPrecision: PM.ViewPrecision, SteamID: PM.SteamID}
+	turn := fullTurn(PM.ViewPrecision)
+	for frame := PM.Frames[0]; frame <= PM.Frames[len(PM.Frames)-1]; frame++ {
+		i, num, den, _ := locateFrame(PM.Frames, 0, 0, frame)
+		if num == 0 {
+			res.PositionX = append(res.PositionX, PM.PositionX[i])
+			res.PositionY = append(res.PositionY, PM.PositionY[i])
+			res.PositionZ = append(res.PositionZ, PM.PositionZ[i])
+			res.ViewX = append(res.ViewX, PM.ViewX[i])
+			res.ViewY = append(res.ViewY, PM.ViewY[i])
+			continue
+		}
+		res.PositionX = append(res.PositionX, interpolate(PM.PositionX[i], PM.PositionX[i+1], num, den))
+		res.PositionY = append(res.PositionY, interpolate(PM.PositionY[i], PM.PositionY[i+1], num, den))
+		res.PositionZ = append(res.PositionZ, interpolate(PM.PositionZ[i], PM.PositionZ[i+1], num, den))
+		res.ViewX = append(res.ViewX, interpolateAngle(PM.ViewX[i], PM.ViewX[i+1], num, den, turn))
+		res.ViewY = append(res.ViewY, interpolateAngle(PM.ViewY[i], PM.ViewY[i+1], num, den, turn))
+	}
+	return res
+}
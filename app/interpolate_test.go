@@ -0,0 +1,89 @@
+package app
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	for _, c := range []struct{ a, b, num, den, expected int }{
+		{0, 10, 1, 4, 3}, {0, 10, 3, 4, 8}, {10, 0, 1, 4, 7}, {-10, 10, 1, 2, 0}, {5, 5, 1, 3, 5}, {0, -10, 1, 4, -3},
+	} {
+		if res := interpolate(c.a, c.b, c.num, c.den); res != c.expected {
+			t.Error("interpolate failed on ", c, ", got ", res)
+		}
+	}
+	for _, c := range []struct{ a, b, num, den, expected int }{
+		{350, 10, 1, 2, 0}, {10, 350, 1, 4, 5}, {90, 270, 1, 2, 0}, {0, 0, 1, 2, 0}, {358, 2, 1, 4, 359},
+	} {
+		if res := interpolateAngle(c.a, c.b, c.num, c.den, 360); res != c.expected {
+			t.Error("interpolateAngle failed on ", c, ", got ", res)
+		}
+	}
+}
+
+func TestAdaptiveSampling(t *testing.T) {
+	app := Application{codecs: DefaultStreamCodecs, sampling: AdaptiveSampling{PositionThreshold: 5, ViewThreshold: 2, MaxInterval: 4}}
+	// standing still, walking off along x, turning across 0 and standing still again until killed;
+	// turning by no more than the threshold, as in frame 11, leaves the frame out
+	xs := []int{0, 0, 1, 0, 0, 0, 0, 10, 20, 30, 30, 30, 30, 30, 30}
+	yaws := []int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 358, 356, 356, 356, 356}
+	PM := &PlayerMovement{StartFrame: 1}
+	for i := range xs {
+		app.savedFrameNumber = i + 1
+		app.samplePlayer(PM, movementSample{app.savedFrameNumber, FixedVector3{int32(xs[i]), 0, 0}, yaws[i], 0})
+	}
+	if expected := []int{1, 5, 7, 8, 9, 10, 11, 12}; !reflect.DeepEqual(PM.Frames, expected) {
+		t.Error("samplePlayer failed, sampled frames ", PM.Frames, " instead of ", expected)
+	}
+
+	PMIE := app.encodePlayerMovement(1, PM, true)
+	if PMIE.Frames.IsZero() || PMIE.Samples != 9 {
+		t.Fatal("encodePlayerMovement didn't record the frames of ", PMIE.Samples, " samples")
+	}
+	decoded, err := DecodePlayerMovement(PMIE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{1, 5, 7, 8, 9, 10, 11, 12, 15}; !reflect.DeepEqual(decoded.Frames, expected) {
+		t.Error("DecodePlayerMovement failed, got frames ", decoded.Frames, " instead of ", expected)
+	}
+	interpolated := decoded.Interpolated()
+	if expected := []int{0, 0, 0, 0, 0, 0, 0, 10, 20, 30, 30, 30, 30, 30, 30}; interpolated.StartFrame != 1 || !reflect.DeepEqual(interpolated.PositionX, expected) {
+		t.Error("Interpolated failed, got ", interpolated.PositionX, " instead of ", expected)
+	}
+	if !reflect.DeepEqual(interpolated.ViewX, []int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 358, 356, 356, 356, 356}) {
+		t.Error("Interpolated failed on view angles, got ", interpolated.ViewX)
+	}
+
+	for frame := 1; frame <= 15; frame++ {
+		PMI, ok, err := PlayerMovementAt(PMIE, frame)
+		expected, _ := interpolated.At(frame)
+		if err != nil || !ok || !reflect.DeepEqual(PMI, expected) || math.Abs(float64(int(PMI.Position.X)-xs[frame-1])) > app.sampling.PositionThreshold {
+			t.Error("PlayerMovementAt failed in frame ", frame, ", got ", PMI, ok, err, " instead of ", expected)
+		}
+	}
+	if _, ok, err := PlayerMovementAt(PMIE, 16); ok || err != nil {
+		t.Error("PlayerMovementAt found a sample past the last one")
+	}
+	// keyframes point into the same gaps, which older readers decode whole
+	for _, interval := range []int{1, 2, 3, 8, 9, 64} {
+		codecs := DefaultStreamCodecs
+		codecs.KeyframeInterval = interval
+		keyframed := NewPlayerMovementInfoEncoded(1, &decoded, decoded.EndFrame, codecs)
+		if len(keyframed.FramesKeyframes) != (9+interval-1)/interval || !reflect.DeepEqual(keyframed.Frames, PMIE.Frames) {
+			t.Error("NewPlayerMovementInfoEncoded failed every ", interval, ", got frame keyframes ", keyframed.FramesKeyframes)
+		}
+		for frame := 0; frame <= 16; frame++ {
+			PMI, ok, err := PlayerMovementAt(keyframed, frame)
+			expected, expectedOk := interpolated.At(frame)
+			if err != nil || ok != expectedOk || !reflect.DeepEqual(PMI, expected) {
+				t.Error("PlayerMovementAt failed every ", interval, " in frame ", frame, ", got ", PMI, ok, err, " instead of ", expected)
+			}
+		}
+	}
+	if PM.skipped != nil || len(PM.Frames) != 0 {
+		t.Error("encodePlayerMovement didn't reset the movement")
+	}
+}
@@ -0,0 +1,451 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/geo/r3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration upgrades a database from schema version From to From+1.
+type Migration struct {
+	From        int
+	Description string
+	Apply       func(db *mongo.Database, collectionNames map[ClIndex]string) error
+}
+
+// migrations must stay sorted by From, one per schema version.
+var migrations = []Migration{
+	{1, "adds Tick and RoundNumber to events and game states, renames ViewXArray/ViewYArray to ViewX/ViewY", migrateV1ToV2},
+	{2, "resamples Elias encoded grenade trajectories to saved frames, marking them approximated", migrateV2ToV3},
+	{3, "records the prediction order of encoded streams", migrateV3ToV4},
+	{4, "rewrites encoded streams as BSON binaries", migrateV4ToV5},
+	{5, "stores the lifecycles of smokes and flags kills through smoke", migrateV5ToV6},
+}
+
+// DatabaseSchemaVersion reads the schema version stamped into the database's header document.
+func DatabaseSchemaVersion(db *mongo.Database, collectionNames map[ClIndex]string) (int, error) {
+	var header bson.M
+	err := db.Collection(collectionNames[ClHeader]).FindOne(context.TODO(), bson.M{}).Decode(&header)
+	if err == mongo.ErrNoDocuments {
+		return 0, fmt.Errorf("database %s has no header document", db.Name())
+	}
+	if err != nil {
+		return 0, err
+	}
+	switch v := header["SchemaVersion"].(type) {
+	case int32:
+		return int(v), nil
+	case int64:
+		return int(v), nil
+	case nil:
+		return legacySchemaVersion, nil
+	default:
+		return 0, fmt.Errorf("database %s has malformed SchemaVersion %v", db.Name(), v)
+	}
+}
+
+// Migrate upgrades the documents of a database produced by an older parser version to SchemaVersion
+// in place, one migration at a time. It returns the schema version the database had before.
+func Migrate(db *mongo.Database, collectionNames map[ClIndex]string) (int, error) {
+	from, err := DatabaseSchemaVersion(db, collectionNames)
+	if err != nil {
+		return 0, err
+	}
+	if from > SchemaVersion {
+		return from, fmt.Errorf("database %s has schema version %d, newer than supported %d", db.Name(), from, SchemaVersion)
+	}
+
+	for _, m := range migrations {
+		if m.From < from {
+			continue
+		}
+		dbgLog(fmt.Sprintf("%s: migrating from version %d: %s", db.Name(), m.From, m.Description))
+		if err := m.Apply(db, collectionNames); err != nil {
+			return from, fmt.Errorf("migration from version %d failed: %v", m.From, err)
+		}
+		// stamping after every step lets an interrupted migration be resumed
+		if err := stampSchemaVersion(db, collectionNames, m.From+1); err != nil {
+			return from, err
+		}
+	}
+
+	return from, nil
+}
+
+func stampSchemaVersion(db *mongo.Database, collectionNames map[ClIndex]string, version int) error {
+	update := bson.M{"$set": bson.M{"SchemaVersion": version}}
+	_, err := db.Collection(collectionNames[ClHeader]).UpdateMany(context.TODO(), bson.M{}, update)
+	if err != nil {
+		return err
+	}
+	_, err = db.Client().Database("meta_info").Collection(collectionNames[ClReplays]).
+		UpdateMany(context.TODO(), bson.M{"DBname": db.Name()}, update)
+	return err
+}
+
+// Version 1 databases have neither RoundStart nor RoundEnd events, so rounds are restored from
+// ScoreUpdated (round end) and RoundFreezetimeEnd events: a round begins right after the last
+// score update preceding its freeze time end. As the parser does, the frames before the first round
+// are round 0 and the rounds after are numbered from 1. Ticks cannot be restored and are set to -1.
+func migrateV1ToV2(db *mongo.Database, collectionNames map[ClIndex]string) error {
+	roundStarts, err := legacyRoundStarts(db.Collection(collectionNames[ClEvents]))
+	if err != nil {
+		return err
+	}
+
+	for _, cl := range []ClIndex{ClEvents, ClGameState} {
+		collection := db.Collection(collectionNames[cl])
+		// i is -1 for round 0, which ends where the first round starts
+		for i := -1; i < len(roundStarts); i++ {
+			frames := bson.M{}
+			if i >= 0 {
+				frames["$gte"] = roundStarts[i]
+			}
+			if i+1 < len(roundStarts) {
+				frames["$lt"] = roundStarts[i+1]
+			}
+			filter := bson.M{}
+			if len(frames) > 0 {
+				filter["FrameNumber"] = frames
+			}
+			_, err := collection.UpdateMany(context.TODO(), filter, bson.M{"$set": bson.M{"RoundNumber": i + 1, "Tick": -1}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Elias encoded movement is stored per round with the streams in an array, which $rename can't reach
+	positions := db.Collection(collectionNames[ClPositions])
+	cursor, err := positions.Find(context.TODO(), bson.M{"PlayerMovements.ViewXArray": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var doc bson.D
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		for i, e := range doc {
+			if e.Key != "PlayerMovements" {
+				continue
+			}
+			movements, ok := e.Value.(primitive.A)
+			if !ok {
+				continue
+			}
+			for j, m := range movements {
+				if movement, ok := m.(primitive.D); ok {
+					movements[j] = renameKeys(movement, map[string]string{"ViewXArray": "ViewX", "ViewYArray": "ViewY"})
+				}
+			}
+			doc[i].Value = movements
+		}
+		_, err := positions.ReplaceOne(context.TODO(), bson.M{"_id": idOf(doc)}, doc)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Version 2 encoded the grenades' Trajectory, which has a point per projectile update instead of
+// one per saved frame. The frames of the updates weren't stored, so the points are spread evenly over
+// the frames the grenade was flying and the trajectories are marked Approximated.
+func migrateV2ToV3(db *mongo.Database, collectionNames map[ClIndex]string) error {
+	projectiles := db.Collection(collectionNames[ClProjectiles])
+	cursor, err := projectiles.Find(context.TODO(), bson.M{"GrenadePositions": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var GPIE GrenadePositionInfoEncoded
+		if err := cursor.Decode(&GPIE); err != nil {
+			return err
+		}
+		trajectory, err := DecodeGrenadeMovement(GPIE)
+		if err != nil {
+			return err
+		}
+		points := minLength(trajectory.PositionX, trajectory.PositionY, trajectory.PositionZ)
+		if points == 0 {
+			continue
+		}
+		frames := GPIE.EndFrame - GPIE.StartFrame + 1
+		if frames < 1 {
+			frames = 1
+		}
+		resampled := GrenadeMovement{StartFrame: GPIE.StartFrame}
+		for f := 0; f < frames; f++ {
+			i := 0
+			if frames > 1 {
+				i = f * (points - 1) / (frames - 1)
+			}
+			resampled.PositionX = append(resampled.PositionX, trajectory.PositionX[i])
+			resampled.PositionY = append(resampled.PositionY, trajectory.PositionY[i])
+			resampled.PositionZ = append(resampled.PositionZ, trajectory.PositionZ[i])
+		}
+		GPIE = NewGrenadePositionInfoEncoded(GPIE.UniqueID, &resampled, DefaultStreamCodecs)
+		GPIE.Approximated = true
+		_, err = projectiles.ReplaceOne(context.TODO(), bson.M{"_id": cursor.Current.Lookup("_id")}, GPIE)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Version 4 lets encoded streams be stored with other prediction orders. Every older stream holds
+// deltas, which is order 1, while a missing order reads as 0.
+func migrateV3ToV4(db *mongo.Database, collectionNames map[ClIndex]string) error {
+	_, err := db.Collection(collectionNames[ClProjectiles]).UpdateMany(context.TODO(),
+		bson.M{"GrenadePositions": bson.M{"$exists": false}, "Order": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"Order": 1}})
+	if err != nil {
+		return err
+	}
+
+	positions := db.Collection(collectionNames[ClPositions])
+	cursor, err := positions.Find(context.TODO(), bson.M{"PlayerMovements.PositionOrder": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var doc bson.D
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		for i, e := range doc {
+			if e.Key != "PlayerMovements" {
+				continue
+			}
+			movements, ok := e.Value.(primitive.A)
+			if !ok {
+				continue
+			}
+			for j, m := range movements {
+				if movement, ok := m.(primitive.D); ok {
+					movements[j] = append(movement, primitive.E{Key: "PositionOrder", Value: 1}, primitive.E{Key: "ViewOrder", Value: 1})
+				}
+			}
+			doc[i].Value = movements
+		}
+		_, err := positions.ReplaceOne(context.TODO(), bson.M{"_id": idOf(doc)}, doc)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Version 5 stores encoded streams as BSON binaries instead of documents with an array of blocks.
+// Streams in the old layout can still be read, rewriting them only makes them smaller.
+func migrateV4ToV5(db *mongo.Database, collectionNames map[ClIndex]string) error {
+	positions := db.Collection(collectionNames[ClPositions])
+	filter := bson.M{"PlayerMovements.X": bson.M{"$type": "object"}}
+	if err := rewriteAll(positions, filter, func() interface{} { return &RoundMovement{} }); err != nil {
+		return err
+	}
+	projectiles := db.Collection(collectionNames[ClProjectiles])
+	filter = bson.M{"X": bson.M{"$type": "object"}}
+	return rewriteAll(projectiles, filter, func() interface{} { return &GrenadePositionInfoEncoded{} })
+}
+
+// Version 6 stores a lifecycle document of every smoke and flags kills whose victim a smoke hid from the killer.
+// Smokes are put together from their SmokeStart and SmokeExpired events, which share the thrower and the position,
+// without projectile and callout. Kills are flagged if the movements of their players were recorded.
+func migrateV5ToV6(db *mongo.Database, collectionNames map[ClIndex]string) error {
+	var header struct {
+		PositionPrecision int `bson:"PositionPrecision"`
+	}
+	if err := db.Collection(collectionNames[ClHeader]).FindOne(context.TODO(), bson.M{}).Decode(&header); err != nil {
+		return err
+	}
+	smokes := db.Collection(collectionNames[ClSmokes])
+	// an interrupted migration starts over
+	if _, err := smokes.DeleteMany(context.TODO(), bson.M{}); err != nil {
+		return err
+	}
+
+	events := db.Collection(collectionNames[ClEvents])
+	cursor, err := events.Find(context.TODO(), bson.M{"EventType": bson.M{"$in": []EvType{SmokeStart, SmokeExpired, Kill}}},
+		options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+
+	var up, lifecycles []*SmokeLifecycle
+	var throughSmoke []interface{}
+	for cursor.Next(context.TODO()) {
+		var ev struct {
+			ID			interface{}	`bson:"_id"`
+			FrameNumber	int			`bson:"FrameNumber"`
+			Tick		int			`bson:"Tick"`
+			RoundNumber	int			`bson:"RoundNumber"`
+			EventType	EvType		`bson:"EventType"`
+			Data		struct {
+				GrenadeEvent	struct {
+					Position	struct {
+						X, Y, Z	float64
+					}		`bson:"Position"`
+					Thrower	int64	`bson:"Thrower"`
+				}	`bson:"GrenadeEvent"`
+				VictimMovement	*PlayerMovementInfo	`bson:"VictimMovement"`
+				KillerMovement	*PlayerMovementInfo	`bson:"KillerMovement"`
+			}	`bson:"Data"`
+		}
+		if err := cursor.Decode(&ev); err != nil {
+			return err
+		}
+		// smokes are gone with the round they were thrown in
+		if len(up) > 0 && up[0].RoundNumber != ev.RoundNumber {
+			up = nil
+		}
+
+		switch ev.EventType {
+		case SmokeStart, SmokeExpired:
+			GE := ev.Data.GrenadeEvent
+			position, err := NewFixedVector3(r3.Vector{X: GE.Position.X, Y: GE.Position.Y, Z: GE.Position.Z}, header.PositionPrecision)
+			if err != nil {
+				return err
+			}
+			if ev.EventType == SmokeStart {
+				S := &SmokeLifecycle{-1, ev.RoundNumber, ev.FrameNumber, ev.Tick, -1, -1, GE.Thrower, position, ""}
+				up = append(up, S)
+				lifecycles = append(lifecycles, S)
+				continue
+			}
+			for i, S := range up {
+				if S.Thrower == GE.Thrower && S.Position == position {
+					S.ExpireFrame, S.ExpireTick = ev.FrameNumber, ev.Tick
+					up = append(up[:i], up[i+1:]...)
+					break
+				}
+			}
+		case Kill:
+			if ev.Data.VictimMovement == nil || ev.Data.KillerMovement == nil {
+				continue
+			}
+			for _, S := range up {
+				if SmokeBlocks(S.Position.Vector(header.PositionPrecision), ev.Data.KillerMovement.Position.Vector(header.PositionPrecision),
+					ev.Data.VictimMovement.Position.Vector(header.PositionPrecision)) {
+					throughSmoke = append(throughSmoke, ev.ID)
+					break
+				}
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if len(lifecycles) > 0 {
+		docs := make([]interface{}, len(lifecycles))
+		for i, S := range lifecycles {
+			docs[i] = S
+		}
+		if _, err := smokes.InsertMany(context.TODO(), docs); err != nil {
+			return err
+		}
+	}
+	if len(throughSmoke) > 0 {
+		_, err = events.UpdateMany(context.TODO(), bson.M{"_id": bson.M{"$in": throughSmoke}}, bson.M{"$set": bson.M{"Data.ThroughSmoke": true}})
+	}
+	return err
+}
+
+// rewriteAll decodes the matching documents into the value newDoc returns and replaces them with it.
+func rewriteAll(collection *mongo.Collection, filter bson.M, newDoc func() interface{}) error {
+	cursor, err := collection.Find(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		doc := newDoc()
+		if err := cursor.Decode(doc); err != nil {
+			return err
+		}
+		_, err := collection.ReplaceOne(context.TODO(), bson.M{"_id": cursor.Current.Lookup("_id")}, doc)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// legacyRoundStarts returns the first frame of every round from the first one on, the frames before being round 0.
+func legacyRoundStarts(events *mongo.Collection) ([]int, error) {
+	filter := bson.M{"EventType": bson.M{"$in": bson.A{ScoreUpdated, RoundFreezetimeEnd}}}
+	cursor, err := events.Find(context.TODO(), filter, options.Find().SetSort(bson.M{"FrameNumber": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var evs []legacyRoundEvent
+	for cursor.Next(context.TODO()) {
+		var ev legacyRoundEvent
+		if err := cursor.Decode(&ev); err != nil {
+			return nil, err
+		}
+		evs = append(evs, ev)
+	}
+	return roundStarts(evs), cursor.Err()
+}
+
+type legacyRoundEvent struct {
+	FrameNumber int    `bson:"FrameNumber"`
+	EventType   EvType `bson:"EventType"`
+}
+
+// roundStarts restores the first frames of the rounds from ScoreUpdated and RoundFreezetimeEnd events sorted by frame.
+// A round begins right after the last score update since the freeze time before ended, or where its own freeze time ends
+// if there was none. The first round begins at frame 0 if no score was updated before its freeze time ended,
+// as the demo then starts with it.
+func roundStarts(evs []legacyRoundEvent) []int {
+	var starts []int
+	lastScoreUpdate, lastFreezetimeEnd := -1, -1
+	for _, ev := range evs {
+		if ev.EventType == ScoreUpdated {
+			lastScoreUpdate = ev.FrameNumber
+			continue
+		}
+		switch {
+		case lastScoreUpdate > lastFreezetimeEnd:
+			starts = append(starts, lastScoreUpdate+1)
+		case lastFreezetimeEnd < 0:
+			starts = append(starts, 0)
+		default:
+			starts = append(starts, ev.FrameNumber)
+		}
+		lastFreezetimeEnd = ev.FrameNumber
+	}
+	return starts
+}
+
+func renameKeys(doc primitive.D, names map[string]string) primitive.D {
+	for i, e := range doc {
+		if name, ok := names[e.Key]; ok {
+			doc[i].Key = name
+		}
+	}
+	return doc
+}
+
+func idOf(doc primitive.D) interface{} {
+	for _, e := range doc {
+		if e.Key == "_id" {
+			return e.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoundStarts(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		evs      []legacyRoundEvent
+		expected []int
+	}{
+		{"no rounds", nil, nil},
+		{"demo starting with the first round", []legacyRoundEvent{
+			{100, RoundFreezetimeEnd}, {900, ScoreUpdated}, {1100, RoundFreezetimeEnd}, {1800, ScoreUpdated},
+		}, []int{0, 901}},
+		{"demo starting before the first round", []legacyRoundEvent{
+			{40, ScoreUpdated}, {300, RoundFreezetimeEnd}, {1300, RoundFreezetimeEnd},
+		}, []int{41, 1300}},
+	} {
+		if res := roundStarts(c.evs); !reflect.DeepEqual(res, c.expected) {
+			t.Error("roundStarts failed on ", c.name, ", got ", res, " instead of ", c.expected)
+		}
+	}
+}
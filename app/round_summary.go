@@ -0,0 +1,10 @@
+package app
+
+// RoundSummary is what commands make of a round after parsing. The parser stores one per round when it ends,
+// keyed by RoundNumber, and commands set their fields on it, leaving the others as they are.
+type RoundSummary struct {
+	RoundNumber	int		`bson:"RoundNumber"`
+	// Strategy the terrorists played, set by the strategies command: the label of the rule the round matched or,
+	// if none did, the group of alike rounds it was clustered into. "" if it wasn't classified.
+	Strategy	string	`bson:"Strategy"`
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"github.com/golang/geo/r3"
+)
+
+// SmokeLifecycle is a smoke from when it bloomed to when it faded.
+type SmokeLifecycle struct {
+	// UniqueID of the projectile, -1 if it was gone when the smoke started
+	Projectile	int64			`bson:"Projectile"`
+	RoundNumber	int				`bson:"RoundNumber"`
+	StartFrame	int				`bson:"StartFrame"`
+	StartTick	int				`bson:"StartTick"`
+	// -1 if the round or the demo ended before the smoke faded
+	ExpireFrame	int				`bson:"ExpireFrame"`
+	ExpireTick	int				`bson:"ExpireTick"`
+	// -1 if the smoke has no thrower
+	Thrower		int64			`bson:"Thrower"`
+	// Position is where the smoke grenade detonated, on the ground the cloud rises from
+	Position	FixedVector3	`bson:"Position"`
+	// place the smoke is in, "" if the parser had no zones of it
+	Callout		string			`bson:"Callout"`
+}
+
+// sizes of smokes and players in world units, for telling what a smoke hides
+const (
+	// SmokeRadius is the radius of the sphere a smoke is taken for
+	SmokeRadius = 144
+	// smokeCenterHeight is how high above where the grenade detonated the center of the sphere is
+	smokeCenterHeight = 64
+	// EyeHeight is how high above their position standing players see from
+	EyeHeight = 64
+)
+
+// SmokeBlocks tells whether a smoke that detonated at smoke hides players at from and to from each other,
+// i.e. whether the line between their eyes passes through the smoke.
+func SmokeBlocks(smoke, from, to r3.Vector) bool {
+	center := smoke.Add(r3.Vector{Z: smokeCenterHeight})
+	eye := r3.Vector{Z: EyeHeight}
+	a, b := from.Add(eye), to.Add(eye)
+	// distance from the center to the nearest point of the segment
+	d := b.Sub(a)
+	t := 0.0
+	if n := d.Norm2(); n > 0 {
+		t = center.Sub(a).Dot(d) / n
+		if t < 0 {
+			t = 0
+		} else if t > 1 {
+			t = 1
+		}
+	}
+	return a.Add(d.Mul(t)).Sub(center).Norm() <= SmokeRadius
+}
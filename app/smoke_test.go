@@ -0,0 +1,31 @@
+package app
+
+import (
+	"github.com/golang/geo/r3"
+	"testing"
+)
+
+func TestSmokeBlocks(t *testing.T) {
+	smoke := r3.Vector{X: 100, Y: 100, Z: 0}
+	for _, c := range []struct {
+		from, to r3.Vector
+		expected bool
+	}{
+		// straight through the middle
+		{r3.Vector{X: -500, Y: 100}, r3.Vector{X: 700, Y: 100}, true},
+		// grazing the edge
+		{r3.Vector{X: -500, Y: 100 + SmokeRadius}, r3.Vector{X: 700, Y: 100 + SmokeRadius}, true},
+		{r3.Vector{X: -500, Y: 101 + SmokeRadius}, r3.Vector{X: 700, Y: 101 + SmokeRadius}, false},
+		// both players on the same side of the smoke
+		{r3.Vector{X: -500, Y: 100}, r3.Vector{X: -300, Y: 100}, false},
+		// a player standing in the smoke
+		{r3.Vector{X: 100, Y: 100}, r3.Vector{X: 1000, Y: 1000}, true},
+		// high above the smoke
+		{r3.Vector{X: -500, Y: 100, Z: 300}, r3.Vector{X: 700, Y: 100, Z: 300}, false},
+		{r3.Vector{X: 100, Y: 100}, r3.Vector{X: 100, Y: 100}, true},
+	} {
+		if res := SmokeBlocks(smoke, c.from, c.to); res != c.expected {
+			t.Error("SmokeBlocks failed from ", c.from, " to ", c.to, ", got ", res, " instead of ", c.expected)
+		}
+	}
+}
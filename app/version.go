@@ -0,0 +1,18 @@
+package app
+
+// SchemaVersion is the version of the BSON layout of the stored documents.
+// It is stamped into the header and replays documents, so databases produced by
+// older parser versions can be told apart and upgraded with Migrate.
+// Databases without a stamp are treated as version 1.
+// Fields added as optional, which older documents are read without, don't change it.
+const SchemaVersion = 6
+
+// MinReadableSchemaVersion is the oldest schema version whose documents are read the same as the current ones.
+// Older databases have to be migrated before they can be read.
+const MinReadableSchemaVersion = 4
+
+// ParserVersion is the version of the parser that produced the documents.
+const ParserVersion = "0.18.0"
+
+// legacySchemaVersion is assumed for databases that have no SchemaVersion in their header.
+const legacySchemaVersion = 1
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"csgo-parser-mongodb/app"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// commands run instead of parsing when given as the first argument,
+// e.g. `csgo-parser-mongodb migrate -dbname match730_1`
+var commands = map[string]func(args []string){
+	"migrate":    runMigrate,
+	"decode":     runDecode,
+	"measure":    runMeasure,
+	"query":      runQuery,
+	"heatmap":    runHeatmap,
+	"replay":     runReplay,
+	"lineups":    runLineups,
+	"smokes":     runSmokes,
+	"formation":  runFormation,
+	"strategies": runStrategies,
+	"similar":    runSimilar,
+}
+
+// databaseNames returns dbName, or every database registered in meta_info if all is set.
+func databaseNames(client *mongo.Client, dbName string, all bool) []string {
+	if !all {
+		return []string{dbName}
+	}
+	names, err := client.Database("meta_info").Collection(clNames[app.ClReplays]).Distinct(context.TODO(), "DBname", bson.M{})
+	checkError(err)
+	var dbNames []string
+	for _, name := range names {
+		dbNames = append(dbNames, name.(string))
+	}
+	return dbNames
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"csgo-parser-mongodb/app"
+	"csgo-parser-mongodb/reader"
+	"flag"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+const decodeBatchSize = 1000
+
+// runDecode reads the Elias encoded positions of a match and emits them in the shape
+// they are stored in without -elias: FramePositions and FrameProjectiles documents.
+func runDecode(args []string) {
+	var mongoUri, dbName, outDbName string
+
+	flags := flag.NewFlagSet("decode", flag.ExitOnError)
+	flags.StringVar(&mongoUri, "uri", "localhost:27017", "MongoDB connection URI.")
+	flags.StringVar(&dbName, "dbname", "test", "Database name of the parsed match.")
+	flags.StringVar(&outDbName, "outdb", "", "Database to write decoded positions to. Prints them as JSON lines if empty.")
+	checkError(flags.Parse(args))
+
+	client := connect_to_mongo("mongodb://"+mongoUri, 2*time.Second)
+	defer close_connection_to_mongo(client)
+
+	match, err := reader.Load(reader.NewMongoSource(client.Database(dbName), clNames))
+	checkError(err)
+
+	var out func(cl app.ClIndex, doc interface{})
+	var flush func()
+	if outDbName == "" {
+		out = func(cl app.ClIndex, doc interface{}) {
+			line, err := bson.MarshalExtJSON(doc, false, false)
+			checkError(err)
+			fmt.Println(string(line))
+		}
+		flush = func() {}
+	} else {
+		batches := make(map[app.ClIndex][]interface{})
+		flush = func() {
+			for cl, batch := range batches {
+				if len(batch) > 0 {
+					_, err := client.Database(outDbName).Collection(clNames[cl]).InsertMany(context.TODO(), batch)
+					checkError(err)
+				}
+				batches[cl] = batch[:0]
+			}
+		}
+		out = func(cl app.ClIndex, doc interface{}) {
+			batches[cl] = append(batches[cl], doc)
+			if len(batches[cl]) >= decodeBatchSize {
+				flush()
+			}
+		}
+	}
+
+	positions, err := match.Positions()
+	checkError(err)
+	for positions.Next() {
+		out(app.ClPositions, positions.Value())
+	}
+	checkError(positions.Err())
+	checkError(positions.Close())
+
+	projectiles, err := match.Projectiles()
+	checkError(err)
+	for projectiles.Next() {
+		out(app.ClProjectiles, projectiles.Value())
+	}
+	checkError(projectiles.Err())
+	checkError(projectiles.Close())
+
+	flush()
+}
+
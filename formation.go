@@ -0,0 +1,51 @@
+package main
+
+import (
+	"csgo-parser-mongodb/app"
+	"csgo-parser-mongodb/reader"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runFormation prints the spacing and territory of the teams in a match as JSON lines, every stored second
+// or, with -at, once a round at the same time after its freeze time ended to compare the teams' defaults.
+func runFormation(args []string) {
+	var mongoUri, dbName string
+	var round int
+	var at float64
+
+	flags := flag.NewFlagSet("formation", flag.ExitOnError)
+	flags.StringVar(&mongoUri, "uri", "localhost:27017", "MongoDB connection URI.")
+	flags.StringVar(&dbName, "dbname", "test", "Database name of the parsed match.")
+	flags.IntVar(&round, "round", 0, "Prints only the metrics of this round. 0 prints every round's.")
+	flags.Float64Var(&at, "at", -1, "Prints the metrics of every round only this many seconds after its freeze time ended. Negative prints every stored second.")
+	checkError(flags.Parse(args))
+
+	if round < 0 {
+		fmt.Println("-round must not be negative.")
+		os.Exit(2)
+	}
+
+	client := connect_to_mongo("mongodb://"+mongoUri, 2*time.Second)
+	defer close_connection_to_mongo(client)
+
+	match, err := reader.Load(reader.NewMongoSource(client.Database(dbName), clNames))
+	checkError(err)
+	var metrics []app.FrameTeamMetrics
+	if at < 0 {
+		metrics, err = match.TeamMetrics()
+	} else {
+		metrics, err = match.TeamMetricsAt(at)
+	}
+	checkError(err)
+
+	out := json.NewEncoder(os.Stdout)
+	for _, TM := range metrics {
+		if round == 0 || TM.RoundNumber == round {
+			checkError(out.Encode(TM))
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"csgo-parser-mongodb/app"
+	"csgo-parser-mongodb/heatmap"
+	"csgo-parser-mongodb/overview"
+	"csgo-parser-mongodb/reader"
+	"flag"
+	"fmt"
+	"github.com/golang/geo/r3"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// radarSize is the size in pixels radar coordinates are given in, the size of the game's radar images.
+const radarSize = 1024
+
+// heatmapPoint is a position to draw with what it can be filtered by.
+type heatmapPoint struct {
+	round int
+	// SteamID of the player the position belongs to, -1 if none does, e.g. for a flash detonation
+	player   int64
+	weapon   common.EquipmentElement
+	position r3.Vector
+}
+
+// heatmapFilter selects the points to draw. Zero values select everything.
+type heatmapFilter struct {
+	player     int64
+	side       common.Team
+	firstRound int
+	// 0 for no last round
+	lastRound int
+	weapon    common.EquipmentElement
+}
+
+func (f heatmapFilter) keep(p heatmapPoint, teams reader.Teams) bool {
+	if p.round < f.firstRound || f.lastRound > 0 && p.round > f.lastRound {
+		return false
+	}
+	if f.weapon != common.EqUnknown && p.weapon != f.weapon {
+		return false
+	}
+	if f.player != 0 && p.player != f.player {
+		return false
+	}
+	if f.side != common.TeamUnassigned && (p.player == -1 || teams.Team(p.round, p.player) != f.side) {
+		return false
+	}
+	return true
+}
+
+// runHeatmap draws how densely kills, deaths, positions or grenade detonations are spread over a map's radar
+// to a PNG, for a match or every match of the map registered in meta_info.
+func runHeatmap(args []string) {
+	var mongoUri, dbName, input, out, radarImage, overviewsDir, section, side, rounds, weapon string
+	var all bool
+	var filter heatmapFilter
+	var roundTime, sigma, opacity float64
+
+	flags := flag.NewFlagSet("heatmap", flag.ExitOnError)
+	flags.StringVar(&mongoUri, "uri", "localhost:27017", "MongoDB connection URI.")
+	flags.StringVar(&dbName, "dbname", "test", "Database name of the parsed match.")
+	flags.BoolVar(&all, "all", false, "Draws every database registered in meta_info of the same map as the first one together.")
+	flags.StringVar(&input, "input", "deaths", "What to draw: deaths, kills, positions or grenades, where grenades detonated.")
+	flags.StringVar(&out, "out", "heatmap.png", "PNG file to draw to.")
+	flags.StringVar(&radarImage, "radarimage", "", "PNG or JPEG radar image of the map to draw over, e.g. converted from the game's .dds. Draws over transparency if empty.")
+	flags.StringVar(&overviewsDir, "overviews", "", "Folder of the maps' overview files, for matches stored without the overview of their map.")
+	flags.StringVar(&section, "section", overview.DefaultSection, "Level of multi-level maps to draw, e.g. lower on de_nuke. Positions on other levels are left out.")
+	flags.Int64Var(&filter.player, "steamid", 0, "Draws only the positions of the player with this SteamID. 0 draws everyone's.")
+	flags.StringVar(&side, "side", "", "Draws only the positions of players on this side, t or ct. Empty draws both.")
+	flags.StringVar(&rounds, "rounds", "", "Draws only these rounds, e.g. 5 or 1-15. Empty draws every round.")
+	flags.StringVar(&weapon, "weapon", "", "Draws only kills and deaths by this weapon or detonations of this grenade, e.g. ak47 or AK-47. Doesn't apply to positions.")
+	flags.Float64Var(&roundTime, "time", -1, "Draws positions this many seconds after the end of the freeze time of every round. Negative draws every stored position.")
+	flags.Float64Var(&sigma, "radius", 8, "Spreads every point over a blob of about this radius in pixels of the radar.")
+	flags.Float64Var(&opacity, "opacity", 0.8, "Opacity of the most dense spots, from 0 to 1.")
+	checkError(flags.Parse(args))
+
+	var err error
+	if filter.side, err = parseSide(side); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if filter.firstRound, filter.lastRound, err = parseRoundRange(rounds); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if weapon != "" {
+		if filter.weapon = parseWeapon(weapon); filter.weapon == common.EqUnknown {
+			fmt.Printf("Unknown weapon %q.\n", weapon)
+			os.Exit(2)
+		}
+	}
+	switch {
+	case input != "deaths" && input != "kills" && input != "positions" && input != "grenades":
+		fmt.Printf("Unknown input %q, must be deaths, kills, positions or grenades.\n", input)
+		os.Exit(2)
+	case input == "positions" && filter.weapon != common.EqUnknown:
+		fmt.Println("-weapon doesn't apply to positions.")
+		os.Exit(2)
+	case sigma < 0 || opacity < 0 || opacity > 1:
+		fmt.Println("-radius must not be negative and -opacity must be between 0 and 1.")
+		os.Exit(2)
+	}
+
+	var overviews overview.Registry
+	if overviewsDir != "" {
+		if overviews, err = overview.LoadDir(overviewsDir); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+	var background image.Image
+	width, height := radarSize, radarSize
+	if radarImage != "" {
+		f, err := os.Open(radarImage)
+		checkError(err)
+		background, _, err = image.Decode(f)
+		f.Close()
+		checkError(err)
+		width, height = background.Bounds().Dx(), background.Bounds().Dy()
+	}
+
+	client := connect_to_mongo("mongodb://"+mongoUri, 2*time.Second)
+	defer close_connection_to_mongo(client)
+
+	grid := heatmap.NewGrid(width, height)
+	mapName := ""
+	drawn, matches := 0, 0
+	for _, name := range databaseNames(client, dbName, all) {
+		match, err := reader.Load(reader.NewMongoSource(client.Database(name), clNames))
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			continue
+		}
+		if mapName == "" {
+			mapName = match.Header.MapName
+		} else if !strings.EqualFold(match.Header.MapName, mapName) {
+			continue
+		}
+		OV, ok := match.Overview(overviews)
+		if !ok {
+			fmt.Printf("%s: no overview of %s, see -overviews\n", name, match.Header.MapName)
+			continue
+		}
+		var teams reader.Teams
+		if filter.side != common.TeamUnassigned {
+			teams, err = match.Teams()
+			checkError(err)
+		}
+
+		points, err := heatmapPoints(match, input, roundTime)
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			continue
+		}
+		matches++
+		for _, p := range points {
+			if !filter.keep(p, teams) {
+				continue
+			}
+			radar := OV.ToRadar(p.position)
+			if OV.Sections[radar.Section].Name != section {
+				continue
+			}
+			grid.Add(radar.X*float64(width)/radarSize, radar.Y*float64(height)/radarSize, 1)
+			drawn++
+		}
+	}
+
+	f, err := os.Create(out)
+	checkError(err)
+	defer f.Close()
+	checkError(png.Encode(f, grid.Blur(sigma*float64(width)/radarSize).Render(background, opacity)))
+	fmt.Printf("Drew %d %s of %d matches of %s to %s.\n", drawn, input, matches, mapName, out)
+}
+
+// heatmapPoints returns the positions of a match to draw.
+func heatmapPoints(match *reader.Match, input string, roundTime float64) ([]heatmapPoint, error) {
+	var points []heatmapPoint
+	switch input {
+	case "deaths", "kills":
+		legacy := 0
+		for _, ev := range match.EventsOfType(app.Kill) {
+			data := ev.Data.(*reader.KillData)
+			movement, player := data.VictimMovement, data.Victim
+			if input == "kills" {
+				movement, player = data.KillerMovement, data.Killer
+			}
+			if movement == nil {
+				// kills stored before the positions of their players were recorded
+				if data.VictimMovement == nil {
+					legacy++
+				}
+				continue
+			}
+			weapon := common.EqUnknown
+			if data.Weapon.Valid {
+				weapon = data.Weapon.Weapon
+			}
+			points = append(points, heatmapPoint{ev.RoundNumber, player, weapon, match.Position(movement.Position)})
+		}
+		if legacy > 0 {
+			return nil, fmt.Errorf("%d kills were stored without the positions of their players, parse the match again", legacy)
+		}
+
+	case "grenades":
+		for _, ev := range match.Events {
+			switch data := ev.Data.(type) {
+			case *reader.GrenadeData:
+				if ev.Type == app.SmokeStart || ev.Type == app.HeExplode || ev.Type == app.FireGrenadeStart || ev.Type == app.DecoyStart {
+					points = append(points, heatmapPoint{ev.RoundNumber, data.Thrower, data.GrenadeType, r3.Vector{X: data.Position.X, Y: data.Position.Y, Z: data.Position.Z}})
+				}
+			case *app.FlashExplodeInfo:
+				// flash detonations are stored without their thrower
+				points = append(points, heatmapPoint{ev.RoundNumber, -1, common.EqFlash, match.Position(data.Position)})
+			}
+		}
+
+	case "positions":
+		if roundTime >= 0 {
+			return roundTimePoints(match, roundTime)
+		}
+		it, err := match.Positions()
+		if err != nil {
+			return nil, err
+		}
+		defer it.Close()
+		for it.Next() {
+			FP := it.Value()
+			round := roundOfFrame(match.Rounds, FP.FrameNumber)
+			for _, PMI := range FP.PlayersPositions {
+				points = append(points, heatmapPoint{round, PMI.SteamID, common.EqUnknown, match.Position(PMI.Position)})
+			}
+		}
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return points, nil
+}
+
+// roundTimePoints returns the positions of the players the given number of seconds after the freeze time of every round.
+func roundTimePoints(match *reader.Match, roundTime float64) ([]heatmapPoint, error) {
+	if match.Header.PlaybackTime <= 0 {
+		return nil, fmt.Errorf("header has no playback time to tell the tick rate by")
+	}
+	tickRate := float64(match.Header.PlaybackTicks) / match.Header.PlaybackTime.Seconds()
+	query, err := match.Query()
+	if err != nil {
+		return nil, err
+	}
+	var points []heatmapPoint
+	for _, round := range match.Rounds {
+		if round.FreezetimeEndTick < 0 {
+			continue
+		}
+		tick := float64(round.FreezetimeEndTick) + roundTime*tickRate
+		if round.EndTick >= 0 && tick > float64(round.EndTick) {
+			continue
+		}
+		players, err := query.PlayersAt(tick)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range players {
+			points = append(points, heatmapPoint{round.Number, p.SteamID, common.EqUnknown, p.Position})
+		}
+	}
+	return points, nil
+}
+
+// roundOfFrame returns the number of the round a frame is in, 0 if it is before the first one.
+func roundOfFrame(rounds []reader.Round, frame int) int {
+	number := 0
+	for _, r := range rounds {
+		if r.StartFrame >= 0 && r.StartFrame <= frame {
+			number = r.Number
+		}
+	}
+	return number
+}
+
+func parseSide(side string) (common.Team, error) {
+	switch strings.ToLower(side) {
+	case "":
+		return common.TeamUnassigned, nil
+	case "t":
+		return common.TeamTerrorists, nil
+	case "ct":
+		return common.TeamCounterTerrorists, nil
+	}
+	return common.TeamUnassigned, fmt.Errorf("unknown side %q, must be t or ct", side)
+}
+
+// parseRoundRange parses a round, e.g. 5, or a range of rounds, e.g. 1-15. An empty range is every round,
+// which has 0 for its last round.
+func parseRoundRange(rounds string) (first, last int, err error) {
+	if rounds == "" {
+		return 0, 0, nil
+	}
+	parts := strings.SplitN(rounds, "-", 2)
+	if first, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, fmt.Errorf("malformed round range %q", rounds)
+	}
+	last = first
+	if len(parts) == 2 {
+		if last, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, fmt.Errorf("malformed round range %q", rounds)
+		}
+	}
+	if first < 1 || last < first {
+		return 0, 0, fmt.Errorf("incorrect round range %q, rounds start at 1", rounds)
+	}
+	return first, last, nil
+}
+
+// parseWeapon looks a weapon up by its name in the game, e.g. ak47, or the name demoinfocs gives it, e.g. AK-47.
+func parseWeapon(name string) common.EquipmentElement {
+	if eq := common.MapEquipment(strings.ToLower(name)); eq != common.EqUnknown {
+		return eq
+	}
+	for eq := common.EquipmentElement(1); eq < 1000; eq++ {
+		if s := eq.String(); s != "" && strings.EqualFold(s, name) {
+			return eq
+		}
+	}
+	return common.EqUnknown
+}
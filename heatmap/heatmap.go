@@ -0,0 +1,151 @@
+// Package heatmap renders how densely points are spread over an image, e.g. a map's radar.
+package heatmap
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Grid counts points by the pixel they fall in.
+type Grid struct {
+	width, height int
+	values        []float64
+}
+
+// NewGrid returns an empty grid of the given size in pixels.
+func NewGrid(width, height int) *Grid {
+	return &Grid{width, height, make([]float64, width*height)}
+}
+
+// Add counts a point with the given weight in the pixel it falls in. Points outside of the grid are dropped.
+func (g *Grid) Add(x, y, weight float64) {
+	ix, iy := int(math.Floor(x)), int(math.Floor(y))
+	if ix < 0 || iy < 0 || ix >= g.width || iy >= g.height {
+		return
+	}
+	g.values[iy*g.width+ix] += weight
+}
+
+// At returns the weight counted in a pixel.
+func (g *Grid) At(x, y int) float64 {
+	return g.values[y*g.width+x]
+}
+
+// Max returns the greatest weight of a pixel.
+func (g *Grid) Max() float64 {
+	max := 0.0
+	for _, v := range g.values {
+		max = math.Max(max, v)
+	}
+	return max
+}
+
+// Blur spreads every pixel's weight over its surroundings with a Gaussian of the given standard deviation
+// in pixels, so single points show as blobs. The total weight stays the same but for what spreads over the edges.
+func (g *Grid) Blur(sigma float64) *Grid {
+	if sigma <= 0 {
+		res := NewGrid(g.width, g.height)
+		copy(res.values, g.values)
+		return res
+	}
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	// the Gaussian is separable, rows are blurred first and then columns
+	rows := NewGrid(g.width, g.height)
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			v := g.values[y*g.width+x]
+			if v == 0 {
+				continue
+			}
+			for i, k := range kernel {
+				if tx := x + i - radius; tx >= 0 && tx < g.width {
+					rows.values[y*g.width+tx] += v * k
+				}
+			}
+		}
+	}
+	res := NewGrid(g.width, g.height)
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			v := rows.values[y*g.width+x]
+			if v == 0 {
+				continue
+			}
+			for i, k := range kernel {
+				if ty := y + i - radius; ty >= 0 && ty < g.height {
+					res.values[ty*g.width+x] += v * k
+				}
+			}
+		}
+	}
+	return res
+}
+
+// Render draws the weights of the grid, relative to the greatest one, over the background,
+// from transparent blue where there are few points through green and yellow to red where there are most.
+// The background is drawn stretched over the grid; without one the points are drawn over transparency.
+// opacity is how opaque the colors of the most dense pixels are, from 0 to 1.
+func (g *Grid) Render(background image.Image, opacity float64) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, g.width, g.height))
+	if background != nil {
+		b := background.Bounds()
+		for y := 0; y < g.height; y++ {
+			for x := 0; x < g.width; x++ {
+				img.Set(x, y, background.At(b.Min.X+x*b.Dx()/g.width, b.Min.Y+y*b.Dy()/g.height))
+			}
+		}
+	}
+
+	max := g.Max()
+	if max == 0 {
+		return img
+	}
+	overlay := image.NewNRGBA(img.Bounds())
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			if v := g.values[y*g.width+x] / max; v > 0 {
+				overlay.SetNRGBA(x, y, Color(v, opacity))
+			}
+		}
+	}
+	draw.Draw(img, img.Bounds(), overlay, image.Point{}, draw.Over)
+	return img
+}
+
+// colorStops are the colors weights from 0 to 1 are mapped to, evenly spaced.
+var colorStops = []color.NRGBA{
+	{0, 0, 255, 0},
+	{0, 255, 255, 0},
+	{0, 255, 0, 0},
+	{255, 255, 0, 0},
+	{255, 0, 0, 0},
+}
+
+// Color returns the color of a weight relative to the greatest one, in [0, 1].
+// Its alpha grows with the weight up to opacity.
+func Color(v, opacity float64) color.NRGBA {
+	v = math.Max(0, math.Min(1, v))
+	pos := v * float64(len(colorStops)-1)
+	i := int(math.Min(pos, float64(len(colorStops)-2)))
+	t := pos - float64(i)
+	a, b := colorStops[i], colorStops[i+1]
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+	// weak densities fade out instead of tinting the whole map
+	alpha := math.Sqrt(v) * math.Max(0, math.Min(1, opacity))
+	return color.NRGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), uint8(math.Round(alpha * 255))}
+}
@@ -0,0 +1,61 @@
+package heatmap
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestBlur(t *testing.T) {
+	g := NewGrid(41, 31)
+	g.Add(20.5, 15.2, 2)
+	g.Add(-1, 3, 1)
+	g.Add(41, 3, 1)
+	if g.At(20, 15) != 2 || g.Max() != 2 {
+		t.Error("Add failed, got ", g.At(20, 15), g.Max())
+	}
+
+	blurred := g.Blur(2)
+	total := 0.0
+	for y := 0; y < 31; y++ {
+		for x := 0; x < 41; x++ {
+			total += blurred.At(x, y)
+		}
+	}
+	if math.Abs(total-2) > 1e-9 {
+		t.Error("Blur failed to keep the weight, got ", total, " instead of ", 2)
+	}
+	if blurred.Max() != blurred.At(20, 15) || blurred.At(22, 15) >= blurred.At(21, 15) || blurred.At(21, 15) != blurred.At(19, 15) || blurred.At(20, 17) != blurred.At(22, 15) {
+		t.Error("Blur failed to spread the weight evenly around the point")
+	}
+	if g.Blur(0).At(20, 15) != 2 {
+		t.Error("Blur without a radius changed the grid")
+	}
+}
+
+func TestRender(t *testing.T) {
+	background := image.NewUniform(color.RGBA{10, 20, 30, 255})
+	g := NewGrid(4, 4)
+	g.Add(1, 1, 4)
+	g.Add(2, 2, 1)
+	img := g.Render(background, 1)
+
+	if res := img.RGBAAt(0, 0); res != (color.RGBA{10, 20, 30, 255}) {
+		t.Error("Render failed on an empty pixel, got ", res)
+	}
+	if res := img.RGBAAt(1, 1); res != (color.RGBA{255, 0, 0, 255}) {
+		t.Error("Render failed on the most dense pixel, got ", res)
+	}
+	// a quarter of the greatest weight is cyan at half the opacity
+	if res, expected := Color(0.25, 1), (color.NRGBA{0, 255, 255, 128}); res != expected {
+		t.Error("Color failed, got ", res, " instead of ", expected)
+	}
+	if res := Color(0.5, 0.5); res.G != 255 || res.A != uint8(math.Round(math.Sqrt(0.5)*0.5*255)) {
+		t.Error("Color failed, got ", res)
+	}
+
+	if res := NewGrid(2, 2).Render(nil, 1).RGBAAt(1, 1); res != (color.RGBA{}) {
+		t.Error("Render failed without points and background, got ", res)
+	}
+}
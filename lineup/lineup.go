@@ -0,0 +1,202 @@
+// Package lineup mines grenade lineups from the throws of matches on the same map: throws of the same grenade
+// by the same side from about the same spot to about the same spot are the same lineup.
+package lineup
+
+import (
+	"csgo-parser-mongodb/reader"
+	"fmt"
+	"github.com/golang/geo/r3"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"math"
+	"sort"
+)
+
+// AccuracyRadius is how far in world units from its lineup's detonation a throw may detonate and still have landed,
+// about the width of two players.
+const AccuracyRadius = 64
+
+// Throw is a grenade thrown in a match.
+type Throw struct {
+	// Match is the database name of the match
+	Match string
+	reader.GrenadeThrow
+}
+
+// Lineup is a way of throwing a grenade that players repeat.
+// Position, ViewX, ViewY and Detonation are the means of its throws'.
+type Lineup struct {
+	Name         string
+	Weapon       common.EquipmentElement
+	Team         common.Team
+	Position     r3.Vector
+	ViewX, ViewY float64
+	Detonation   r3.Vector
+	// the most common callouts of the throws, "" if none had any
+	ThrowerCallout, DetonationCallout string
+	Throws                            []Throw
+}
+
+// Stats are how often and how well a lineup was thrown.
+type Stats struct {
+	Throws, Matches, Players int
+	// Accuracy is the share of throws that detonated within AccuracyRadius of the lineup's detonation
+	Accuracy float64
+	// SuccessRate is the share of successful throws: flashes that blinded an enemy, HE grenades, molotovs and
+	// incendiaries that damaged one, and smokes that landed, see Accuracy
+	SuccessRate float64
+	// EnemiesFlashed and EnemyDamage are per throw
+	EnemiesFlashed, EnemyDamage float64
+	// RoundsWon is the share of throws in rounds the thrower's team won
+	RoundsWon float64
+}
+
+// Stats returns how often and how well the lineup was thrown.
+func (l Lineup) Stats() Stats {
+	var s Stats
+	if len(l.Throws) == 0 {
+		return s
+	}
+	matches, players := make(map[string]bool), make(map[int64]bool)
+	var landed, succeeded, won, flashed, damage int
+	for _, t := range l.Throws {
+		matches[t.Match] = true
+		players[t.Thrower] = true
+		accurate := t.Detonation.Sub(l.Detonation).Norm() <= AccuracyRadius
+		if accurate {
+			landed++
+		}
+		switch t.Weapon {
+		case common.EqSmoke:
+			if accurate {
+				succeeded++
+			}
+		case common.EqFlash:
+			if t.EnemiesFlashed > 0 {
+				succeeded++
+			}
+		default:
+			if t.EnemyDamage > 0 {
+				succeeded++
+			}
+		}
+		if t.RoundWon {
+			won++
+		}
+		flashed += t.EnemiesFlashed
+		damage += t.EnemyDamage
+	}
+	n := float64(len(l.Throws))
+	s.Throws, s.Matches, s.Players = len(l.Throws), len(matches), len(players)
+	s.Accuracy, s.SuccessRate, s.RoundsWon = float64(landed)/n, float64(succeeded)/n, float64(won)/n
+	s.EnemiesFlashed, s.EnemyDamage = float64(flashed)/n, float64(damage)/n
+	return s
+}
+
+// cluster is a lineup being put together, with the sums of its throws.
+type cluster struct {
+	weapon               common.EquipmentElement
+	team                 common.Team
+	position, detonation r3.Vector
+	throws               []Throw
+}
+
+func (c *cluster) add(t Throw) {
+	c.position, c.detonation = c.position.Add(t.Position), c.detonation.Add(t.Detonation)
+	c.throws = append(c.throws, t)
+}
+
+func (c *cluster) mean(sum r3.Vector) r3.Vector {
+	return sum.Mul(1 / float64(len(c.throws)))
+}
+
+// Cluster puts throws of the same grenade by the same side into lineups. A throw joins the first lineup whose
+// mean throw position is within throwRadius and mean detonation within landingRadius world units of its own,
+// or starts a new one.
+// Lineups are returned from the most thrown on, each named after its callouts or, without any, its positions.
+func Cluster(throws []Throw, throwRadius, landingRadius float64) []Lineup {
+	var clusters []*cluster
+	for _, t := range throws {
+		var joined bool
+		for _, c := range clusters {
+			if c.weapon == t.Weapon && c.team == t.Team &&
+				t.Position.Sub(c.mean(c.position)).Norm() <= throwRadius && t.Detonation.Sub(c.mean(c.detonation)).Norm() <= landingRadius {
+				c.add(t)
+				joined = true
+				break
+			}
+		}
+		if !joined {
+			c := &cluster{weapon: t.Weapon, team: t.Team}
+			c.add(t)
+			clusters = append(clusters, c)
+		}
+	}
+
+	res := make([]Lineup, 0, len(clusters))
+	for _, c := range clusters {
+		l := Lineup{
+			Weapon:     c.weapon,
+			Team:       c.team,
+			Position:   c.mean(c.position),
+			Detonation: c.mean(c.detonation),
+			Throws:     c.throws,
+		}
+		var yaws, pitches []float64
+		throwerCallouts, detonationCallouts := make(map[string]int), make(map[string]int)
+		for _, t := range c.throws {
+			yaws, pitches = append(yaws, t.ViewX), append(pitches, t.ViewY)
+			throwerCallouts[t.ThrowerCallout]++
+			detonationCallouts[t.DetonationCallout]++
+		}
+		l.ViewX, l.ViewY = meanAngle(yaws), meanAngle(pitches)
+		l.ThrowerCallout, l.DetonationCallout = mostCommon(throwerCallouts), mostCommon(detonationCallouts)
+		res = append(res, l)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return len(res[i].Throws) > len(res[j].Throws)
+	})
+	name(res)
+	return res
+}
+
+// meanAngle returns the mean of angles in degrees, in [0, 360), so that 350 and 10 average to 0 and not 180.
+func meanAngle(angles []float64) float64 {
+	var x, y float64
+	for _, a := range angles {
+		x += math.Cos(a * math.Pi / 180)
+		y += math.Sin(a * math.Pi / 180)
+	}
+	// atan2 gives tiny remainders for angles that should come out whole
+	res := math.Round(math.Atan2(y, x)*180/math.Pi*1e9) / 1e9
+	return math.Mod(res+360, 360)
+}
+
+// mostCommon returns the most common non-empty callout, the first in alphabetical order on ties.
+func mostCommon(callouts map[string]int) string {
+	res, count := "", 0
+	for callout, n := range callouts {
+		if callout != "" && (n > count || n == count && callout < res) {
+			res, count = callout, n
+		}
+	}
+	return res
+}
+
+// name names the lineups after their side, grenade and callouts, numbering lineups that would share a name.
+func name(lineups []Lineup) {
+	place := func(callout string, v r3.Vector) string {
+		if callout != "" {
+			return callout
+		}
+		return fmt.Sprintf("(%.0f, %.0f)", v.X, v.Y)
+	}
+	side := map[common.Team]string{common.TeamTerrorists: "T ", common.TeamCounterTerrorists: "CT "}
+	seen := make(map[string]int)
+	for i := range lineups {
+		l := &lineups[i]
+		l.Name = fmt.Sprintf("%s%s from %s to %s", side[l.Team], l.Weapon, place(l.ThrowerCallout, l.Position), place(l.DetonationCallout, l.Detonation))
+		if seen[l.Name]++; seen[l.Name] > 1 {
+			l.Name = fmt.Sprintf("%s #%d", l.Name, seen[l.Name])
+		}
+	}
+}
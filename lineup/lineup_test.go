@@ -0,0 +1,63 @@
+package lineup
+
+import (
+	"csgo-parser-mongodb/reader"
+	"github.com/golang/geo/r3"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"math"
+	"testing"
+)
+
+func TestCluster(t *testing.T) {
+	T, CT := common.TeamTerrorists, common.TeamCounterTerrorists
+	throw := func(match string, thrower int64, team common.Team, weapon common.EquipmentElement, x, dx float64, yaw float64) Throw {
+		return Throw{match, reader.GrenadeThrow{
+			Thrower: thrower, Team: team, Weapon: weapon,
+			Position: r3.Vector{X: x, Y: 0}, ViewX: yaw, ViewY: 10,
+			Detonation:     r3.Vector{X: x + dx, Y: 1000},
+			ThrowerCallout: "T Spawn", DetonationCallout: "Window",
+		}}
+	}
+	smoke1 := throw("a", 1, T, common.EqSmoke, 0, 0, 350)
+	smoke2 := throw("b", 2, T, common.EqSmoke, 10, 0, 10)
+	// landed too far off to count as landed
+	smoke3 := throw("b", 1, T, common.EqSmoke, 20, 100, 0)
+	// same spots but another side, grenade or landing
+	ctSmoke := throw("a", 3, CT, common.EqSmoke, 0, 0, 0)
+	flash := throw("a", 1, T, common.EqFlash, 0, 0, 0)
+	flash.EnemiesFlashed, flash.RoundWon = 2, true
+	elsewhere := throw("a", 1, T, common.EqSmoke, 0, 1000, 0)
+	elsewhere.DetonationCallout = ""
+
+	lineups := Cluster([]Throw{smoke1, ctSmoke, smoke2, flash, smoke3, elsewhere}, 32, 150)
+	if len(lineups) != 4 {
+		t.Fatal("Cluster failed, got ", len(lineups), " lineups: ", lineups)
+	}
+	l := lineups[0]
+	if len(l.Throws) != 3 || l.Weapon != common.EqSmoke || l.Team != T {
+		t.Fatal("Cluster failed, got ", l)
+	}
+	if expected := (r3.Vector{X: 10, Y: 0}); l.Position != expected {
+		t.Error("Cluster failed on the position, got ", l.Position, " instead of ", expected)
+	}
+	if l.ViewX != 0 || math.Abs(l.ViewY-10) > 1e-9 {
+		t.Error("Cluster failed on the view angles, got ", l.ViewX, l.ViewY)
+	}
+	if expected := "T Smoke Grenade from T Spawn to Window"; l.Name != expected {
+		t.Error("Cluster failed on the name, got ", l.Name, " instead of ", expected)
+	}
+	if res, expected := lineups[3].Name, "T Smoke Grenade from T Spawn to (1000, 1000)"; res != expected {
+		t.Error("Cluster failed on the name without callout, got ", res, " instead of ", expected)
+	}
+	if res := lineups[1].Name; res != "CT Smoke Grenade from T Spawn to Window" {
+		t.Error("Cluster failed on the name, got ", res)
+	}
+
+	stats := l.Stats()
+	if stats.Throws != 3 || stats.Matches != 2 || stats.Players != 2 || math.Abs(stats.Accuracy-2.0/3) > 1e-9 || stats.SuccessRate != stats.Accuracy {
+		t.Error("Stats failed, got ", stats)
+	}
+	if stats := lineups[2].Stats(); stats.SuccessRate != 1 || stats.EnemiesFlashed != 2 || stats.RoundsWon != 1 {
+		t.Error("Stats failed on a flash, got ", stats)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"csgo-parser-mongodb/lineup"
+	"csgo-parser-mongodb/reader"
+	"csgo-parser-mongodb/zones"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"os"
+	"strings"
+	"time"
+)
+
+// minedLineup is a line written by the lineups command.
+type minedLineup struct {
+	lineup.Lineup
+	Stats lineup.Stats
+}
+
+// runLineups mines the grenade lineups thrown in a match, or every match of its map registered in meta_info,
+// and writes them with how often and how well they were thrown as JSON lines, from the most thrown on.
+func runLineups(args []string) {
+	var mongoUri, dbName, zonesDir, side, weapon, out string
+	var all bool
+	var throwRadius, landingRadius float64
+	var minThrows int
+
+	flags := flag.NewFlagSet("lineups", flag.ExitOnError)
+	flags.StringVar(&mongoUri, "uri", "localhost:27017", "MongoDB connection URI.")
+	flags.StringVar(&dbName, "dbname", "test", "Database name of the parsed match.")
+	flags.BoolVar(&all, "all", false, "Mines every database registered in meta_info of the same map as the first one together.")
+	flags.StringVar(&zonesDir, "zones", "", "Folder of the maps' zones in JSON or GeoJSON to name lineups after, for matches stored without callouts.")
+	flags.StringVar(&side, "side", "", "Mines only the lineups of this side, t or ct. Empty mines both.")
+	flags.StringVar(&weapon, "weapon", "", "Mines only the lineups of this grenade, e.g. smokegrenade or Smoke Grenade. Empty mines every grenade.")
+	flags.Float64Var(&throwRadius, "throwradius", 32, "How far apart in world units throws may be thrown from to be the same lineup.")
+	flags.Float64Var(&landingRadius, "landingradius", 150, "How far apart in world units throws may detonate to be the same lineup.")
+	flags.IntVar(&minThrows, "min", 2, "Leaves out lineups thrown fewer times.")
+	flags.StringVar(&out, "out", "lineups.json", "File to write the lineups to as JSON lines.")
+	checkError(flags.Parse(args))
+
+	team, err := parseSide(side)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	var grenade common.EquipmentElement
+	if weapon != "" {
+		if grenade = parseWeapon(weapon); grenade.Class() != common.EqClassGrenade || grenade == common.EqDecoy {
+			fmt.Printf("Unknown grenade %q, must be a smoke, flash, HE, molotov or incendiary.\n", weapon)
+			os.Exit(2)
+		}
+	}
+	if throwRadius <= 0 || landingRadius <= 0 {
+		fmt.Println("-throwradius and -landingradius must be positive.")
+		os.Exit(2)
+	}
+	var zoneRegistry zones.Registry
+	if zonesDir != "" {
+		if zoneRegistry, err = zones.LoadDir(zonesDir); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+
+	client := connect_to_mongo("mongodb://"+mongoUri, 2*time.Second)
+	defer close_connection_to_mongo(client)
+
+	var throws []lineup.Throw
+	mapName := ""
+	matches := 0
+	for _, name := range databaseNames(client, dbName, all) {
+		match, err := reader.Load(reader.NewMongoSource(client.Database(name), clNames))
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			continue
+		}
+		if mapName == "" {
+			mapName = match.Header.MapName
+		} else if !strings.EqualFold(match.Header.MapName, mapName) {
+			continue
+		}
+		matchThrows, err := match.GrenadeThrows(zoneRegistry)
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			continue
+		}
+		matches++
+		for _, t := range matchThrows {
+			if team != common.TeamUnassigned && t.Team != team || grenade != common.EqUnknown && t.Weapon != grenade {
+				continue
+			}
+			throws = append(throws, lineup.Throw{Match: name, GrenadeThrow: t})
+		}
+	}
+
+	f, err := os.Create(out)
+	checkError(err)
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	written := 0
+	for _, l := range lineup.Cluster(throws, throwRadius, landingRadius) {
+		if len(l.Throws) < minThrows {
+			continue
+		}
+		stats := l.Stats()
+		checkError(enc.Encode(minedLineup{l, stats}))
+		fmt.Printf("%s: %d throws in %d matches by %d players, %.0f%% successful\n",
+			l.Name, stats.Throws, stats.Matches, stats.Players, 100*stats.SuccessRate)
+		written++
+	}
+	fmt.Printf("Mined %d lineups from %d throws of %d matches of %s to %s.\n", written, len(throws), matches, mapName, out)
+}
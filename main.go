@@ -2,46 +2,148 @@ package main
 
 import (
 	"csgo-parser-mongodb/app"
+	"csgo-parser-mongodb/overview"
+	"csgo-parser-mongodb/zones"
+	"csgo-parser-mongodb/util/codec"
 	"fmt"
 	"os"
 	"flag"
+	"strings"
 	"time"
 )
 
-var clNames = map[app.ClIndex]string {
-	app.ClEntities: "entities",
-	app.ClEvents: "events",
-	app.ClPlayers: "players",
-	app.ClPositions: "players_positions",
-	app.ClProjectiles: "grenades_positions",
-	app.ClInfernos: "current_infernos",
-	app.ClHeader: "header",
-	app.ClGameState: "game_states",
-	app.ClReplays: "replays",
-}
-
-func correctFramerate(x int) bool {
-	return (x >= 16) && (x <= 128) && (x != 0) && ((x & (x - 1)) == 0)
-}
+var clNames = app.DefaultCollectionNames
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
 	var pathToDemoFile, mongoUri, dbName string
-	var gameStateFreq, frameRate int
+	var gameStateFreq, gameStateKeyframes int
+	var frameRate float64
 	var eliasEncoding bool
+	var positionCodec, viewCodec, grenadeCodec string
+	var positionPrecision int
+	var sampling app.AdaptiveSampling
+	var overviewsDir string
+	var radar bool
+	var zonesDir string
+	var callouts bool
+	codecs := app.DefaultStreamCodecs
 
 	flag.StringVar(&pathToDemoFile,"dpath", "none", "Path to the .dem file to parse.")
 	flag.StringVar(&mongoUri, "uri", "localhost:27017", "MongoDB connection URI.")
 	flag.StringVar(&dbName, "dbname", "test", "Database name for parsed data.")
 
-	flag.IntVar(&frameRate,"framerate", 32, "Saves players' and grenades' positions with specified framerate. Possible values: 16, 32, 64 or 128. Cannot be greater than demo's original framerate.")
+	flag.Float64Var(&frameRate, "framerate", 32, "Saves players' and grenades' positions this many times per second of game time, e.g. 20, 32 or 102.4. Positions are saved every frame if it is greater than demo's original framerate.")
 	flag.IntVar(&gameStateFreq, "gamestate", 32, "Saves a full game state every _ frames.")
+	flag.IntVar(&gameStateKeyframes, "gamestatekeyframes", 0, "Saves every _-th game state in full and only what changed in the ones in between, read back with the reader package. 0 saves them all in full.")
+
+	flag.BoolVar(&eliasEncoding, "elias", false, "Saves position and view angle info as Elias Delta code. Greatly diminishes disk space using, but also forces data to be stored in human-unreadable and complicated format that has to be decoded later on, see the decode command. Experimental feature.")
+
+	codecNames := strings.Join(codec.Names(), ", ")
+	flag.StringVar(&positionCodec, "poscodec", "gamma", "Codec of encoded positions, one of "+codecNames+". Only used with -elias.")
+	flag.StringVar(&viewCodec, "viewcodec", "gamma", "Codec of encoded view angles, one of "+codecNames+". Only used with -elias.")
+	flag.StringVar(&grenadeCodec, "grenadecodec", "gamma", "Codec of encoded grenade trajectories, one of "+codecNames+". Only used with -elias.")
 
-	flag.BoolVar(&eliasEncoding, "elias", false, "Saves position and view angle info as Elias Delta code. Greatly diminishes disk space using, but also forces data to be stored in human-unreadable and complicated format with need of decoding later on. Experimental feature.")
+	flag.IntVar(&codecs.PositionOrder, "posorder", codecs.PositionOrder, "Prediction order of encoded positions: 0 stores the values, 1 their deltas, 2 the deltas of the deltas. Only used with -elias.")
+	flag.IntVar(&codecs.ViewOrder, "vieworder", codecs.ViewOrder, "Prediction order of encoded view angles, see -posorder. Only used with -elias.")
+	flag.IntVar(&codecs.GrenadeOrder, "grenadeorder", codecs.GrenadeOrder, "Prediction order of encoded grenade trajectories, see -posorder. Only used with -elias.")
+
+	flag.IntVar(&codecs.ViewPrecision, "viewprecision", codecs.ViewPrecision, fmt.Sprintf("Stores view angles in fixed point with this many bits after the point instead of whole degrees, at most %d.", app.MaxViewPrecision))
+
+	flag.IntVar(&codecs.KeyframeInterval, "keyframes", app.DefaultKeyframeInterval, "Stores a keyframe every _ samples of encoded streams, so replays can seek without decoding them from the start. 0 stores none. Only used with -elias.")
+
+	flag.IntVar(&sampling.MaxInterval, "maxinterval", 0, "Samples a player's movement only when they moved more than -posthreshold or turned more than -viewthreshold since their last sample, or at least every _ saved frames. The frames in between are interpolated when read back. 0 samples every saved frame. Needs -elias.")
+	flag.Float64Var(&sampling.PositionThreshold, "posthreshold", 1, "Distance in units a player has to move to be sampled before -maxinterval.")
+	flag.Float64Var(&sampling.ViewThreshold, "viewthreshold", 0.5, "Angle in degrees a player has to turn to be sampled before -maxinterval.")
+
+	flag.IntVar(&positionPrecision, "posprecision", 0, fmt.Sprintf("Stores positions in fixed point with this many bits after the point instead of whole units, at most %d. E.g. 3 stores them to 1/8 of a unit.", app.MaxPositionPrecision))
+
+	flag.StringVar(&overviewsDir, "overviews", "", "Folder of the maps' overview files, e.g. csgo/resource/overviews. The overview of the parsed map is stored into the header, to convert positions to and from the radar with.")
+	flag.BoolVar(&radar, "radar", false, "Stores positions with their position on the radar too. Needs -overviews. Positions encoded with -elias can only be converted once read back.")
+	flag.StringVar(&zonesDir, "zones", "", "Folder of the maps' zone files, *.json or *.geojson. Kills, hurts and bomb plants and defuses are stored with the callouts of the places their players are in.")
+	flag.BoolVar(&callouts, "callouts", false, "Stores positions of players with the callouts of their places too. Needs -zones. Positions encoded with -elias can only be tagged once read back.")
 
 	flag.Parse()
 
-	if !correctFramerate(frameRate) {
-		fmt.Printf("Incorrect requested framerate: %d. Must be 16, 32, 64 or 128.", frameRate)
+	if err := app.CheckPositionPrecision(positionPrecision); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if err := app.CheckViewPrecision(codecs.ViewPrecision); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if err := app.CheckAdaptiveSampling(sampling); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if sampling.MaxInterval > 0 && !eliasEncoding {
+		fmt.Println("Adaptive sampling needs -elias, positions stored per frame have no room for the frames in between.")
+		os.Exit(2)
+	}
+	if gameStateKeyframes < 0 {
+		fmt.Printf("Incorrect game state keyframe interval: %d. Must not be negative.\n", gameStateKeyframes)
+		os.Exit(2)
+	}
+	if err := codec.CheckInterval(codecs.KeyframeInterval); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	for _, order := range []int{codecs.PositionOrder, codecs.ViewOrder, codecs.GrenadeOrder} {
+		if err := codec.CheckOrder(order); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+	for _, c := range []struct {
+		name string
+		id   *codec.ID
+	}{{positionCodec, &codecs.Position}, {viewCodec, &codecs.View}, {grenadeCodec, &codecs.Grenade}} {
+		selected, err := codec.ByName(c.name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		*c.id = selected.ID()
+	}
+
+	if err := app.CheckSampleRate(frameRate); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if radar && (overviewsDir == "" || eliasEncoding) {
+		fmt.Println("Radar positions need -overviews and can't be stored with -elias.")
+		os.Exit(2)
+	}
+	var overviews overview.Registry
+	if overviewsDir != "" {
+		var err error
+		if overviews, err = overview.LoadDir(overviewsDir); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+	if callouts && (zonesDir == "" || eliasEncoding) {
+		fmt.Println("Callouts of positions need -zones and can't be stored with -elias.")
+		os.Exit(2)
+	}
+	var zoneRegistry zones.Registry
+	if zonesDir != "" {
+		var err error
+		if zoneRegistry, err = zones.LoadDir(zonesDir); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+	if gameStateFreq <= 0 {
+		fmt.Printf("Incorrect game state frequency: %d. Must be positive.\n", gameStateFreq)
+		os.Exit(2)
 	}
 
 	if pathToDemoFile == "none" {
@@ -58,7 +160,7 @@ func main() {
 	client := connect_to_mongo(mongoUri, 2*time.Second)
 	defer close_connection_to_mongo(client)
 
-	application := app.NewApplication(f, client, dbName, clNames, eliasEncoding, gameStateFreq, frameRate)
+	application := app.NewApplication(f, client, dbName, clNames, eliasEncoding, codecs, positionPrecision, sampling, gameStateFreq, gameStateKeyframes, frameRate, overviews, radar, zoneRegistry, callouts)
 	application.Init()
 	t1 := time.Now()
 	application.Parse()
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"csgo-parser-mongodb/reader"
+	"csgo-parser-mongodb/util/codec"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// sampledSeries are the values of one coordinate sampled at consecutive frames.
+type sampledSeries [][]int
+
+// seriesRun collects the coordinates of a player or grenade while it is sampled at consecutive frames.
+type seriesRun struct {
+	lastFrame int
+	values    [][]int
+}
+
+// seriesCollector splits the coordinates of players or grenades into runs of consecutive frames.
+type seriesCollector struct {
+	runs   map[int64]*seriesRun
+	series sampledSeries
+}
+
+func newSeriesCollector() *seriesCollector {
+	return &seriesCollector{runs: make(map[int64]*seriesRun)}
+}
+
+func (c *seriesCollector) add(id int64, frame int, coordinates ...int) {
+	run, ok := c.runs[id]
+	if ok && run.lastFrame+1 != frame {
+		c.series = append(c.series, run.values...)
+		ok = false
+	}
+	if !ok {
+		run = &seriesRun{values: make([][]int, len(coordinates))}
+		c.runs[id] = run
+	}
+	for i, v := range coordinates {
+		run.values[i] = append(run.values[i], v)
+	}
+	run.lastFrame = frame
+}
+
+func (c *seriesCollector) done() sampledSeries {
+	for id, run := range c.runs {
+		c.series = append(c.series, run.values...)
+		delete(c.runs, id)
+	}
+	return c.series
+}
+
+// runMeasure re-encodes the stored positions of matches with every codec and prediction order
+// and prints how many bits a sample takes with each, to pick the ones to parse the archive with.
+func runMeasure(args []string) {
+	var mongoUri, dbName string
+	var all bool
+
+	flags := flag.NewFlagSet("measure", flag.ExitOnError)
+	flags.StringVar(&mongoUri, "uri", "localhost:27017", "MongoDB connection URI.")
+	flags.StringVar(&dbName, "dbname", "test", "Database name of the parsed match.")
+	flags.BoolVar(&all, "all", false, "Measures every database registered in meta_info together.")
+	checkError(flags.Parse(args))
+
+	client := connect_to_mongo("mongodb://"+mongoUri, 2*time.Second)
+	defer close_connection_to_mongo(client)
+
+	streams := []struct {
+		name   string
+		series sampledSeries
+	}{{name: "positions"}, {name: "yaw"}, {name: "pitch"}, {name: "grenades"}}
+
+	for _, name := range databaseNames(client, dbName, all) {
+		match, err := reader.Load(reader.NewMongoSource(client.Database(name), clNames))
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			continue
+		}
+
+		positions, yaws, pitches := newSeriesCollector(), newSeriesCollector(), newSeriesCollector()
+		positionIt, err := match.Positions()
+		checkError(err)
+		for positionIt.Next() {
+			FP := positionIt.Value()
+			for _, PMI := range FP.PlayersPositions {
+				positions.add(PMI.SteamID, FP.FrameNumber, int(PMI.Position.X), int(PMI.Position.Y), int(PMI.Position.Z))
+				yaws.add(PMI.SteamID, FP.FrameNumber, int(PMI.ViewX))
+				pitches.add(PMI.SteamID, FP.FrameNumber, int(PMI.ViewY))
+			}
+		}
+		checkError(positionIt.Err())
+		checkError(positionIt.Close())
+
+		grenades := newSeriesCollector()
+		projectileIt, err := match.Projectiles()
+		checkError(err)
+		for projectileIt.Next() {
+			FP := projectileIt.Value()
+			for _, GPI := range FP.GrenadesPositions {
+				grenades.add(GPI.UniqueID, FP.FrameNumber, int(GPI.Position.X), int(GPI.Position.Y), int(GPI.Position.Z))
+			}
+		}
+		checkError(projectileIt.Err())
+		checkError(projectileIt.Close())
+
+		streams[0].series = append(streams[0].series, positions.done()...)
+		// view angles are measured in whole degrees, the way the encoder maps them
+		for _, values := range yaws.done() {
+			streams[1].series = append(streams[1].series, codec.UnwrapAngles(values, 360))
+		}
+		for _, values := range pitches.done() {
+			streams[2].series = append(streams[2].series, codec.SignedAngles(values, 360))
+		}
+		streams[3].series = append(streams[3].series, grenades.done()...)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "stream\tcodec\torder\tbits/sample")
+	for _, stream := range streams {
+		var samples int
+		for _, values := range stream.series {
+			samples += len(values)
+		}
+		if samples == 0 {
+			continue
+		}
+		for _, name := range codec.Names() {
+			c, err := codec.ByName(name)
+			checkError(err)
+			for order := 0; order <= codec.MaxOrder; order++ {
+				var bits uint64
+				for _, values := range stream.series {
+					bits += c.Encode(codec.Residuals(values, order)).Len()
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\t%.2f\n", stream.name, name, order, float64(bits)/float64(samples))
+			}
+		}
+	}
+	checkError(w.Flush())
+}
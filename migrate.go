@@ -0,0 +1,36 @@
+package main
+
+import (
+	"csgo-parser-mongodb/app"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// runMigrate upgrades databases produced by older parser versions to the current schema version.
+func runMigrate(args []string) {
+	var mongoUri, dbName string
+	var all bool
+
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.StringVar(&mongoUri, "uri", "localhost:27017", "MongoDB connection URI.")
+	flags.StringVar(&dbName, "dbname", "test", "Database name to migrate.")
+	flags.BoolVar(&all, "all", false, "Migrates every database registered in meta_info.")
+	checkError(flags.Parse(args))
+
+	client := connect_to_mongo("mongodb://"+mongoUri, 2*time.Second)
+	defer close_connection_to_mongo(client)
+
+	for _, name := range databaseNames(client, dbName, all) {
+		from, err := app.Migrate(client.Database(name), clNames)
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			continue
+		}
+		if from == app.SchemaVersion {
+			fmt.Printf("%s: already at schema version %d.\n", name, from)
+		} else {
+			fmt.Printf("%s: migrated from schema version %d to %d.\n", name, from, app.SchemaVersion)
+		}
+	}
+}
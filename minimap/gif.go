@@ -0,0 +1,204 @@
+package minimap
+
+import (
+	"github.com/golang/geo/r2"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+	"sort"
+)
+
+// sizes of what is drawn, in pixels of a scene 512 pixels wide; they grow and shrink with the scene
+const (
+	playerRadius  = 4.5
+	coneLength    = 28
+	coneAngle     = 50
+	grenadeRadius = 2.5
+	killSize      = 4
+	lineWidth     = 1.2
+)
+
+var (
+	backgroundColor = color.NRGBA{32, 32, 32, 255}
+	outlineColor    = color.NRGBA{0, 0, 0, 255}
+	infernoColor    = color.NRGBA{255, 110, 0, 110}
+)
+
+// teamColor returns the color players of a team are drawn in, with the given alpha.
+func teamColor(team common.Team, alpha uint8) color.NRGBA {
+	switch team {
+	case common.TeamTerrorists:
+		return color.NRGBA{234, 170, 52, alpha}
+	case common.TeamCounterTerrorists:
+		return color.NRGBA{93, 145, 220, alpha}
+	}
+	return color.NRGBA{170, 170, 170, alpha}
+}
+
+// grenadeColor returns the color a grenade is drawn in.
+func grenadeColor(weapon common.EquipmentElement) color.NRGBA {
+	switch weapon {
+	case common.EqSmoke:
+		return color.NRGBA{210, 210, 210, 255}
+	case common.EqFlash:
+		return color.NRGBA{255, 255, 160, 255}
+	case common.EqHE:
+		return color.NRGBA{230, 60, 60, 255}
+	case common.EqMolotov, common.EqIncendiary:
+		return color.NRGBA{255, 120, 0, 255}
+	case common.EqDecoy:
+		return color.NRGBA{160, 120, 80, 255}
+	}
+	return color.NRGBA{255, 255, 255, 255}
+}
+
+// cone returns the outline of the view cone of a player.
+func cone(p Player, length float64) []r2.Point {
+	const steps = 8
+	points := []r2.Point{p.Position}
+	for i := 0; i <= steps; i++ {
+		a := (p.Yaw - coneAngle/2 + coneAngle*float64(i)/steps) * math.Pi / 180
+		// the scene's y axis points down
+		points = append(points, p.Position.Add(r2.Point{X: math.Cos(a), Y: -math.Sin(a)}.Mul(length)))
+	}
+	return points
+}
+
+// canvas blends shapes onto an image.
+type canvas struct {
+	img *image.RGBA
+}
+
+func (c canvas) blend(x, y int, col color.NRGBA) {
+	if !(image.Point{x, y}).In(c.img.Rect) {
+		return
+	}
+	i := c.img.PixOffset(x, y)
+	a := uint32(col.A)
+	pix := c.img.Pix[i : i+4]
+	pix[0] = uint8((uint32(col.R)*a + uint32(pix[0])*(255-a)) / 255)
+	pix[1] = uint8((uint32(col.G)*a + uint32(pix[1])*(255-a)) / 255)
+	pix[2] = uint8((uint32(col.B)*a + uint32(pix[2])*(255-a)) / 255)
+	pix[3] = uint8(a + uint32(pix[3])*(255-a)/255)
+}
+
+// fillPolygon fills the pixels whose centers are inside the polygon, by the even-odd rule.
+func (c canvas) fillPolygon(points []r2.Point, col color.NRGBA) {
+	if len(points) < 3 {
+		return
+	}
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	b := c.img.Rect
+	var xs []float64
+	for y := int(math.Max(math.Floor(minY), float64(b.Min.Y))); y < int(math.Min(math.Ceil(maxY), float64(b.Max.Y))); y++ {
+		cy := float64(y) + 0.5
+		xs = xs[:0]
+		for i, j := 0, len(points)-1; i < len(points); j, i = i, i+1 {
+			a, b := points[i], points[j]
+			if (a.Y > cy) != (b.Y > cy) {
+				xs = append(xs, a.X+(cy-a.Y)*(b.X-a.X)/(b.Y-a.Y))
+			}
+		}
+		sort.Float64s(xs)
+		for k := 0; k+1 < len(xs); k += 2 {
+			for x := int(math.Ceil(xs[k] - 0.5)); float64(x)+0.5 < xs[k+1]; x++ {
+				c.blend(x, y, col)
+			}
+		}
+	}
+}
+
+func (c canvas) fillCircle(center r2.Point, radius float64, col color.NRGBA) {
+	for y := int(math.Floor(center.Y - radius)); y <= int(math.Ceil(center.Y+radius)); y++ {
+		for x := int(math.Floor(center.X - radius)); x <= int(math.Ceil(center.X+radius)); x++ {
+			if (r2.Point{X: float64(x) + 0.5, Y: float64(y) + 0.5}).Sub(center).Norm() <= radius {
+				c.blend(x, y, col)
+			}
+		}
+	}
+}
+
+// line draws a line of the given width as the rectangle around it.
+func (c canvas) line(a, b r2.Point, width float64, col color.NRGBA) {
+	d := b.Sub(a)
+	if d.Norm() == 0 {
+		return
+	}
+	n := d.Ortho().Normalize().Mul(width / 2)
+	c.fillPolygon([]r2.Point{a.Add(n), b.Add(n), b.Sub(n), a.Sub(n)}, col)
+}
+
+// Draw renders a frame of the scene over its background.
+func (s Scene) Draw(F Frame, background *image.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, s.Width, s.Height))
+	copy(img.Pix, background.Pix)
+	c := canvas{img}
+	k := float64(s.Width) / 512
+
+	for _, INF := range F.Infernos {
+		c.fillPolygon(INF.Hull, infernoColor)
+	}
+	for _, G := range F.Grenades {
+		col := grenadeColor(G.Weapon)
+		trail := col
+		trail.A = 140
+		for i := 1; i < len(G.Trail); i++ {
+			c.line(G.Trail[i-1], G.Trail[i], lineWidth*k, trail)
+		}
+		c.fillCircle(G.Position, grenadeRadius*k, col)
+	}
+	for _, K := range F.Kills {
+		col := teamColor(K.Team, 255)
+		d := killSize * k
+		c.line(K.Position.Add(r2.Point{X: -d, Y: -d}), K.Position.Add(r2.Point{X: d, Y: d}), 2*lineWidth*k, col)
+		c.line(K.Position.Add(r2.Point{X: -d, Y: d}), K.Position.Add(r2.Point{X: d, Y: -d}), 2*lineWidth*k, col)
+	}
+	for _, P := range F.Players {
+		c.fillPolygon(cone(P, coneLength*k), teamColor(P.Team, 70))
+	}
+	for _, P := range F.Players {
+		c.fillCircle(P.Position, (playerRadius+1)*k, outlineColor)
+		c.fillCircle(P.Position, playerRadius*k, teamColor(P.Team, 255))
+	}
+	return img
+}
+
+// background renders the background of the scene, stretched over it.
+func (s Scene) background() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, s.Width, s.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(backgroundColor), image.Point{}, draw.Src)
+	if s.Background != nil {
+		b := s.Background.Bounds()
+		for y := 0; y < s.Height; y++ {
+			for x := 0; x < s.Width; x++ {
+				img.Set(x, y, s.Background.At(b.Min.X+x*b.Dx()/s.Width, b.Min.Y+y*b.Dy()/s.Height))
+			}
+		}
+	}
+	return img
+}
+
+// EncodeGIF writes the scene as an animated GIF that loops forever.
+// Frames are reduced to the Plan 9 palette, which keeps the radar images readable.
+func (s Scene) EncodeGIF(w io.Writer) error {
+	background := s.background()
+	// GIFs count in hundredths of a second and browsers slow down anything faster than 2 of them
+	delay := int(math.Max(2, math.Round(s.FrameDuration.Seconds()*100)))
+	anim := &gif.GIF{}
+	for _, F := range s.Frames {
+		img := s.Draw(F, background)
+		paletted := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.Draw(paletted, img.Bounds(), img, image.Point{}, draw.Src)
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
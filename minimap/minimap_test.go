@@ -0,0 +1,115 @@
+package minimap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/golang/geo/r2"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pt returns a point of a 64 pixels wide grid in a scene of 512 pixels, where shapes have their intended sizes.
+func pt(x, y float64) r2.Point {
+	return r2.Point{X: 8 * x, Y: 8 * y}
+}
+
+func testScene() Scene {
+	return Scene{
+		Width: 512, Height: 512,
+		FrameDuration: 125 * time.Millisecond,
+		Frames: []Frame{
+			{
+				Players:  []Player{{1, common.TeamTerrorists, pt(10, 10), 0}},
+				Grenades: []Grenade{{7, common.EqSmoke, pt(40, 40), []r2.Point{pt(40, 40)}}},
+			},
+			{
+				Players:  []Player{{1, common.TeamTerrorists, pt(12, 10), 90}, {2, common.TeamCounterTerrorists, pt(50, 10), 180}},
+				Grenades: []Grenade{{7, common.EqSmoke, pt(44, 40), []r2.Point{pt(40, 40), pt(44, 40)}}},
+				Infernos: []Inferno{{9, []r2.Point{pt(20, 50), pt(30, 50), pt(30, 60), pt(20, 60)}}},
+			},
+			{
+				Players: []Player{{1, common.TeamTerrorists, pt(14, 10), 90}},
+				Kills:   []Kill{{2, common.TeamCounterTerrorists, pt(50, 10)}},
+			},
+		},
+	}
+}
+
+func TestDraw(t *testing.T) {
+	s := testScene()
+	background := s.background()
+	if res := background.RGBAAt(0, 0); res != (color.RGBA{32, 32, 32, 255}) {
+		t.Error("background failed, got ", res)
+	}
+
+	img := s.Draw(s.Frames[1], background)
+	if res, expected := img.RGBAAt(96, 80), teamColor(common.TeamTerrorists, 255); res != (color.RGBA{expected.R, expected.G, expected.B, 255}) {
+		t.Error("Draw failed on a player, got ", res, " instead of ", expected)
+	}
+	if res := img.RGBAAt(200, 440); res.R <= res.G || res.G <= res.B {
+		t.Error("Draw failed on an inferno, got ", res)
+	}
+	// the first player looks up, the cone is above and not below them
+	if above, below := img.RGBAAt(96, 60), img.RGBAAt(96, 100); above == below || below != background.RGBAAt(96, 100) {
+		t.Error("Draw failed on a view cone, got ", above, " above and ", below, " below")
+	}
+	if res := img.RGBAAt(40, 320); res != background.RGBAAt(40, 320) {
+		t.Error("Draw failed to leave the background, got ", res)
+	}
+}
+
+func TestEncodeGIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testScene().EncodeGIF(&buf); err != nil {
+		t.Fatal("EncodeGIF failed: ", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal("EncodeGIF failed to write a GIF: ", err)
+	}
+	if len(anim.Image) != 3 || anim.Delay[0] != 13 || anim.LoopCount != 0 {
+		t.Error("EncodeGIF failed, got ", len(anim.Image), " frames shown for ", anim.Delay, " looping ", anim.LoopCount)
+	}
+	if res := anim.Image[0].Bounds(); res != image.Rect(0, 0, 512, 512) {
+		t.Error("EncodeGIF failed, got bounds ", res)
+	}
+}
+
+func TestEncodeSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testScene().EncodeSVG(&buf); err != nil {
+		t.Fatal("EncodeSVG failed: ", err)
+	}
+	svg := buf.String()
+
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	elements := make(map[string]int)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal("EncodeSVG failed to write XML: ", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			elements[start.Name.Local]++
+		}
+	}
+	// 2 players and a grenade with their markers, an inferno and a kill
+	if elements["circle"] != 3 || elements["polygon"] != 1 || elements["polyline"] != 1 || elements["path"] != 3 {
+		t.Error("EncodeSVG failed, got elements ", elements)
+	}
+	if !strings.Contains(svg, `dur="0.375s"`) || !strings.Contains(svg, `values="hidden;visible;hidden"`) || !strings.Contains(svg, `values="hidden;hidden;visible"`) {
+		t.Error("EncodeSVG failed to animate the frames")
+	}
+	// the second player keeps their last position once dead
+	if !strings.Contains(svg, `values="400.0;400.0;400.0"`) {
+		t.Error("EncodeSVG failed to hold the position of a hidden player")
+	}
+}
@@ -0,0 +1,185 @@
+// Package minimap animates rounds top-down over the radar of their map, the way the game's minimap shows them,
+// to export as animated GIF or SVG.
+package minimap
+
+import (
+	"csgo-parser-mongodb/app"
+	"csgo-parser-mongodb/overview"
+	"csgo-parser-mongodb/reader"
+	"fmt"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"image"
+	"math"
+	"time"
+)
+
+// Scene is an animation of a round. Positions are in pixels of the scene.
+type Scene struct {
+	Width, Height int
+	// Background is drawn stretched over the scene, e.g. the radar image of the map. It may be nil.
+	Background image.Image
+	// FrameDuration is how long every frame is shown
+	FrameDuration time.Duration
+	Frames        []Frame
+}
+
+// Frame is what the animation shows at a moment of the round.
+type Frame struct {
+	Players  []Player
+	Grenades []Grenade
+	Infernos []Inferno
+	// Kills are the kills of the round up to the frame
+	Kills []Kill
+}
+
+// Player is a player alive in a frame.
+type Player struct {
+	SteamID  int64
+	Team     common.Team
+	Position r2.Point
+	// Yaw is the direction the player looks in, in degrees counterclockwise from the right of the scene
+	Yaw float64
+}
+
+// Grenade is a grenade in flight with the way it has flown so far.
+type Grenade struct {
+	UniqueID int64
+	Weapon   common.EquipmentElement
+	Position r2.Point
+	Trail    []r2.Point
+}
+
+// Inferno is the area a molotov or incendiary grenade burns.
+type Inferno struct {
+	UniqueID int64
+	Hull     []r2.Point
+}
+
+// Kill marks where a player died.
+type Kill struct {
+	Victim int64
+	// Team is the team of the victim
+	Team     common.Team
+	Position r2.Point
+}
+
+// radarSize is the size in pixels radar coordinates are given in.
+const radarSize = 1024
+
+// Build animates a round of a match over a square scene of the given size in pixels, at about fps frames
+// per second of game time. Positions are converted to the scene with the overview of the match's map.
+func Build(m *reader.Match, round int, OV overview.Map, size int, fps float64) (Scene, error) {
+	if size <= 0 || fps <= 0 {
+		return Scene{}, fmt.Errorf("scene needs a positive size and frame rate, got %d and %v", size, fps)
+	}
+	var r *reader.Round
+	for i := range m.Rounds {
+		if m.Rounds[i].Number == round {
+			r = &m.Rounds[i]
+		}
+	}
+	if r == nil || r.StartFrame < 0 {
+		return Scene{}, fmt.Errorf("match has no start of round %d", round)
+	}
+	end := r.EndFrame
+	if end < 0 {
+		return Scene{}, fmt.Errorf("round %d has no end", round)
+	}
+	rate, err := m.SampleRate()
+	if err != nil {
+		return Scene{}, err
+	}
+	step := int(math.Max(1, math.Round(rate/fps)))
+
+	seeker, err := m.Seeker()
+	if err != nil {
+		return Scene{}, err
+	}
+	teams, err := m.Teams()
+	if err != nil {
+		return Scene{}, err
+	}
+	weapons := make(map[int64]common.EquipmentElement, len(m.Entities))
+	for _, e := range m.Entities {
+		weapons[e.UniqueID] = e.Weapon
+	}
+	infernos, err := roundInfernos(m, r.StartFrame, end)
+	if err != nil {
+		return Scene{}, err
+	}
+
+	scale := float64(size) / radarSize
+	toScene := func(v r3.Vector) r2.Point {
+		p := OV.ToRadar(v)
+		return r2.Point{X: p.X * scale, Y: p.Y * scale}
+	}
+	var kills []reader.Event
+	for _, ev := range m.EventsOfType(app.Kill) {
+		if data := ev.Data.(*reader.KillData); ev.RoundNumber == round && data.VictimMovement != nil {
+			kills = append(kills, ev)
+		}
+	}
+
+	scene := Scene{Width: size, Height: size, FrameDuration: time.Duration(float64(step) / rate * float64(time.Second))}
+	trails := make(map[int64][]r2.Point)
+	for frame := r.StartFrame; frame <= end; frame++ {
+		projectiles, err := seeker.ProjectilesAt(frame)
+		if err != nil {
+			return Scene{}, err
+		}
+		flying := make(map[int64][]r2.Point, len(projectiles.GrenadesPositions))
+		for _, GPI := range projectiles.GrenadesPositions {
+			flying[GPI.UniqueID] = append(trails[GPI.UniqueID], toScene(m.Position(GPI.Position)))
+		}
+		trails = flying
+		if (frame-r.StartFrame)%step != 0 {
+			continue
+		}
+
+		var F Frame
+		positions, err := seeker.PositionsAt(frame)
+		if err != nil {
+			return Scene{}, err
+		}
+		for _, PMI := range positions.PlayersPositions {
+			F.Players = append(F.Players, Player{PMI.SteamID, teams.Team(round, PMI.SteamID), toScene(m.Position(PMI.Position)), float64(PMI.ViewX)})
+		}
+		for _, GPI := range projectiles.GrenadesPositions {
+			trail := trails[GPI.UniqueID]
+			F.Grenades = append(F.Grenades, Grenade{GPI.UniqueID, weapons[GPI.UniqueID], trail[len(trail)-1], append([]r2.Point(nil), trail...)})
+		}
+		for _, INF := range infernos[frame] {
+			hull := make([]r2.Point, len(INF.ConvexHull2D))
+			for i, p := range INF.ConvexHull2D {
+				hull[i] = toScene(r3.Vector{X: p.X, Y: p.Y})
+			}
+			F.Infernos = append(F.Infernos, Inferno{INF.UniqueID, hull})
+		}
+		for _, ev := range kills {
+			if ev.FrameNumber <= frame {
+				data := ev.Data.(*reader.KillData)
+				F.Kills = append(F.Kills, Kill{data.Victim, teams.Team(round, data.Victim), toScene(m.Position(data.VictimMovement.Position))})
+			}
+		}
+		scene.Frames = append(scene.Frames, F)
+	}
+	return scene, nil
+}
+
+// roundInfernos returns the infernos burning in the frames from start to end, by frame.
+func roundInfernos(m *reader.Match, start, end int) (map[int][]app.InfernoInfo, error) {
+	it, err := m.Infernos()
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	res := make(map[int][]app.InfernoInfo)
+	for it.Next() {
+		if FI := it.Value(); FI.FrameNumber >= start && FI.FrameNumber <= end {
+			res[FI.FrameNumber] = FI.CurrentInfernos
+		}
+	}
+	return res, it.Err()
+}
@@ -0,0 +1,240 @@
+package minimap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/golang/geo/r2"
+	"image/color"
+	"image/png"
+	"io"
+	"sort"
+	"strings"
+)
+
+// svgWriter writes the elements of an SVG scene, keeping the first error.
+type svgWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (s *svgWriter) printf(format string, args ...interface{}) {
+	if s.err == nil {
+		_, s.err = fmt.Fprintf(s.w, format, args...)
+	}
+}
+
+// track writes a discrete animation of an attribute, showing a value in every frame of the scene.
+func (s *svgWriter) track(attribute string, values []string, dur float64) {
+	keyTimes := make([]string, len(values))
+	for i := range values {
+		keyTimes[i] = fmt.Sprintf("%.4f", float64(i)/float64(len(values)))
+	}
+	s.printf(`<animate attributeName="%s" calcMode="discrete" dur="%.3fs" repeatCount="indefinite" values="%s" keyTimes="%s"/>`+"\n",
+		attribute, dur, strings.Join(values, ";"), strings.Join(keyTimes, ";"))
+}
+
+func svgColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func svgOpacity(c color.NRGBA) string {
+	return fmt.Sprintf("%.3f", float64(c.A)/255)
+}
+
+func svgPoints(points []r2.Point) string {
+	res := make([]string, len(points))
+	for i, p := range points {
+		res[i] = fmt.Sprintf("%.1f,%.1f", p.X, p.Y)
+	}
+	return strings.Join(res, " ")
+}
+
+func svgPath(points []r2.Point) string {
+	if len(points) == 0 {
+		return "M0,0"
+	}
+	return "M" + strings.Replace(svgPoints(points), " ", " L", -1) + " Z"
+}
+
+// visibility returns the visibility track of something shown in the frames marked.
+func visibility(shown []bool) []string {
+	res := make([]string, len(shown))
+	for i, s := range shown {
+		res[i] = "hidden"
+		if s {
+			res[i] = "visible"
+		}
+	}
+	return res
+}
+
+// hold fills the values of the frames something is not shown in with the last value it had,
+// or the first one it gets before it is shown, so hidden elements do not jump around.
+func hold(values []string, shown []bool) []string {
+	first := ""
+	for i, s := range shown {
+		if s {
+			first = values[i]
+			break
+		}
+	}
+	last := first
+	for i, s := range shown {
+		if s {
+			last = values[i]
+		} else {
+			values[i] = last
+		}
+	}
+	return values
+}
+
+// EncodeSVG writes the scene as an SVG animated with SMIL, which browsers play in a loop.
+// Every player, grenade, inferno and kill is one element whose attributes change from frame to frame.
+func (s Scene) EncodeSVG(w io.Writer) error {
+	out := &svgWriter{w: bufio.NewWriter(w)}
+	n := len(s.Frames)
+	dur := float64(n) * s.FrameDuration.Seconds()
+	k := float64(s.Width) / 512
+
+	out.printf(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		s.Width, s.Height, s.Width, s.Height)
+	out.printf(`<rect width="%d" height="%d" fill="%s"/>`+"\n", s.Width, s.Height, svgColor(backgroundColor))
+	if s.Background != nil {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, s.Background); err != nil {
+			return err
+		}
+		out.printf(`<image width="%d" height="%d" preserveAspectRatio="none" xlink:href="data:image/png;base64,%s"/>`+"\n",
+			s.Width, s.Height, base64.StdEncoding.EncodeToString(buf.Bytes()))
+	}
+	if n == 0 {
+		out.printf("</svg>\n")
+		if out.err != nil {
+			return out.err
+		}
+		return out.w.Flush()
+	}
+
+	// infernos
+	for _, id := range s.ids(func(F Frame) []int64 {
+		var res []int64
+		for _, INF := range F.Infernos {
+			res = append(res, INF.UniqueID)
+		}
+		return res
+	}) {
+		shown, points := make([]bool, n), make([]string, n)
+		for i, F := range s.Frames {
+			for _, INF := range F.Infernos {
+				if INF.UniqueID == id {
+					shown[i], points[i] = true, svgPoints(INF.Hull)
+				}
+			}
+		}
+		out.printf(`<polygon fill="%s" fill-opacity="%s">`+"\n", svgColor(infernoColor), svgOpacity(infernoColor))
+		out.track("visibility", visibility(shown), dur)
+		out.track("points", hold(points, shown), dur)
+		out.printf("</polygon>\n")
+	}
+
+	// grenades and their trails
+	for _, id := range s.ids(func(F Frame) []int64 {
+		var res []int64
+		for _, G := range F.Grenades {
+			res = append(res, G.UniqueID)
+		}
+		return res
+	}) {
+		shown, trails, xs, ys := make([]bool, n), make([]string, n), make([]string, n), make([]string, n)
+		var col color.NRGBA
+		for i, F := range s.Frames {
+			for _, G := range F.Grenades {
+				if G.UniqueID == id {
+					col = grenadeColor(G.Weapon)
+					shown[i], trails[i] = true, svgPoints(G.Trail)
+					xs[i], ys[i] = fmt.Sprintf("%.1f", G.Position.X), fmt.Sprintf("%.1f", G.Position.Y)
+				}
+			}
+		}
+		out.printf(`<g>`+"\n"+`<polyline fill="none" stroke="%s" stroke-opacity="0.55" stroke-width="%.2f">`+"\n", svgColor(col), lineWidth*k)
+		out.track("points", hold(trails, shown), dur)
+		out.printf("</polyline>\n"+`<circle r="%.2f" fill="%s">`+"\n", grenadeRadius*k, svgColor(col))
+		out.track("cx", hold(xs, shown), dur)
+		out.track("cy", hold(ys, shown), dur)
+		out.printf("</circle>\n")
+		out.track("visibility", visibility(shown), dur)
+		out.printf("</g>\n")
+	}
+
+	// kills stay marked from the frame they happened in on
+	for _, K := range s.Frames[n-1].Kills {
+		shown := make([]bool, n)
+		for i, F := range s.Frames {
+			for _, FK := range F.Kills {
+				shown[i] = shown[i] || FK.Victim == K.Victim
+			}
+		}
+		d := killSize * k
+		out.printf(`<path d="M%.1f,%.1f L%.1f,%.1f M%.1f,%.1f L%.1f,%.1f" stroke="%s" stroke-width="%.2f">`+"\n",
+			K.Position.X-d, K.Position.Y-d, K.Position.X+d, K.Position.Y+d,
+			K.Position.X-d, K.Position.Y+d, K.Position.X+d, K.Position.Y-d,
+			svgColor(teamColor(K.Team, 255)), 2*lineWidth*k)
+		out.track("visibility", visibility(shown), dur)
+		out.printf("</path>\n")
+	}
+
+	// players with their view cones
+	for _, id := range s.ids(func(F Frame) []int64 {
+		var res []int64
+		for _, P := range F.Players {
+			res = append(res, P.SteamID)
+		}
+		return res
+	}) {
+		shown, cones, xs, ys := make([]bool, n), make([]string, n), make([]string, n), make([]string, n)
+		var col color.NRGBA
+		for i, F := range s.Frames {
+			for _, P := range F.Players {
+				if P.SteamID == id {
+					col = teamColor(P.Team, 255)
+					shown[i], cones[i] = true, svgPath(cone(P, coneLength*k))
+					xs[i], ys[i] = fmt.Sprintf("%.1f", P.Position.X), fmt.Sprintf("%.1f", P.Position.Y)
+				}
+			}
+		}
+		out.printf(`<g>`+"\n"+`<path fill="%s" fill-opacity="0.275">`+"\n", svgColor(col))
+		out.track("d", hold(cones, shown), dur)
+		out.printf("</path>\n"+`<circle r="%.2f" fill="%s" stroke="%s" stroke-width="%.2f">`+"\n",
+			playerRadius*k, svgColor(col), svgColor(outlineColor), k)
+		out.track("cx", hold(xs, shown), dur)
+		out.track("cy", hold(ys, shown), dur)
+		out.printf("</circle>\n")
+		out.track("visibility", visibility(shown), dur)
+		out.printf("</g>\n")
+	}
+
+	out.printf("</svg>\n")
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
+
+// ids returns the distinct IDs of what the frames show, in order.
+func (s Scene) ids(of func(Frame) []int64) []int64 {
+	seen := make(map[int64]bool)
+	var res []int64
+	for _, F := range s.Frames {
+		for _, id := range of(F) {
+			if !seen[id] {
+				seen[id] = true
+				res = append(res, id)
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
@@ -0,0 +1,131 @@
+package overview
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode"
+)
+
+// keyValue is a node of Valve's KeyValues format: a key with either a value or a block of nodes.
+type keyValue struct {
+	key      string
+	value    string
+	children []keyValue
+	block    bool
+}
+
+// child returns the first node under the key, compared without case as the game does.
+func (kv keyValue) child(key string) (keyValue, bool) {
+	for _, c := range kv.children {
+		if strings.EqualFold(c.key, key) {
+			return c, true
+		}
+	}
+	return keyValue{}, false
+}
+
+// parseKeyValues reads the nodes of a KeyValues document.
+// Quoted and bare tokens are both accepted, // comments and [$PLATFORM] conditions are skipped.
+func parseKeyValues(r io.Reader) ([]keyValue, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := tokenize(string(data))
+	if err != nil {
+		return nil, err
+	}
+	nodes, rest, err := parseNodes(tokens, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("unexpected %q", rest[0].text)
+	}
+	return nodes, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func (t token) is(s string) bool {
+	return !t.quoted && t.text == s
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case strings.HasPrefix(s[i:], "//"):
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '{' || c == '}':
+			tokens = append(tokens, token{string(c), false})
+			i++
+		case c == '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated condition at offset %d", i)
+			}
+			i += end + 1
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, token{s[i+1 : i+1+end], true})
+			i += end + 2
+		default:
+			start := i
+			for i < len(s) && !unicode.IsSpace(rune(s[i])) && s[i] != '"' && s[i] != '{' && s[i] != '}' {
+				i++
+			}
+			tokens = append(tokens, token{s[start:i], false})
+		}
+	}
+	return tokens, nil
+}
+
+// parseNodes reads nodes up to the end of the tokens or, in a block, up to its closing brace.
+func parseNodes(tokens []token, inBlock bool) ([]keyValue, []token, error) {
+	var nodes []keyValue
+	for len(tokens) > 0 {
+		if tokens[0].is("}") {
+			if !inBlock {
+				return nil, nil, fmt.Errorf("unexpected }")
+			}
+			return nodes, tokens[1:], nil
+		}
+		if tokens[0].is("{") {
+			return nil, nil, fmt.Errorf("block without a key")
+		}
+		kv := keyValue{key: tokens[0].text}
+		if len(tokens) < 2 {
+			return nil, nil, fmt.Errorf("key %q without a value", kv.key)
+		}
+		if tokens[1].is("{") {
+			var err error
+			kv.block = true
+			if kv.children, tokens, err = parseNodes(tokens[2:], true); err != nil {
+				return nil, nil, err
+			}
+		} else if tokens[1].is("}") {
+			return nil, nil, fmt.Errorf("key %q without a value", kv.key)
+		} else {
+			kv.value = tokens[1].text
+			tokens = tokens[2:]
+		}
+		nodes = append(nodes, kv)
+	}
+	if inBlock {
+		return nil, nil, fmt.Errorf("unterminated block")
+	}
+	return nodes, nil, nil
+}
@@ -0,0 +1,173 @@
+// Package overview converts world coordinates to coordinates on the radar images of maps and back,
+// as described by the overview files the game ships in csgo/resource/overviews.
+package overview
+
+import (
+	"fmt"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RadarSize is the width and height in pixels of the radar images the transform is for.
+const RadarSize = 1024
+
+// DefaultSection is the name of the level shown on a map's primary radar image.
+const DefaultSection = "default"
+
+// Section is a level of a multi-level map, e.g. the lower floor of Nuke, which has a radar image of its own.
+type Section struct {
+	Name        string  `bson:"Name"`
+	AltitudeMin float64 `bson:"AltitudeMin"`
+	AltitudeMax float64 `bson:"AltitudeMax"`
+}
+
+// Map is the radar transform of a map: the world position of the radar image's top left corner
+// and how many units a pixel of the image covers. Every level of a map shares it.
+type Map struct {
+	Name  string  `bson:"Name"`
+	PosX  float64 `bson:"PosX"`
+	PosY  float64 `bson:"PosY"`
+	Scale float64 `bson:"Scale"`
+	// Sections are the levels of the map, the default one first.
+	// A map without vertical sections has only the default one, spanning every altitude.
+	Sections []Section `bson:"Sections"`
+}
+
+// RadarPoint is a position on a map's radar image, in pixels from its top left corner,
+// on the image of the level the position is in.
+type RadarPoint struct {
+	X       float64 `bson:"X"`
+	Y       float64 `bson:"Y"`
+	Section int     `bson:"Section"`
+}
+
+// Parse reads an overview file.
+func Parse(r io.Reader) (Map, error) {
+	nodes, err := parseKeyValues(r)
+	if err != nil {
+		return Map{}, err
+	}
+	if len(nodes) != 1 || !nodes[0].block {
+		return Map{}, fmt.Errorf("overview must be a single block named after its map")
+	}
+	root := nodes[0]
+	m := Map{Name: strings.ToLower(root.key)}
+
+	for _, f := range []struct {
+		key   string
+		value *float64
+	}{{"pos_x", &m.PosX}, {"pos_y", &m.PosY}, {"scale", &m.Scale}} {
+		kv, ok := root.child(f.key)
+		if !ok {
+			return Map{}, fmt.Errorf("overview of %s has no %s", m.Name, f.key)
+		}
+		if *f.value, err = strconv.ParseFloat(kv.value, 64); err != nil {
+			return Map{}, fmt.Errorf("overview of %s has malformed %s %q", m.Name, f.key, kv.value)
+		}
+	}
+	if m.Scale <= 0 {
+		return Map{}, fmt.Errorf("overview of %s has scale %v, must be positive", m.Name, m.Scale)
+	}
+
+	if sections, ok := root.child("verticalsections"); ok {
+		for _, kv := range sections.children {
+			s := Section{Name: strings.ToLower(kv.key), AltitudeMin: math.Inf(-1), AltitudeMax: math.Inf(1)}
+			if min, ok := kv.child("AltitudeMin"); ok {
+				if s.AltitudeMin, err = strconv.ParseFloat(min.value, 64); err != nil {
+					return Map{}, fmt.Errorf("overview of %s has malformed AltitudeMin %q in section %s", m.Name, min.value, s.Name)
+				}
+			}
+			if max, ok := kv.child("AltitudeMax"); ok {
+				if s.AltitudeMax, err = strconv.ParseFloat(max.value, 64); err != nil {
+					return Map{}, fmt.Errorf("overview of %s has malformed AltitudeMax %q in section %s", m.Name, max.value, s.Name)
+				}
+			}
+			m.Sections = append(m.Sections, s)
+		}
+	}
+	sort.SliceStable(m.Sections, func(i, j int) bool {
+		return m.Sections[i].Name == DefaultSection && m.Sections[j].Name != DefaultSection
+	})
+	if len(m.Sections) == 0 || m.Sections[0].Name != DefaultSection {
+		m.Sections = append([]Section{{DefaultSection, math.Inf(-1), math.Inf(1)}}, m.Sections...)
+	}
+	return m, nil
+}
+
+// SectionAt returns the index of the level an altitude is in. Altitudes no level claims are in the default one.
+func (m Map) SectionAt(z float64) int {
+	for i, s := range m.Sections {
+		if i > 0 && z >= s.AltitudeMin && z < s.AltitudeMax {
+			return i
+		}
+	}
+	return 0
+}
+
+// ToRadar converts a world position to the radar image of its level.
+func (m Map) ToRadar(v r3.Vector) RadarPoint {
+	return RadarPoint{(v.X - m.PosX) / m.Scale, (m.PosY - v.Y) / m.Scale, m.SectionAt(v.Z)}
+}
+
+// FromRadar converts a point on the radar back to world coordinates. The radar has no altitude, so it is given.
+func (m Map) FromRadar(p RadarPoint, z float64) r3.Vector {
+	return r3.Vector{X: p.X*m.Scale + m.PosX, Y: m.PosY - p.Y*m.Scale, Z: z}
+}
+
+// Bounds returns the area of the map the radar image covers in world coordinates,
+// its corners counterclockwise from the top left one.
+func (m Map) Bounds() []r2.Point {
+	size := RadarSize * m.Scale
+	return []r2.Point{
+		{X: m.PosX, Y: m.PosY},
+		{X: m.PosX, Y: m.PosY - size},
+		{X: m.PosX + size, Y: m.PosY - size},
+		{X: m.PosX + size, Y: m.PosY},
+	}
+}
+
+// Registry holds the overviews of maps by name, e.g. de_nuke, as the header's MapName has it.
+type Registry map[string]Map
+
+// LoadDir reads the overview files, *.txt, of a folder such as csgo/resource/overviews.
+// Text files that aren't overviews are skipped.
+func LoadDir(dir string) (Registry, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	reg := make(Registry)
+	for _, path := range paths {
+		m, err := parseFile(path)
+		if err != nil {
+			continue
+		}
+		reg[m.Name] = m
+	}
+	if len(reg) == 0 {
+		return nil, fmt.Errorf("no overview files in %s", dir)
+	}
+	return reg, nil
+}
+
+func parseFile(path string) (Map, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Map{}, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Map returns the overview of a map, its name compared without case.
+func (reg Registry) Map(name string) (Map, bool) {
+	m, ok := reg[strings.ToLower(name)]
+	return m, ok
+}
@@ -0,0 +1,159 @@
+package overview
+
+import (
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const nuke = `// HLTV overview description file for de_nuke.bsp
+
+"de_nuke"
+{
+	"material"	"overviews/de_nuke"	// texture file
+	"pos_x"		"-3453"	// upper left world coordinate
+	"pos_y"		"2887"
+	"scale"		"7.0"
+	"rotate"	"0"
+	"zoom"		"0"
+
+	"verticalsections"
+	{
+		"lower" // i.e. de_nuke_lower_radar.dds
+		{
+			"AltitudeMax" "-495"
+			"AltitudeMin" "-10000"
+		}
+		"default" // use the primary radar image
+		{
+			"AltitudeMax" "10000"
+			"AltitudeMin" "-495"
+		}
+	}
+	"CTSpawn_x"	"0.82"
+	"CTSpawn_y"	"0.45"
+}
+`
+
+const dust2 = `"de_dust2"
+{
+	material overviews/de_dust2
+	pos_x -2476
+	pos_y 3239
+	scale 4.4
+	[$X360] "zoom" "1"
+}
+`
+
+func TestParse(t *testing.T) {
+	m, err := Parse(strings.NewReader(nuke))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "de_nuke" || m.PosX != -3453 || m.PosY != 2887 || m.Scale != 7 {
+		t.Error("Parse failed, got ", m)
+	}
+	if len(m.Sections) != 2 || m.Sections[0] != (Section{DefaultSection, -495, 10000}) || m.Sections[1] != (Section{"lower", -10000, -495}) {
+		t.Error("Parse failed on vertical sections, got ", m.Sections)
+	}
+
+	m, err = Parse(strings.NewReader(dust2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "de_dust2" || m.PosX != -2476 || m.PosY != 3239 || m.Scale != 4.4 {
+		t.Error("Parse failed on bare tokens, got ", m)
+	}
+	if len(m.Sections) != 1 || m.Sections[0] != (Section{DefaultSection, math.Inf(-1), math.Inf(1)}) {
+		t.Error("Parse failed without vertical sections, got ", m.Sections)
+	}
+
+	for _, s := range []string{
+		`"de_x" { "pos_x" "1" "pos_y" "2" }`,
+		`"de_x" { "pos_x" "1" "pos_y" "2" "scale" "0" }`,
+		`"de_x" { "pos_x" "a" "pos_y" "2" "scale" "1" }`,
+		`"de_x" { "pos_x" "1" "pos_y" "2" "scale" "1"`,
+		`"de_x" "value"`,
+		`"de_x" { "pos_x" }`,
+		`"de_x" { "pos_x "1" }`,
+	} {
+		if _, err := Parse(strings.NewReader(s)); err == nil {
+			t.Error("Parse succeeded on malformed overview ", s)
+		}
+	}
+}
+
+func TestRadar(t *testing.T) {
+	m, err := Parse(strings.NewReader(nuke))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		world r3.Vector
+		radar RadarPoint
+	}{
+		{r3.Vector{X: -3453, Y: 2887, Z: 0}, RadarPoint{0, 0, 0}},
+		{r3.Vector{X: -3453 + 700, Y: 2887 - 1400, Z: -495}, RadarPoint{100, 200, 0}},
+		{r3.Vector{X: 0, Y: 0, Z: -600}, RadarPoint{3453.0 / 7, 2887.0 / 7, 1}},
+	} {
+		if res := m.ToRadar(c.world); res != c.radar {
+			t.Error("ToRadar failed, got ", res, " instead of ", c.radar)
+		}
+		if res := m.FromRadar(c.radar, c.world.Z); res.Sub(c.world).Norm() > 1e-9 {
+			t.Error("FromRadar failed, got ", res, " instead of ", c.world)
+		}
+	}
+}
+
+func TestBounds(t *testing.T) {
+	m := Map{PosX: -3453, PosY: 2887, Scale: 7}
+	expected := []r2.Point{{X: -3453, Y: 2887}, {X: -3453, Y: 2887 - 7168}, {X: -3453 + 7168, Y: 2887 - 7168}, {X: -3453 + 7168, Y: 2887}}
+	if res := m.Bounds(); !reflect.DeepEqual(res, expected) {
+		t.Error("Bounds failed, got ", res, " instead of ", expected)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overviews")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"de_nuke.txt":  nuke,
+		"de_dust2.txt": dust2,
+		"notes.txt":    "not an overview",
+		"de_nuke.dds":  "",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reg, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reg) != 2 {
+		t.Error("LoadDir failed, got ", reg)
+	}
+	if m, ok := reg.Map("DE_NUKE"); !ok || m.Scale != 7 {
+		t.Error("Map failed, got ", m, ok)
+	}
+	if _, ok := reg.Map("de_inferno"); ok {
+		t.Error("Map found a map that wasn't loaded")
+	}
+	if _, ok := Registry(nil).Map("de_nuke"); ok {
+		t.Error("Map found a map in an empty registry")
+	}
+
+	if _, err := LoadDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("LoadDir succeeded on a folder without overviews")
+	}
+}
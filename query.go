@@ -0,0 +1,61 @@
+package main
+
+import (
+	"csgo-parser-mongodb/reader"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// queriedTick is a line printed by the query command.
+type queriedTick struct {
+	Tick     float64
+	Frame    float64
+	Players  []reader.PlayerPosition
+	Grenades []reader.GrenadePosition
+}
+
+// runQuery prints where players and grenades are at the given ticks of a match as JSON lines,
+// interpolated between the saved frames, e.g. to line them up with the frames of a video.
+func runQuery(args []string) {
+	var mongoUri, dbName string
+	var from, to, step float64
+
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	flags.StringVar(&mongoUri, "uri", "localhost:27017", "MongoDB connection URI.")
+	flags.StringVar(&dbName, "dbname", "test", "Database name of the parsed match.")
+	flags.Float64Var(&from, "tick", 0, "Tick to look positions up at, fractions of a tick allowed.")
+	flags.Float64Var(&to, "to", -1, "Looks positions up at every -step ticks from -tick up to this tick, e.g. at every frame of a video. Only -tick if negative.")
+	flags.Float64Var(&step, "step", 1, "Ticks between the looked up ticks with -to, e.g. the tick rate divided by the video's frame rate.")
+	checkError(flags.Parse(args))
+
+	if to < 0 {
+		to = from
+	}
+	if step <= 0 || to < from {
+		fmt.Printf("Incorrect ticks: from %v to %v every %v. -to must not be before -tick and -step must be positive.\n", from, to, step)
+		os.Exit(2)
+	}
+
+	client := connect_to_mongo("mongodb://"+mongoUri, 2*time.Second)
+	defer close_connection_to_mongo(client)
+
+	match, err := reader.Load(reader.NewMongoSource(client.Database(dbName), clNames))
+	checkError(err)
+	query, err := match.Query()
+	checkError(err)
+
+	out := json.NewEncoder(os.Stdout)
+	// ticks are counted up by index so steps that aren't whole don't add up rounding errors
+	for i := 0; from+float64(i)*step <= to; i++ {
+		tick := from + float64(i)*step
+		line := queriedTick{Tick: tick, Frame: query.FrameAt(tick)}
+		line.Players, err = query.PlayersAt(tick)
+		checkError(err)
+		line.Grenades, err = query.GrenadesAt(tick)
+		checkError(err)
+		checkError(out.Encode(line))
+	}
+}
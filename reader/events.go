@@ -0,0 +1,287 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"fmt"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"github.com/markus-wa/demoinfocs-golang/events"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Event is a stored game event. Data holds the event's typed payload, see NewEventData,
+// or nil for events that carry none (e.g. RoundFreezetimeEnd).
+// Players are referred to by their SteamID, -1 standing for no player (e.g. a kill without assister).
+type Event struct {
+	FrameNumber	int
+	Tick		int
+	RoundNumber	int
+	Type		app.EvType
+	Data		interface{}
+}
+
+// Vector is a position stored in event data, in world coordinates.
+type Vector struct {
+	X	float64	`bson:"X"`
+	Y	float64	`bson:"Y"`
+	Z	float64	`bson:"Z"`
+}
+
+// OptionalEquipment is a piece of equipment an event may or may not refer to.
+type OptionalEquipment struct {
+	app.EquipmentInfo
+	Valid bool
+}
+
+func (e *OptionalEquipment) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	return unmarshalOptional(t, data, &e.EquipmentInfo, &e.Valid)
+}
+
+// OptionalTeamState is a team state an event may or may not refer to.
+type OptionalTeamState struct {
+	app.TeamStateInfo
+	Valid bool
+}
+
+func (ts *OptionalTeamState) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	return unmarshalOptional(t, data, &ts.TeamStateInfo, &ts.Valid)
+}
+
+// missing pointers are stored as -1 by the parser instead of a document
+func unmarshalOptional(t bsontype.Type, data []byte, v interface{}, valid *bool) error {
+	if t != bsontype.EmbeddedDocument {
+		*valid = false
+		return nil
+	}
+	*valid = true
+	return bson.Unmarshal(data, v)
+}
+
+// EquipmentData is a piece of equipment stored by value, as in item events.
+type EquipmentData struct {
+	EntityID		int						`bson:"EntityID"`
+	Weapon			common.EquipmentElement	`bson:"Weapon"`
+	Owner			int64					`bson:"Owner"`
+	AmmoInMagazine	int						`bson:"AmmoInMagazine"`
+	AmmoReserve		int						`bson:"AmmoReserve"`
+	OriginalString	string					`bson:"OriginalString"`
+	ZoomLevel		int						`bson:"ZoomLevel"`
+}
+
+type KillData struct {
+	Weapon				OptionalEquipment	`bson:"Weapon"`
+	Victim				int64				`bson:"Victim"`
+	Killer				int64				`bson:"Killer"`
+	Assister			int64				`bson:"Assister"`
+	PenetratedObjects	int					`bson:"PenetratedObjects"`
+	IsHeadshot			bool				`bson:"IsHeadshot"`
+	// where the victim and the killer were at the tick of the kill, nil for kills stored before
+	// they were recorded and for the killer of a kill without one
+	VictimMovement		*app.PlayerMovementInfo	`bson:"VictimMovement"`
+	KillerMovement		*app.PlayerMovementInfo	`bson:"KillerMovement"`
+	// places the victim and the killer were in, "" if the parser had no zones of them
+	VictimCallout		string				`bson:"VictimCallout"`
+	KillerCallout		string				`bson:"KillerCallout"`
+	// whether a smoke hid the victim from the killer
+	ThroughSmoke		bool				`bson:"ThroughSmoke"`
+}
+
+type PlayerHurtData struct {
+	Player			int64				`bson:"Player"`
+	Attacker		int64				`bson:"Attacker"`
+	Health			int					`bson:"Health"`
+	Armor			int					`bson:"Armor"`
+	Weapon			OptionalEquipment	`bson:"Weapon"`
+	WeaponString	string				`bson:"WeaponString"`
+	HealthDamage	int					`bson:"HealthDamage"`
+	ArmorDamage		int					`bson:"ArmorDamage"`
+	HitGroup		events.HitGroup		`bson:"HitGroup"`
+	// places the player and the attacker were in, "" if the parser had no zones of them
+	PlayerCallout	string				`bson:"PlayerCallout"`
+	AttackerCallout	string				`bson:"AttackerCallout"`
+}
+
+type WeaponFireData struct {
+	Shooter	int64				`bson:"Shooter"`
+	Weapon	OptionalEquipment	`bson:"Weapon"`
+}
+
+// PlayerData is the payload of events that only refer to a player, e.g. Footstep or BombPickup.
+type PlayerData struct {
+	Player	int64	`bson:"Player"`
+}
+
+type BotTakenOverData struct {
+	Taker	int64	`bson:"Taker"`
+}
+
+type BombData struct {
+	Player	int64	`bson:"Player"`
+	Site	rune	`bson:"Site"`
+	// place the player was in, only stored for plants and defuses
+	PlayerCallout	string	`bson:"PlayerCallout"`
+}
+
+type BombDefuseStartData struct {
+	Player	int64	`bson:"Player"`
+	HasKit	bool	`bson:"HasKit"`
+}
+
+type BombDroppedData struct {
+	Player		int64	`bson:"Player"`
+	EntityID	int		`bson:"EntityID"`
+}
+
+type GrenadeData struct {
+	GrenadeType	common.EquipmentElement	`bson:"GrenadeType"`
+	Position	Vector					`bson:"Position"`
+	Thrower		int64					`bson:"Thrower"`
+}
+
+// ProjectileData is the payload of GrenadeProjectileThrow and GrenadeProjectileDestroy.
+// Events stored without a Thrower, as the parser didn't record one before, only have the Projectile.
+type ProjectileData struct {
+	Projectile	int64					`bson:"Projectile"`
+	Weapon		common.EquipmentElement	`bson:"Weapon"`
+	Thrower		int64					`bson:"Thrower"`
+	// where the thrower stood and aimed at the throw, nil for destructions and throws without thrower
+	ThrowerMovement	*app.PlayerMovementInfo	`bson:"ThrowerMovement"`
+	ThrowerCallout	string				`bson:"ThrowerCallout"`
+	// where the projectile detonated, nil for throws
+	Position	*app.FixedVector3		`bson:"Position"`
+	Callout		string					`bson:"Callout"`
+}
+
+type GrenadeProjectileBounceData struct {
+	Projectile	int64	`bson:"Projectile"`
+	BounceNr	int		`bson:"BounceNr"`
+}
+
+type ItemData struct {
+	Weapon	EquipmentData	`bson:"Weapon"`
+	Player	int64			`bson:"Player"`
+}
+
+type ScoreUpdatedData struct {
+	OldScore	int					`bson:"OldScore"`
+	NewScore	int					`bson:"NewScore"`
+	TeamState	OptionalTeamState	`bson:"TeamState"`
+}
+
+type RoundStartData struct {
+	TimeLimit	int		`bson:"TimeLimit"`
+	FragLimit	int		`bson:"FragLimit"`
+	Objective	string	`bson:"Objective"`
+}
+
+type RoundEndData struct {
+	Message		string					`bson:"Message"`
+	Reason		events.RoundEndReason	`bson:"Reason"`
+	Winner		common.Team				`bson:"Winner"`
+	WinnerState	OptionalTeamState		`bson:"WinnerState"`
+	LoserState	OptionalTeamState		`bson:"LoserState"`
+}
+
+type RoundMVPAnnouncementData struct {
+	Player	int64					`bson:"Player"`
+	Reason	events.RoundMVPReason	`bson:"Reason"`
+}
+
+type RankUpdateData struct {
+	SteamID		int64	`bson:"SteamID"`
+	RankOld		int		`bson:"RankOld"`
+	RankNew		int		`bson:"RankNew"`
+	WinCount	int		`bson:"WinCount"`
+	RankChange	float32	`bson:"RankChange"`
+}
+
+// NewEventData returns a pointer to the typed payload of the given event type,
+// or nil if the type has no payload the parser stores.
+func NewEventData(t app.EvType) interface{} {
+	switch t {
+	case app.Kill:
+		return &KillData{}
+	case app.PlayerHurt:
+		return &PlayerHurtData{}
+	case app.WeaponFire:
+		return &WeaponFireData{}
+	case app.Footstep, app.PlayerJump, app.PlayerDisconnected, app.BombDefuseAborted, app.BombPickup:
+		return &PlayerData{}
+	case app.BotTakenOver:
+		return &BotTakenOverData{}
+	case app.BombDefuseStart:
+		return &BombDefuseStartData{}
+	case app.BombDropped:
+		return &BombDroppedData{}
+	case app.BombDefused, app.BombExplode, app.BombPlantBegin, app.BombPlanted:
+		return &BombData{}
+	case app.HeExplode, app.SmokeStart, app.SmokeExpired, app.FireGrenadeStart, app.FireGrenadeExpired,
+		app.DecoyStart, app.DecoyExpired:
+		return &GrenadeData{}
+	case app.FlashExplode:
+		return &app.FlashExplodeInfo{}
+	case app.PlayerFlashed:
+		return &app.PlayerFlashedInfo{}
+	case app.GrenadeProjectileThrow, app.GrenadeProjectileDestroy:
+		return &ProjectileData{}
+	case app.GrenadeProjectileBounce:
+		return &GrenadeProjectileBounceData{}
+	case app.ItemPickup, app.ItemDrop, app.ItemEquip:
+		return &ItemData{}
+	case app.ScoreUpdated:
+		return &ScoreUpdatedData{}
+	case app.RoundStart:
+		return &RoundStartData{}
+	case app.RoundEnd:
+		return &RoundEndData{}
+	case app.RoundMVPAnnouncement:
+		return &RoundMVPAnnouncementData{}
+	case app.RankUpdate:
+		return &RankUpdateData{}
+	}
+	return nil
+}
+
+// embedded event structs (GrenadeEvent, BombEvent) are stored as a nested document named after them
+var embeddedEventData = map[app.EvType]string{
+	app.HeExplode:          "GrenadeEvent",
+	app.SmokeStart:         "GrenadeEvent",
+	app.SmokeExpired:       "GrenadeEvent",
+	app.FireGrenadeStart:   "GrenadeEvent",
+	app.FireGrenadeExpired: "GrenadeEvent",
+	app.DecoyStart:         "GrenadeEvent",
+	app.DecoyExpired:       "GrenadeEvent",
+	app.BombDefused:        "BombEvent",
+	app.BombExplode:        "BombEvent",
+	app.BombPlantBegin:     "BombEvent",
+	app.BombPlanted:        "BombEvent",
+}
+
+type storedEvent struct {
+	FrameNumber	int				`bson:"FrameNumber"`
+	Tick		int				`bson:"Tick"`
+	RoundNumber	int				`bson:"RoundNumber"`
+	EventType	app.EvType		`bson:"EventType"`
+	Data		bson.RawValue	`bson:"Data"`
+}
+
+func (se storedEvent) event() (Event, error) {
+	ev := Event{se.FrameNumber, se.Tick, se.RoundNumber, se.EventType, nil}
+	data := NewEventData(se.EventType)
+	if data == nil || se.Data.Type != bsontype.EmbeddedDocument {
+		return ev, nil
+	}
+	raw := se.Data
+	if name, ok := embeddedEventData[se.EventType]; ok {
+		embedded, err := raw.Document().LookupErr(name)
+		if err != nil {
+			return ev, fmt.Errorf("%s event at frame %d: %v", app.EvTypeByIndex[se.EventType], se.FrameNumber, err)
+		}
+		raw = embedded
+	}
+	if err := raw.Unmarshal(data); err != nil {
+		return ev, fmt.Errorf("%s event at frame %d: %v", app.EvTypeByIndex[se.EventType], se.FrameNumber, err)
+	}
+	ev.Data = data
+	return ev, nil
+}
@@ -0,0 +1,113 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func decodeStored(t *testing.T, doc interface{}) Event {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var se storedEvent
+	if err := bson.Unmarshal(raw, &se); err != nil {
+		t.Fatal(err)
+	}
+	ev, err := se.event()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+func TestKillEventData(t *testing.T) {
+	ev := decodeStored(t, app.EventInfo{
+		FrameNumber: 10,
+		Tick:        1280,
+		RoundNumber: 3,
+		EventType:   app.Kill,
+		Data: map[string]interface{}{
+			"Weapon":            app.EquipmentInfo{UniqueID: 42, Weapon: common.EqAK47, OwnerID: 7},
+			"Victim":            int64(8),
+			"Killer":            int64(7),
+			"Assister":          -1,
+			"PenetratedObjects": 0,
+			"IsHeadshot":        true,
+			"VictimMovement":    app.PlayerMovementInfo{SteamID: 8, Position: app.FixedVector3{X: 1, Y: 2, Z: 3}, ViewX: 90},
+		},
+	})
+	data, ok := ev.Data.(*KillData)
+	if !ok {
+		t.Fatalf("Kill event data has type %T", ev.Data)
+	}
+	if ev.Tick != 1280 || ev.RoundNumber != 3 {
+		t.Error("Kill event has wrong tick or round: ", ev.Tick, ev.RoundNumber)
+	}
+	if !data.Weapon.Valid || data.Weapon.Weapon != common.EqAK47 || data.Killer != 7 || data.Assister != -1 || !data.IsHeadshot {
+		t.Error("Kill event data decoded wrong: ", *data)
+	}
+	if data.VictimMovement == nil || *data.VictimMovement != (app.PlayerMovementInfo{SteamID: 8, Position: app.FixedVector3{X: 1, Y: 2, Z: 3}, ViewX: 90}) || data.KillerMovement != nil {
+		t.Error("Kill event movements decoded wrong: ", data.VictimMovement, data.KillerMovement)
+	}
+}
+
+func TestEmbeddedEventData(t *testing.T) {
+	ev := decodeStored(t, app.EventInfo{
+		EventType: app.SmokeStart,
+		Data: map[string]interface{}{
+			"GrenadeEvent": map[string]interface{}{
+				"GrenadeType": common.EqSmoke,
+				"Position":    map[string]interface{}{"X": 1.5, "Y": -2.0, "Z": 3.0},
+				"Thrower":     int64(5),
+			},
+		},
+	})
+	data, ok := ev.Data.(*GrenadeData)
+	if !ok {
+		t.Fatalf("SmokeStart event data has type %T", ev.Data)
+	}
+	if data.GrenadeType != common.EqSmoke || data.Position != (Vector{1.5, -2, 3}) || data.Thrower != 5 {
+		t.Error("SmokeStart event data decoded wrong: ", *data)
+	}
+
+	ev = decodeStored(t, app.EventInfo{
+		EventType: app.BombPlanted,
+		Data: map[string]interface{}{
+			"BombEvent": map[string]interface{}{"Player": int64(5), "Site": 'A', "PlayerCallout": "A Site"},
+		},
+	})
+	if bomb, ok := ev.Data.(*BombData); !ok || bomb.Player != 5 || bomb.Site != 'A' || bomb.PlayerCallout != "A Site" {
+		t.Error("BombPlanted event data decoded wrong: ", ev.Data)
+	}
+
+	ev = decodeStored(t, app.EventInfo{EventType: app.RoundEnd, Data: map[string]interface{}{
+		"Winner":      common.TeamTerrorists,
+		"WinnerState": -1,
+		"LoserState":  app.TeamStateInfo{ID: 2, Score: 4},
+	}})
+	roundEnd := ev.Data.(*RoundEndData)
+	if roundEnd.WinnerState.Valid || !roundEnd.LoserState.Valid || roundEnd.LoserState.Score != 4 {
+		t.Error("RoundEnd team states decoded wrong: ", *roundEnd)
+	}
+}
+
+func TestOpeningKills(t *testing.T) {
+	kill := func(round int, killer int64) Event {
+		return Event{RoundNumber: round, Type: app.Kill, Data: &KillData{Killer: killer, KillerCallout: "Banana"}}
+	}
+	m := &Match{Events: []Event{
+		kill(0, 9),
+		{RoundNumber: 1, Type: app.RoundStart},
+		kill(1, 1), kill(1, 2),
+		{RoundNumber: 2, Type: app.RoundStart},
+		{RoundNumber: 3, Type: app.RoundStart},
+		kill(3, 3), kill(3, 1),
+	}}
+	res := m.OpeningKills()
+	if len(res) != 2 || res[0].Data.(*KillData).Killer != 1 || res[1].Data.(*KillData).Killer != 3 {
+		t.Error("OpeningKills failed, got ", res)
+	}
+}
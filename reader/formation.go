@@ -0,0 +1,45 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"fmt"
+)
+
+// TeamMetrics returns the spacing and territory of the teams, stored about every second of the match, in order.
+func (m *Match) TeamMetrics() ([]app.FrameTeamMetrics, error) {
+	var res []app.FrameTeamMetrics
+	err := readAll(m.src, app.ClTeamMetrics, func(c Cursor) error {
+		var TM app.FrameTeamMetrics
+		err := c.Decode(&TM)
+		res = append(res, TM)
+		return err
+	})
+	return res, err
+}
+
+// TeamMetricsAt returns the team metrics of every round the given seconds of game time after its freeze time ended,
+// the first stored that late, to compare how teams set up, e.g. their defaults. Rounds whose freeze time didn't end
+// or that ended before are left out.
+func (m *Match) TeamMetricsAt(seconds float64) ([]app.FrameTeamMetrics, error) {
+	if m.Header.PlaybackTime <= 0 {
+		return nil, fmt.Errorf("match has no playback time to tell ticks from seconds by")
+	}
+	tickRate := float64(m.Header.PlaybackTicks) / m.Header.PlaybackTime.Seconds()
+	metrics, err := m.TeamMetrics()
+	if err != nil {
+		return nil, err
+	}
+	var res []app.FrameTeamMetrics
+	for _, r := range m.Rounds {
+		if r.FreezetimeEndTick < 0 {
+			continue
+		}
+		for _, TM := range metrics {
+			if TM.RoundNumber == r.Number && float64(TM.Tick-r.FreezetimeEndTick) >= seconds*tickRate {
+				res = append(res, TM)
+				break
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,39 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"testing"
+	"time"
+)
+
+func TestTeamMetricsAt(t *testing.T) {
+	metrics := func(frame, tick, round int) app.FrameTeamMetrics {
+		return app.FrameTeamMetrics{FrameNumber: frame, Tick: tick, RoundNumber: round}
+	}
+	m := &Match{
+		Header: Header{PlaybackTime: 100 * time.Second, PlaybackTicks: 6400},
+		src: MemorySource{
+			app.ClTeamMetrics: {
+				metrics(1, 100, 1), metrics(2, 164, 1), metrics(3, 228, 1), metrics(4, 292, 1),
+				// the second round ended a second after its freeze time
+				metrics(5, 1000, 2), metrics(6, 1064, 2),
+				metrics(7, 2000, 3), metrics(8, 2100, 3),
+			},
+		},
+		Rounds: []Round{
+			{Number: 1, FreezetimeEndTick: 150},
+			{Number: 2, FreezetimeEndTick: 1000},
+			{Number: 3, FreezetimeEndTick: -1},
+		},
+	}
+	res, err := m.TeamMetricsAt(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].FrameNumber != 3 || res[1].FrameNumber != 6 {
+		t.Error("TeamMetricsAt failed, got ", res, " instead of the frames 3 and 6")
+	}
+	if res, _ := m.TeamMetricsAt(2); len(res) != 1 || res[0].FrameNumber != 4 {
+		t.Error("TeamMetricsAt failed to leave out rounds that ended before, got ", res)
+	}
+}
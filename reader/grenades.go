@@ -0,0 +1,140 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"csgo-parser-mongodb/zones"
+	"github.com/golang/geo/r3"
+	"github.com/markus-wa/demoinfocs-golang/common"
+)
+
+// GrenadeThrow is a grenade from where it was thrown to where it detonated, with what it did to the thrower's enemies.
+type GrenadeThrow struct {
+	Projectile int64
+	Round      int
+	// Tick of the throw
+	Tick    int
+	Thrower int64
+	Team    common.Team
+	Weapon  common.EquipmentElement
+	// Position is where the thrower stood and ViewX and ViewY where they aimed at the throw
+	Position     r3.Vector
+	ViewX, ViewY float64
+	Detonation   r3.Vector
+	// DetonationTick is the tick the projectile was destroyed at, the tick smokes and decoys expired
+	DetonationTick int
+	// places the thrower and the detonation were in, "" if neither the parser nor the zones had them
+	ThrowerCallout, DetonationCallout string
+	// EnemiesFlashed is how many enemies a flash blinded
+	EnemiesFlashed int
+	// EnemyDamage is the health an HE grenade or the fire of a molotov or incendiary took from enemies
+	EnemyDamage int
+	// RoundWon is whether the thrower's team won the round
+	RoundWon bool
+}
+
+// grenadeClass returns the grenade a weapon stands for in throws: molotovs and incendiaries burn alike.
+func grenadeClass(weapon common.EquipmentElement) common.EquipmentElement {
+	if weapon == common.EqIncendiary {
+		return common.EqMolotov
+	}
+	return weapon
+}
+
+// GrenadeThrows returns the smokes, flashes, HE grenades, molotovs and incendiaries thrown in the match, in order.
+// Callouts the parser didn't store are looked up in the zones of reg, which may be nil.
+// Blinds and damage are credited to the thrower's latest grenade of their kind thrown before them in the round.
+// Throws stored without a Thrower and grenades that never detonated are left out.
+func (m *Match) GrenadeThrows(reg zones.Registry) ([]GrenadeThrow, error) {
+	teams, err := m.Teams()
+	if err != nil {
+		return nil, err
+	}
+	detonations := make(map[int64]Event)
+	for _, ev := range m.EventsOfType(app.GrenadeProjectileDestroy) {
+		if data := ev.Data.(*ProjectileData); data.Position != nil {
+			detonations[data.Projectile] = ev
+		}
+	}
+	won := make(map[int]common.Team, len(m.Rounds))
+	for _, r := range m.Rounds {
+		won[r.Number] = r.Winner
+	}
+
+	var res []GrenadeThrow
+	// index in res of the latest throw of every thrower and kind of grenade
+	type key struct {
+		thrower int64
+		class   common.EquipmentElement
+	}
+	latest := make(map[key]int)
+	enemies := func(round int, a, b int64) bool {
+		ta, tb := teams.Team(round, a), teams.Team(round, b)
+		return ta != common.TeamUnassigned && tb != common.TeamUnassigned && ta != tb
+	}
+	credit := func(ev Event, attacker int64, weapon common.EquipmentElement) (*GrenadeThrow, bool) {
+		i, ok := latest[key{attacker, grenadeClass(weapon)}]
+		if !ok || res[i].Round != ev.RoundNumber {
+			return nil, false
+		}
+		return &res[i], true
+	}
+
+	for _, ev := range m.Events {
+		switch data := ev.Data.(type) {
+		case *ProjectileData:
+			if ev.Type != app.GrenadeProjectileThrow || data.ThrowerMovement == nil {
+				continue
+			}
+			switch data.Weapon {
+			case common.EqSmoke, common.EqFlash, common.EqHE, common.EqMolotov, common.EqIncendiary:
+			default:
+				continue
+			}
+			destroy, ok := detonations[data.Projectile]
+			if !ok {
+				continue
+			}
+			destroyData := destroy.Data.(*ProjectileData)
+			throw := GrenadeThrow{
+				Projectile:        data.Projectile,
+				Round:             ev.RoundNumber,
+				Tick:              ev.Tick,
+				Thrower:           data.Thrower,
+				Team:              teams.Team(ev.RoundNumber, data.Thrower),
+				Weapon:            data.Weapon,
+				Position:          m.Position(data.ThrowerMovement.Position),
+				ViewX:             float64(data.ThrowerMovement.ViewX),
+				ViewY:             float64(data.ThrowerMovement.ViewY),
+				Detonation:        m.Position(*destroyData.Position),
+				DetonationTick:    destroy.Tick,
+				ThrowerCallout:    data.ThrowerCallout,
+				DetonationCallout: destroyData.Callout,
+			}
+			if throw.ThrowerCallout == "" {
+				throw.ThrowerCallout, _ = m.Callout(data.ThrowerMovement.Position, reg)
+			}
+			if throw.DetonationCallout == "" {
+				throw.DetonationCallout, _ = m.Callout(*destroyData.Position, reg)
+			}
+			throw.RoundWon = throw.Team != common.TeamUnassigned && won[ev.RoundNumber] == throw.Team
+			latest[key{data.Thrower, grenadeClass(data.Weapon)}] = len(res)
+			res = append(res, throw)
+		case *app.PlayerFlashedInfo:
+			if throw, ok := credit(ev, data.AttackerID, common.EqFlash); ok && enemies(ev.RoundNumber, data.AttackerID, data.PlayerID) {
+				throw.EnemiesFlashed++
+			}
+		case *PlayerHurtData:
+			weapon := data.Weapon.Weapon
+			if !data.Weapon.Valid {
+				weapon = common.MapEquipment(data.WeaponString)
+			}
+			if weapon != common.EqHE && grenadeClass(weapon) != common.EqMolotov {
+				continue
+			}
+			if throw, ok := credit(ev, data.Attacker, weapon); ok && enemies(ev.RoundNumber, data.Attacker, data.Player) {
+				throw.EnemyDamage += data.HealthDamage
+			}
+		}
+	}
+	return res, nil
+}
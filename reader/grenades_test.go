@@ -0,0 +1,85 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"github.com/golang/geo/r3"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"testing"
+)
+
+func TestGrenadeThrows(t *testing.T) {
+	T, CT := common.TeamTerrorists, common.TeamCounterTerrorists
+	event := func(tick int, evType app.EvType, data map[string]interface{}) Event {
+		return decodeStored(t, app.EventInfo{FrameNumber: tick / 4, Tick: tick, RoundNumber: 2, EventType: evType, Data: data})
+	}
+	throw := func(tick int, projectile int64, weapon common.EquipmentElement) Event {
+		return event(tick, app.GrenadeProjectileThrow, map[string]interface{}{
+			"Projectile":      projectile,
+			"Weapon":          weapon,
+			"Thrower":         int64(1),
+			"ThrowerMovement": app.PlayerMovementInfo{SteamID: 1, Position: app.FixedVector3{X: 100, Y: 200}, ViewX: 45, ViewY: 350},
+			"ThrowerCallout":  "T Spawn",
+		})
+	}
+	destroy := func(tick int, projectile int64, weapon common.EquipmentElement) Event {
+		return event(tick, app.GrenadeProjectileDestroy, map[string]interface{}{
+			"Projectile": projectile,
+			"Weapon":     weapon,
+			"Thrower":    int64(1),
+			"Position":   app.FixedVector3{X: 300, Y: 400, Z: 10},
+		})
+	}
+	hurt := func(tick int, victim int64, weapon string, damage int) Event {
+		return event(tick, app.PlayerHurt, map[string]interface{}{
+			"Player": victim, "Attacker": int64(1), "Weapon": -1, "WeaponString": weapon, "HealthDamage": damage,
+		})
+	}
+
+	m := &Match{
+		src: MemorySource{app.ClGameState: {app.GameStateInfo{RoundNumber: 2, Players: []app.PlayerStateInfo{
+			{SteamID: 1, Team: T}, {SteamID: 2, Team: CT}, {SteamID: 3, Team: T},
+		}}}},
+		Rounds: []Round{{Number: 2, Winner: T}},
+		Events: []Event{
+			throw(100, 10, common.EqFlash),
+			event(150, app.PlayerFlashed, map[string]interface{}{"AttackerID": int64(1), "PlayerID": int64(2)}),
+			// teammates don't count
+			event(150, app.PlayerFlashed, map[string]interface{}{"AttackerID": int64(1), "PlayerID": int64(3)}),
+			destroy(150, 10, common.EqFlash),
+			throw(200, 11, common.EqMolotov),
+			destroy(260, 11, common.EqMolotov),
+			hurt(300, 2, "inferno", 8),
+			hurt(320, 2, "inferno", 5),
+			// no HE was thrown
+			hurt(330, 2, "hegrenade", 40),
+			throw(400, 12, common.EqDecoy),
+			destroy(900, 12, common.EqDecoy),
+			// never detonated
+			throw(500, 13, common.EqSmoke),
+			// stored before throwers were recorded
+			event(600, app.GrenadeProjectileThrow, map[string]interface{}{"Projectile": int64(14)}),
+			destroy(700, 14, common.EqSmoke),
+		},
+	}
+
+	throws, err := m.GrenadeThrows(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(throws) != 2 {
+		t.Fatal("GrenadeThrows failed, got ", throws)
+	}
+	flash, molotov := throws[0], throws[1]
+	expected := GrenadeThrow{
+		Projectile: 10, Round: 2, Tick: 100, Thrower: 1, Team: T, Weapon: common.EqFlash,
+		Position: r3.Vector{X: 100, Y: 200}, ViewX: 45, ViewY: 350,
+		Detonation: r3.Vector{X: 300, Y: 400, Z: 10}, DetonationTick: 150,
+		ThrowerCallout: "T Spawn", EnemiesFlashed: 1, RoundWon: true,
+	}
+	if flash != expected {
+		t.Error("GrenadeThrows failed on a flash, got ", flash, " instead of ", expected)
+	}
+	if molotov.Weapon != common.EqMolotov || molotov.EnemyDamage != 13 || molotov.EnemiesFlashed != 0 || molotov.DetonationTick != 260 {
+		t.Error("GrenadeThrows failed on a molotov, got ", molotov)
+	}
+}
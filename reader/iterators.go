@@ -0,0 +1,330 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"fmt"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// PositionIterator reads the stored player positions frame by frame.
+// Encoded rounds are decoded on the fly, so both storage modes yield the same frames.
+type PositionIterator struct {
+	cursor  Cursor
+	pending []app.FramePositions
+	value   app.FramePositions
+	err     error
+}
+
+// Positions returns an iterator over the players' positions of the match.
+func (m *Match) Positions() (*PositionIterator, error) {
+	cursor, err := m.src.Find(app.ClPositions)
+	if err != nil {
+		return nil, err
+	}
+	return &PositionIterator{cursor: cursor}, nil
+}
+
+// Next advances to the next frame and reports whether there is one.
+func (it *PositionIterator) Next() bool {
+	for len(it.pending) == 0 {
+		if it.err != nil || !it.cursor.Next() {
+			return false
+		}
+		var raw bson.Raw
+		if it.err = it.cursor.Decode(&raw); it.err != nil {
+			return false
+		}
+		if _, err := raw.LookupErr("PlayerMovements"); err == nil {
+			var RM app.RoundMovement
+			if it.err = bson.Unmarshal(raw, &RM); it.err != nil {
+				return false
+			}
+			if it.pending, it.err = app.RoundMovementFrames(RM); it.err != nil {
+				return false
+			}
+		} else {
+			var FP app.FramePositions
+			if it.err = bson.Unmarshal(raw, &FP); it.err != nil {
+				return false
+			}
+			it.pending = []app.FramePositions{FP}
+		}
+	}
+	it.value, it.pending = it.pending[0], it.pending[1:]
+	return true
+}
+
+// Value returns the current frame.
+func (it *PositionIterator) Value() app.FramePositions {
+	return it.value
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *PositionIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.cursor.Err()
+}
+
+func (it *PositionIterator) Close() error {
+	return it.cursor.Close()
+}
+
+// ProjectileIterator reads the stored grenade positions frame by frame.
+// Encoded trajectories are stored once per grenade, so they are all decoded up front.
+type ProjectileIterator struct {
+	cursor  Cursor
+	pending []app.FrameProjectiles
+	value   app.FrameProjectiles
+	err     error
+}
+
+// Projectiles returns an iterator over the grenades' positions of the match.
+func (m *Match) Projectiles() (*ProjectileIterator, error) {
+	cursor, err := m.src.Find(app.ClProjectiles)
+	if err != nil {
+		return nil, err
+	}
+	it := &ProjectileIterator{cursor: cursor}
+
+	var encoded []app.GrenadePositionInfoEncoded
+	for cursor.Next() {
+		var raw bson.Raw
+		if err := cursor.Decode(&raw); err != nil {
+			cursor.Close()
+			return nil, err
+		}
+		if _, err := raw.LookupErr("GrenadePositions"); err == nil {
+			var FP app.FrameProjectiles
+			if err := bson.Unmarshal(raw, &FP); err != nil {
+				cursor.Close()
+				return nil, err
+			}
+			it.pending = append(it.pending, FP)
+		} else {
+			var GPIE app.GrenadePositionInfoEncoded
+			if err := bson.Unmarshal(raw, &GPIE); err != nil {
+				cursor.Close()
+				return nil, err
+			}
+			encoded = append(encoded, GPIE)
+		}
+	}
+	if len(encoded) > 0 {
+		frames, err := app.GrenadeFrames(encoded)
+		if err != nil {
+			cursor.Close()
+			return nil, err
+		}
+		it.pending = append(it.pending, frames...)
+	}
+	return it, cursor.Err()
+}
+
+// Next advances to the next frame and reports whether there is one.
+func (it *ProjectileIterator) Next() bool {
+	if len(it.pending) == 0 {
+		return false
+	}
+	it.value, it.pending = it.pending[0], it.pending[1:]
+	return true
+}
+
+// Value returns the current frame.
+func (it *ProjectileIterator) Value() app.FrameProjectiles {
+	return it.value
+}
+
+func (it *ProjectileIterator) Err() error {
+	return it.err
+}
+
+func (it *ProjectileIterator) Close() error {
+	return it.cursor.Close()
+}
+
+// InfernoIterator reads the stored burning infernos frame by frame.
+type InfernoIterator struct {
+	cursor Cursor
+	value  app.FrameInfernos
+	err    error
+}
+
+// Infernos returns an iterator over the infernos of the match.
+func (m *Match) Infernos() (*InfernoIterator, error) {
+	cursor, err := m.src.Find(app.ClInfernos)
+	if err != nil {
+		return nil, err
+	}
+	return &InfernoIterator{cursor: cursor}, nil
+}
+
+func (it *InfernoIterator) Next() bool {
+	if it.err != nil || !it.cursor.Next() {
+		return false
+	}
+	it.value = app.FrameInfernos{}
+	it.err = it.cursor.Decode(&it.value)
+	return it.err == nil
+}
+
+func (it *InfernoIterator) Value() app.FrameInfernos {
+	return it.value
+}
+
+func (it *InfernoIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.cursor.Err()
+}
+
+func (it *InfernoIterator) Close() error {
+	return it.cursor.Close()
+}
+
+// InfernoLifecycles returns the lifecycles of the match's infernos, in the order they went out.
+// Fires still burning when the demo ended come last.
+func (m *Match) InfernoLifecycles() ([]app.InfernoLifecycle, error) {
+	var res []app.InfernoLifecycle
+	err := readAll(m.src, app.ClInfernoLifecycles, func(c Cursor) error {
+		var l app.InfernoLifecycle
+		err := c.Decode(&l)
+		res = append(res, l)
+		return err
+	})
+	return res, err
+}
+
+// GameStateIterator reads the stored game state snapshots.
+// Snapshots stored as diffs are applied to the one before, so every value is a full snapshot.
+type GameStateIterator struct {
+	cursor  Cursor
+	value   app.GameStateInfo
+	started bool
+	err     error
+}
+
+// GameStates returns an iterator over the game state snapshots of the match.
+func (m *Match) GameStates() (*GameStateIterator, error) {
+	cursor, err := m.src.Find(app.ClGameState)
+	if err != nil {
+		return nil, err
+	}
+	return &GameStateIterator{cursor: cursor}, nil
+}
+
+// ViewAngles converts the stored fixed point view angles of a player in a game state to degrees.
+func (m *Match) ViewAngles(PSI app.PlayerStateInfo) (x, y float64) {
+	return float64(app.AngleDegrees(PSI.ViewX, m.Header.ViewPrecision)), float64(app.AngleDegrees(PSI.ViewY, m.Header.ViewPrecision))
+}
+
+func (it *GameStateIterator) Next() bool {
+	if it.err != nil || !it.cursor.Next() {
+		return false
+	}
+	var raw bson.Raw
+	if it.err = it.cursor.Decode(&raw); it.err != nil {
+		return false
+	}
+	if _, err := raw.LookupErr("Players"); err == nil {
+		it.value = app.GameStateInfo{}
+		it.err = bson.Unmarshal(raw, &it.value)
+		it.started = it.err == nil
+		return it.started
+	}
+
+	var diff app.GameStateDiff
+	if it.err = bson.Unmarshal(raw, &diff); it.err != nil {
+		return false
+	}
+	if !it.started {
+		it.err = fmt.Errorf("game state in frame %d is stored as a diff to no full game state", diff.FrameNumber)
+		return false
+	}
+	it.value, it.err = diff.Apply(it.value)
+	return it.err == nil
+}
+
+func (it *GameStateIterator) Value() app.GameStateInfo {
+	return it.value
+}
+
+func (it *GameStateIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.cursor.Err()
+}
+
+func (it *GameStateIterator) Close() error {
+	return it.cursor.Close()
+}
+
+// GameStateAt returns the last game state snapshot saved in or before the given frame,
+// reading the snapshots from the start. It reports false if there is none.
+func (m *Match) GameStateAt(frame int) (app.GameStateInfo, bool, error) {
+	it, err := m.GameStates()
+	if err != nil {
+		return app.GameStateInfo{}, false, err
+	}
+	defer it.Close()
+
+	var GSI app.GameStateInfo
+	found := false
+	for it.Next() && it.Value().FrameNumber <= frame {
+		GSI, found = it.Value(), true
+	}
+	return GSI, found, it.Err()
+}
+
+// Teams are the teams the players played for, by round and SteamID.
+type Teams map[int]map[int64]common.Team
+
+// Team returns the team a player played for in a round, TeamUnassigned if they didn't play in it.
+func (t Teams) Team(round int, SteamID int64) common.Team {
+	if team, ok := t[round][SteamID]; ok {
+		return team
+	}
+	return common.TeamUnassigned
+}
+
+// Teams reads the teams of the players in every round from the game states,
+// as the first snapshot of the round a player is in has it.
+func (m *Match) Teams() (Teams, error) {
+	it, err := m.GameStates()
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	teams := make(Teams)
+	for it.Next() {
+		GSI := it.Value()
+		round, ok := teams[GSI.RoundNumber]
+		if !ok {
+			round = make(map[int64]common.Team)
+			teams[GSI.RoundNumber] = round
+		}
+		for _, PSI := range GSI.Players {
+			if _, ok := round[PSI.SteamID]; !ok {
+				round[PSI.SteamID] = PSI.Team
+			}
+		}
+	}
+	return teams, it.Err()
+}
+
+// RoundSummaries returns what commands made of the rounds, see app.RoundSummary, in the order they were first stored.
+func (m *Match) RoundSummaries() ([]app.RoundSummary, error) {
+	var res []app.RoundSummary
+	err := readAll(m.src, app.ClRoundSummaries, func(c Cursor) error {
+		var RS app.RoundSummary
+		err := c.Decode(&RS)
+		res = append(res, RS)
+		return err
+	})
+	return res, err
+}
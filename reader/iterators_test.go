@@ -0,0 +1,254 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"csgo-parser-mongodb/util/codec"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// roundTripPlayer is sampled from its first to its last frame, its angles given in degrees.
+type roundTripPlayer struct {
+	SteamID               int64
+	Round, First, Last    int
+	X, Y, Z, ViewX, ViewY func(frame int) float64
+}
+
+type roundTripGrenade struct {
+	UniqueID    int64
+	First, Last int
+	X, Y, Z     func(frame int) int
+}
+
+// storedAs stores the same movements of players and grenades the way the parser does with and without -elias:
+// a FramePositions and FrameProjectiles document per frame, or a RoundMovement per round and a document per grenade.
+func storedAs(players []roundTripPlayer, grenades []roundTripGrenade, codecs app.StreamCodecs) (plain, elias MemorySource) {
+	plain, elias = MemorySource{}, MemorySource{}
+	angle := func(degrees float64) float32 {
+		return app.AngleDegrees(app.FixedAngle(float32(degrees), codecs.ViewPrecision), codecs.ViewPrecision)
+	}
+	frames := make(map[int]*app.FramePositions)
+	var order []int
+	rounds := make(map[int]*app.RoundMovement)
+	var roundOrder []int
+	for _, p := range players {
+		PM := &app.PlayerMovement{StartFrame: p.First, ViewPrecision: codecs.ViewPrecision}
+		for f := p.First; f <= p.Last; f++ {
+			position := app.FixedVector3{X: int32(p.X(f)), Y: int32(p.Y(f)), Z: int32(p.Z(f))}
+			PM.PositionX = append(PM.PositionX, int(position.X))
+			PM.PositionY = append(PM.PositionY, int(position.Y))
+			PM.PositionZ = append(PM.PositionZ, int(position.Z))
+			PM.ViewX = append(PM.ViewX, app.FixedAngle(float32(p.ViewX(f)), codecs.ViewPrecision))
+			PM.ViewY = append(PM.ViewY, app.FixedAngle(float32(p.ViewY(f)), codecs.ViewPrecision))
+			if frames[f] == nil {
+				frames[f] = &app.FramePositions{FrameNumber: f}
+				order = append(order, f)
+			}
+			frames[f].PlayersPositions = append(frames[f].PlayersPositions, app.PlayerMovementInfo{
+				SteamID: p.SteamID, Position: position, ViewX: angle(p.ViewX(f)), ViewY: angle(p.ViewY(f)),
+			})
+		}
+		if rounds[p.Round] == nil {
+			rounds[p.Round] = &app.RoundMovement{RoundNumber: p.Round}
+			roundOrder = append(roundOrder, p.Round)
+		}
+		rounds[p.Round].PlayerMovements = append(rounds[p.Round].PlayerMovements,
+			app.NewPlayerMovementInfoEncoded(p.SteamID, PM, p.Last, codecs))
+	}
+	sort.Ints(order)
+	for _, f := range order {
+		FP := frames[f]
+		sort.Slice(FP.PlayersPositions, func(i, j int) bool {
+			return FP.PlayersPositions[i].SteamID < FP.PlayersPositions[j].SteamID
+		})
+		plain[app.ClPositions] = append(plain[app.ClPositions], *FP)
+	}
+	for _, r := range roundOrder {
+		elias[app.ClPositions] = append(elias[app.ClPositions], *rounds[r])
+	}
+
+	projectiles := make(map[int]*app.FrameProjectiles)
+	order = nil
+	for _, g := range grenades {
+		GM := &app.GrenadeMovement{StartFrame: g.First}
+		for f := g.First; f <= g.Last; f++ {
+			GM.PositionX = append(GM.PositionX, g.X(f))
+			GM.PositionY = append(GM.PositionY, g.Y(f))
+			GM.PositionZ = append(GM.PositionZ, g.Z(f))
+			if projectiles[f] == nil {
+				projectiles[f] = &app.FrameProjectiles{FrameNumber: f}
+				order = append(order, f)
+			}
+			projectiles[f].GrenadesPositions = append(projectiles[f].GrenadesPositions, app.GrenadePositionInfo{
+				UniqueID: g.UniqueID, Position: app.FixedVector3{X: int32(g.X(f)), Y: int32(g.Y(f)), Z: int32(g.Z(f))},
+			})
+		}
+		elias[app.ClProjectiles] = append(elias[app.ClProjectiles], app.NewGrenadePositionInfoEncoded(g.UniqueID, GM, codecs))
+	}
+	sort.Ints(order)
+	for _, f := range order {
+		plain[app.ClProjectiles] = append(plain[app.ClProjectiles], *projectiles[f])
+	}
+	return plain, elias
+}
+
+func TestGameStateDiffs(t *testing.T) {
+	var states []app.GameStateInfo
+	var docs []interface{}
+	for frame := 1; frame <= 7; frame++ {
+		GSI := app.GameStateInfo{FrameNumber: frame * 2, Tick: frame * 128, RoundNumber: 1, Players: []app.PlayerStateInfo{
+			{SteamID: 1, Hp: 100 - frame, Money: 800, ViewX: app.FixedAngle(10.5*float32(frame), 4), ViewY: app.FixedAngle(355, 4)},
+			{SteamID: 2, Hp: 100, Money: 800 + 100*(frame/3)},
+		}}
+		states = append(states, GSI)
+		if frame%3 == 1 {
+			docs = append(docs, GSI)
+			continue
+		}
+		diff, ok, err := app.DiffGameStates(states[len(states)-2], GSI)
+		if !ok || err != nil {
+			t.Fatal(ok, err)
+		}
+		docs = append(docs, diff)
+	}
+	m := &Match{src: MemorySource{app.ClGameState: docs}, Header: Header{ViewPrecision: 4}}
+
+	it, err := m.GameStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []app.GameStateInfo
+	for it.Next() {
+		res = append(res, it.Value())
+	}
+	if err := it.Err(); err != nil || !reflect.DeepEqual(res, states) {
+		t.Error("GameStates failed, got ", res, err, " instead of ", states)
+	}
+	if x, y := m.ViewAngles(res[6].Players[0]); x != 73.5 || y != 355 {
+		t.Error("ViewAngles failed, got ", x, y, " instead of 73.5 355")
+	}
+
+	if GSI, ok, err := m.GameStateAt(9); !ok || err != nil || !reflect.DeepEqual(GSI, states[3]) {
+		t.Error("GameStateAt failed, got ", GSI, ok, err, " instead of ", states[3])
+	}
+	if _, ok, err := m.GameStateAt(1); ok || err != nil {
+		t.Error("GameStateAt found a game state before the first one")
+	}
+
+	m = &Match{src: MemorySource{app.ClGameState: docs[1:]}}
+	if _, _, err := m.GameStateAt(100); err == nil {
+		t.Error("GameStateAt didn't fail on a diff to no full game state")
+	}
+}
+
+func TestPositionsRoundTrip(t *testing.T) {
+	linear := func(from, step float64) func(int) float64 {
+		return func(f int) float64 { return from + step*float64(f) }
+	}
+	// turning across 0 degrees
+	turning := func(from, step float64) func(int) float64 {
+		return func(f int) float64 { return math.Mod(from+step*float64(f)+360, 360) }
+	}
+	players := []roundTripPlayer{
+		{1, 1, 1, 9, linear(-300, 17), linear(1200, -3), linear(64, 0), turning(340, 3.25), turning(5, -1.5)},
+		// dies after frame 4
+		{2, 1, 1, 4, linear(800, -40), linear(-20, 0), linear(-160, 2), linear(90, 0), linear(0, 0)},
+		// spawns late in round 2
+		{2, 2, 13, 20, linear(500, 9), linear(-700, 11), linear(0, 1), turning(170, 7.5), turning(350, 0.5)},
+		{1, 2, 10, 20, linear(0, -5), linear(0, 5), linear(0, 0), linear(10, 0.125), linear(2, 0)},
+	}
+	grenades := []roundTripGrenade{
+		{7, 3, 8, func(f int) int { return 100 * f }, func(f int) int { return -30 * f }, func(f int) int { return 200 - (f-5)*(f-5) }},
+		{8, 5, 6, func(f int) int { return 1 }, func(f int) int { return 2 }, func(f int) int { return 3 }},
+		{9, 15, 19, func(f int) int { return -f }, func(f int) int { return f * f }, func(f int) int { return 0 }},
+	}
+
+	for _, codecs := range []app.StreamCodecs{
+		app.DefaultStreamCodecs,
+		{Position: codec.Rice, View: codec.Varint, Grenade: codec.Delta, PositionOrder: 2, ViewOrder: 1, GrenadeOrder: 2, ViewPrecision: 4, KeyframeInterval: 3},
+		{Position: codec.EliasFano, View: codec.Delta, Grenade: codec.Gamma, PositionOrder: 0, ViewOrder: 2, GrenadeOrder: 1, ViewPrecision: 8},
+	} {
+		plain, elias := storedAs(players, grenades, codecs)
+
+		var positions [2][]app.FramePositions
+		var projectiles [2][]app.FrameProjectiles
+		for i, src := range []MemorySource{plain, elias} {
+			m := &Match{src: src}
+			it, err := m.Positions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for it.Next() {
+				positions[i] = append(positions[i], it.Value())
+			}
+			if err := it.Err(); err != nil {
+				t.Fatal(codecs, err)
+			}
+			it.Close()
+			pit, err := m.Projectiles()
+			if err != nil {
+				t.Fatal(codecs, err)
+			}
+			for pit.Next() {
+				projectiles[i] = append(projectiles[i], pit.Value())
+			}
+			if err := pit.Err(); err != nil {
+				t.Fatal(codecs, err)
+			}
+			pit.Close()
+		}
+
+		if len(positions[0]) != 20 || !reflect.DeepEqual(positions[1], positions[0]) {
+			t.Error("Elias positions with ", codecs, " differ, got ", positions[1], " instead of ", positions[0])
+		}
+		if len(projectiles[0]) != 11 || !reflect.DeepEqual(projectiles[1], projectiles[0]) {
+			t.Error("Elias grenades with ", codecs, " differ, got ", projectiles[1], " instead of ", projectiles[0])
+		}
+	}
+}
+
+func TestTeams(t *testing.T) {
+	state := func(round int, players ...app.PlayerStateInfo) app.GameStateInfo {
+		return app.GameStateInfo{RoundNumber: round, Players: players}
+	}
+	T, CT := common.TeamTerrorists, common.TeamCounterTerrorists
+	m := &Match{src: MemorySource{app.ClGameState: {
+		state(1, app.PlayerStateInfo{SteamID: 1, Team: T}, app.PlayerStateInfo{SteamID: 2, Team: CT}),
+		// a player joining later in the round
+		state(1, app.PlayerStateInfo{SteamID: 1, Team: CT}, app.PlayerStateInfo{SteamID: 3, Team: T}),
+		state(16, app.PlayerStateInfo{SteamID: 1, Team: CT}, app.PlayerStateInfo{SteamID: 2, Team: T}),
+	}}}
+
+	teams, err := m.Teams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		round    int
+		SteamID  int64
+		expected common.Team
+	}{{1, 1, T}, {1, 2, CT}, {1, 3, T}, {16, 1, CT}, {16, 2, T}, {16, 3, common.TeamUnassigned}, {2, 1, common.TeamUnassigned}} {
+		if res := teams.Team(c.round, c.SteamID); res != c.expected {
+			t.Error("Team failed for player ", c.SteamID, " in round ", c.round, ", got ", res, " instead of ", c.expected)
+		}
+	}
+}
+
+func TestInfernoLifecycles(t *testing.T) {
+	expected := []app.InfernoLifecycle{
+		{UniqueID: 1, RoundNumber: 2, StartFrame: 10, ExpireFrame: 40, Thrower: 5, Weapon: common.EqMolotov, MaxArea: 12000, AreaTime: 50000,
+			Centroid: app.FixedVector3{X: 1, Y: 2, Z: 3}, Callout: "Banana", Damaged: []app.InfernoDamage{{Player: 6, HealthDamage: 30, Hits: 4}}},
+		{UniqueID: 2, RoundNumber: 2, StartFrame: 30, ExpireFrame: -1, ExpireTick: -1, Thrower: -1},
+	}
+	m := &Match{src: MemorySource{app.ClInfernoLifecycles: {expected[0], expected[1]}}}
+	res, err := m.InfernoLifecycles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Error("InfernoLifecycles failed, got ", res, " instead of ", expected)
+	}
+}
@@ -0,0 +1,245 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"csgo-parser-mongodb/overview"
+	"csgo-parser-mongodb/zones"
+	"fmt"
+	"github.com/golang/geo/r3"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"github.com/markus-wa/demoinfocs-golang/events"
+	"time"
+)
+
+// Header is the stored demo header together with the versions of the parser that stored the match.
+type Header struct {
+	Filestamp		string			`bson:"Filestamp"`
+	Protocol		int				`bson:"Protocol"`
+	NetworkProtocol	int				`bson:"NetworkProtocol"`
+	ServerName		string			`bson:"ServerName"`
+	ClientName		string			`bson:"ClientName"`
+	MapName			string			`bson:"MapName"`
+	GameDirectory	string			`bson:"GameDirectory"`
+	PlaybackTime	time.Duration	`bson:"PlaybackTime"`
+	PlaybackTicks	int				`bson:"PlaybackTicks"`
+	PlaybackFrames	int				`bson:"PlaybackFrames"`
+	SignonLength	int				`bson:"SignonLength"`
+	SchemaVersion	int				`bson:"SchemaVersion"`
+	ParserVersion	string			`bson:"ParserVersion"`
+	// PositionPrecision is the number of bits after the point of the stored fixed point positions.
+	PositionPrecision	int			`bson:"PositionPrecision"`
+	// ViewPrecision is the number of bits after the point of the view angles of the stored game states,
+	// 0 for whole degrees as matches parsed before it was recorded have them.
+	ViewPrecision		int			`bson:"ViewPrecision"`
+	// SampleRate is how many times a second of game time positions were saved,
+	// 0 for matches parsed before it was recorded.
+	SampleRate			float64		`bson:"SampleRate"`
+	// Overview is the radar transform of the map, nil if the parser had no overview of it
+	Overview			*overview.Map	`bson:"Overview"`
+}
+
+// Round is put together from the RoundStart, RoundFreezetimeEnd and RoundEnd events.
+// Frames and ticks of events that didn't happen (e.g. the end of an unfinished last round) are -1.
+type Round struct {
+	Number				int
+	StartFrame			int
+	StartTick			int
+	FreezetimeEndFrame	int
+	FreezetimeEndTick	int
+	EndFrame			int
+	EndTick				int
+	Winner				common.Team
+	Reason				events.RoundEndReason
+}
+
+// Match is a parsed match loaded back from a Source.
+// Events and static data are loaded at once, per frame data is read on demand by the iterators.
+type Match struct {
+	Header		Header
+	Players		[]app.PlayerStaticInfo
+	Entities	[]app.EquipmentElementStaticInfo
+	Rounds		[]Round
+	Events		[]Event
+
+	src Source
+}
+
+// Load reads a match from src. Matches stored with a schema version older than app.MinReadableSchemaVersion
+// have to be migrated first.
+func Load(src Source) (*Match, error) {
+	m := &Match{src: src}
+
+	if err := src.Header(&m.Header); err != nil {
+		return nil, fmt.Errorf("reading header: %v", err)
+	}
+	if v := m.Header.SchemaVersion; v < app.MinReadableSchemaVersion {
+		return nil, fmt.Errorf("match has schema version %d, expected at least %d; run the migrate command first",
+			v, app.MinReadableSchemaVersion)
+	} else if v > app.SchemaVersion {
+		return nil, fmt.Errorf("match has schema version %d, newer than supported %d", v, app.SchemaVersion)
+	}
+
+	if err := readAll(src, app.ClPlayers, func(c Cursor) error {
+		var p app.PlayerStaticInfo
+		err := c.Decode(&p)
+		m.Players = append(m.Players, p)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := readAll(src, app.ClEntities, func(c Cursor) error {
+		var e app.EquipmentElementStaticInfo
+		err := c.Decode(&e)
+		m.Entities = append(m.Entities, e)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := readAll(src, app.ClEvents, func(c Cursor) error {
+		var se storedEvent
+		if err := c.Decode(&se); err != nil {
+			return err
+		}
+		ev, err := se.event()
+		m.Events = append(m.Events, ev)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	m.Rounds = roundsFromEvents(m.Events)
+
+	return m, nil
+}
+
+// EventsOfType returns the events of the given type in the order they happened.
+func (m *Match) EventsOfType(t app.EvType) []Event {
+	var res []Event
+	for _, ev := range m.Events {
+		if ev.Type == t {
+			res = append(res, ev)
+		}
+	}
+	return res
+}
+
+// Position converts a stored fixed point position to world coordinates.
+func (m *Match) Position(fv app.FixedVector3) r3.Vector {
+	return fv.Vector(m.Header.PositionPrecision)
+}
+
+// Overview returns the radar transform of the match's map: the one stored with the match
+// or else the one of reg under the header's MapName. reg may be nil.
+func (m *Match) Overview(reg overview.Registry) (overview.Map, bool) {
+	if m.Header.Overview != nil {
+		return *m.Header.Overview, true
+	}
+	return reg.Map(m.Header.MapName)
+}
+
+// Radar converts a stored fixed point position to the radar of the match's map, see Overview.
+func (m *Match) Radar(fv app.FixedVector3, reg overview.Registry) (overview.RadarPoint, bool) {
+	OV, ok := m.Overview(reg)
+	if !ok {
+		return overview.RadarPoint{}, false
+	}
+	return OV.ToRadar(m.Position(fv)), true
+}
+
+// Callout returns the place of the match's map a stored fixed point position is in, using the zones of reg.
+// It is for positions stored without their callout, events are stored with theirs if the parser had zones of the map.
+func (m *Match) Callout(fv app.FixedVector3, reg zones.Registry) (string, bool) {
+	Z, ok := reg.Map(m.Header.MapName)
+	if !ok {
+		return "", false
+	}
+	return Z.Callout(m.Position(fv))
+}
+
+// SampleRate returns how many frames a second of game time were saved. For matches stored before it was
+// recorded it is estimated from the frames and ticks of the rounds.
+func (m *Match) SampleRate() (float64, error) {
+	if m.Header.SampleRate > 0 {
+		return m.Header.SampleRate, nil
+	}
+	frames, ticks := 0, 0
+	for _, r := range m.Rounds {
+		if r.StartTick >= 0 && r.EndTick > r.StartTick && r.StartFrame >= 0 && r.EndFrame > r.StartFrame {
+			frames += r.EndFrame - r.StartFrame
+			ticks += r.EndTick - r.StartTick
+		}
+	}
+	if m.Header.PlaybackTime <= 0 || ticks == 0 {
+		return 0, fmt.Errorf("match has no sample rate and no rounds with ticks to estimate it by")
+	}
+	tickRate := float64(m.Header.PlaybackTicks) / m.Header.PlaybackTime.Seconds()
+	return float64(frames) / (float64(ticks) / tickRate), nil
+}
+
+// OpeningKills returns the first kill of every round.
+func (m *Match) OpeningKills() []Event {
+	var res []Event
+	for _, ev := range m.Events {
+		if ev.Type == app.Kill && ev.RoundNumber > 0 && (len(res) == 0 || res[len(res)-1].RoundNumber != ev.RoundNumber) {
+			res = append(res, ev)
+		}
+	}
+	return res
+}
+
+// Player returns the static info of the player with the given SteamID.
+func (m *Match) Player(SteamID int64) (app.PlayerStaticInfo, bool) {
+	for _, p := range m.Players {
+		if p.SteamID == SteamID {
+			return p, true
+		}
+	}
+	return app.PlayerStaticInfo{}, false
+}
+
+func roundsFromEvents(evs []Event) []Round {
+	var rounds []Round
+	current := func(number int) *Round {
+		if len(rounds) == 0 || rounds[len(rounds)-1].Number != number {
+			rounds = append(rounds, Round{number, -1, -1, -1, -1, -1, -1, common.TeamUnassigned, 0})
+		}
+		return &rounds[len(rounds)-1]
+	}
+
+	for _, ev := range evs {
+		if ev.RoundNumber == 0 {
+			continue
+		}
+		switch ev.Type {
+		case app.RoundStart:
+			r := current(ev.RoundNumber)
+			r.StartFrame, r.StartTick = ev.FrameNumber, ev.Tick
+		case app.RoundFreezetimeEnd:
+			r := current(ev.RoundNumber)
+			r.FreezetimeEndFrame, r.FreezetimeEndTick = ev.FrameNumber, ev.Tick
+		case app.RoundEnd:
+			r := current(ev.RoundNumber)
+			r.EndFrame, r.EndTick = ev.FrameNumber, ev.Tick
+			if data, ok := ev.Data.(*RoundEndData); ok {
+				r.Winner, r.Reason = data.Winner, data.Reason
+			}
+		}
+	}
+	return rounds
+}
+
+func readAll(src Source, cl app.ClIndex, fn func(c Cursor) error) error {
+	cursor, err := src.Find(cl)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	for cursor.Next() {
+		if err := fn(cursor); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
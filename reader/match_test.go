@@ -0,0 +1,24 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"testing"
+)
+
+func TestLoadSchemaVersion(t *testing.T) {
+	for v, readable := range map[int]bool{
+		1:                                false,
+		app.MinReadableSchemaVersion - 1: false,
+		app.MinReadableSchemaVersion:     true,
+		app.SchemaVersion:                true,
+		app.SchemaVersion + 1:            false,
+	} {
+		src := MemorySource{app.ClHeader: {Header{MapName: "de_dust2", SchemaVersion: v}}}
+		m, err := Load(src)
+		if readable && (err != nil || m.Header.SchemaVersion != v) {
+			t.Error("Load failed on schema version ", v, ", got ", err)
+		} else if !readable && err == nil {
+			t.Error("Load didn't fail on schema version ", v)
+		}
+	}
+}
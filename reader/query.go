@@ -0,0 +1,248 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"fmt"
+	"github.com/golang/geo/r3"
+	"math"
+	"sort"
+)
+
+// PlayerPosition is where a player is, in world coordinates, and looks at, in degrees.
+type PlayerPosition struct {
+	SteamID  int64
+	Position r3.Vector
+	ViewX    float64
+	ViewY    float64
+}
+
+// GrenadePosition is where a grenade in flight is, in world coordinates.
+type GrenadePosition struct {
+	UniqueID int64
+	Position r3.Vector
+}
+
+// Query looks up where players and grenades are at any tick of a match, fractions of a tick included,
+// e.g. to line positions up with the frames of a video. Positions between saved frames are interpolated linearly.
+// The ticks of saved frames are taken from the game states, which store the tick of their frame;
+// the frames in between are taken to be evenly spaced in ticks, as they are when positions are sampled by tick.
+// Kill events are extra samples of the victim and the killer at the very tick of the kill.
+type Query struct {
+	m       *Match
+	seeker  *PositionSeeker
+	anchors []tickAnchor
+	// samples of kill events by SteamID, sorted by tick
+	kills map[int64][]playerSample
+}
+
+// frameEpsilon is how close to a saved frame a tick has to come out to be taken as its tick.
+const frameEpsilon = 1e-9
+
+// tickAnchor is a saved frame whose tick is known.
+type tickAnchor struct {
+	frame, tick int
+}
+
+type playerSample struct {
+	tick float64
+	PlayerPosition
+	// whether the player died at the sample, so there are none after it to interpolate to
+	died bool
+}
+
+// Query prepares the match for looking up positions by tick.
+// It needs at least two game states with ticks, which matches stored before ticks were recorded don't have.
+func (m *Match) Query() (*Query, error) {
+	seeker, err := m.Seeker()
+	if err != nil {
+		return nil, err
+	}
+	q := &Query{m: m, seeker: seeker, kills: make(map[int64][]playerSample)}
+
+	if err := readAll(m.src, app.ClGameState, func(c Cursor) error {
+		var a struct {
+			FrameNumber int `bson:"FrameNumber"`
+			Tick        int `bson:"Tick"`
+		}
+		if err := c.Decode(&a); err != nil {
+			return err
+		}
+		// the frame and tick of every anchor have to be after the ones before
+		if a.Tick >= 0 && (len(q.anchors) == 0 || a.FrameNumber > q.anchors[len(q.anchors)-1].frame && a.Tick > q.anchors[len(q.anchors)-1].tick) {
+			q.anchors = append(q.anchors, tickAnchor{a.FrameNumber, a.Tick})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(q.anchors) < 2 {
+		return nil, fmt.Errorf("match has %d game states with ticks, at least 2 are needed to tell the tick of a frame", len(q.anchors))
+	}
+
+	for _, ev := range m.EventsOfType(app.Kill) {
+		data, ok := ev.Data.(*KillData)
+		if !ok || ev.Tick < 0 {
+			continue
+		}
+		if data.VictimMovement != nil {
+			q.addKillSample(float64(ev.Tick), *data.VictimMovement, true)
+		}
+		if data.KillerMovement != nil {
+			q.addKillSample(float64(ev.Tick), *data.KillerMovement, false)
+		}
+	}
+	for _, samples := range q.kills {
+		sort.SliceStable(samples, func(i, j int) bool {
+			return samples[i].tick < samples[j].tick
+		})
+	}
+	return q, nil
+}
+
+func (q *Query) addKillSample(tick float64, PMI app.PlayerMovementInfo, died bool) {
+	q.kills[PMI.SteamID] = append(q.kills[PMI.SteamID], playerSample{tick, q.playerPosition(PMI), died})
+}
+
+// FrameAt returns the saved frame the tick falls in, with fractions of a frame for ticks between saved frames.
+// Ticks before the first game state or after the last are extrapolated from the nearest two.
+func (q *Query) FrameAt(tick float64) float64 {
+	i := sort.Search(len(q.anchors), func(i int) bool {
+		return float64(q.anchors[i].tick) > tick
+	}) - 1
+	a, b := q.anchorsAround(i)
+	f := float64(a.frame) + (tick-float64(a.tick))*float64(b.frame-a.frame)/float64(b.tick-a.tick)
+	// ticks of saved frames come out as whole frames despite rounding errors
+	if math.Abs(f-math.Round(f)) < frameEpsilon {
+		f = math.Round(f)
+	}
+	return f
+}
+
+// TickAt returns the tick of a saved frame, see FrameAt.
+func (q *Query) TickAt(frame int) float64 {
+	i := sort.Search(len(q.anchors), func(i int) bool {
+		return q.anchors[i].frame > frame
+	}) - 1
+	a, b := q.anchorsAround(i)
+	return float64(a.tick) + float64(frame-a.frame)*float64(b.tick-a.tick)/float64(b.frame-a.frame)
+}
+
+// anchorsAround returns the anchor i and the one after it, the first or last two if there are none.
+func (q *Query) anchorsAround(i int) (tickAnchor, tickAnchor) {
+	if i < 0 {
+		i = 0
+	}
+	if i > len(q.anchors)-2 {
+		i = len(q.anchors) - 2
+	}
+	return q.anchors[i], q.anchors[i+1]
+}
+
+// PlayersAt returns where the players alive at the tick are and look at, sorted by SteamID.
+// A player is left out if there is no sample of them after the tick to interpolate to, unless the tick is the one sampled.
+func (q *Query) PlayersAt(tick float64) ([]PlayerPosition, error) {
+	f := q.FrameAt(tick)
+	frame := int(math.Floor(f))
+	exact := f == float64(frame)
+	before, err := q.seeker.PositionsAt(frame)
+	if err != nil {
+		return nil, err
+	}
+	after, err := q.seeker.PositionsAt(frame + 1)
+	if err != nil {
+		return nil, err
+	}
+	start, end := q.TickAt(frame), q.TickAt(frame+1)
+	next := make(map[int64]app.PlayerMovementInfo, len(after.PlayersPositions))
+	for _, PMI := range after.PlayersPositions {
+		next[PMI.SteamID] = PMI
+	}
+
+	var res []PlayerPosition
+	for _, PMI := range before.PlayersPositions {
+		prev := playerSample{tick: start, PlayerPosition: q.playerPosition(PMI)}
+		var succ *playerSample
+		if sampled, ok := next[PMI.SteamID]; ok {
+			succ = &playerSample{tick: end, PlayerPosition: q.playerPosition(sampled)}
+		}
+		for _, kill := range q.kills[PMI.SteamID] {
+			if kill.tick < start || kill.tick > end {
+				continue
+			}
+			if kill.tick <= tick {
+				prev = kill
+			} else if succ == nil || kill.tick < succ.tick {
+				kill := kill
+				succ = &kill
+			}
+		}
+
+		switch {
+		case prev.tick == tick, exact && prev.tick == start:
+			res = append(res, prev.PlayerPosition)
+		case prev.died || succ == nil:
+			continue
+		default:
+			res = append(res, interpolatePlayer(prev.PlayerPosition, succ.PlayerPosition, (tick-prev.tick)/(succ.tick-prev.tick)))
+		}
+	}
+	return res, nil
+}
+
+// GrenadesAt returns where the grenades flying at the tick are, sorted by UniqueID.
+// A grenade is left out if there is no sample of it after the tick to interpolate to, unless the tick is the one sampled.
+func (q *Query) GrenadesAt(tick float64) ([]GrenadePosition, error) {
+	f := q.FrameAt(tick)
+	frame := int(math.Floor(f))
+	before, err := q.seeker.ProjectilesAt(frame)
+	if err != nil {
+		return nil, err
+	}
+	after, err := q.seeker.ProjectilesAt(frame + 1)
+	if err != nil {
+		return nil, err
+	}
+	next := make(map[int64]app.GrenadePositionInfo, len(after.GrenadesPositions))
+	for _, GPI := range after.GrenadesPositions {
+		next[GPI.UniqueID] = GPI
+	}
+
+	var res []GrenadePosition
+	for _, GPI := range before.GrenadesPositions {
+		position := q.m.Position(GPI.Position)
+		if f == float64(frame) {
+			res = append(res, GrenadePosition{GPI.UniqueID, position})
+		} else if succ, ok := next[GPI.UniqueID]; ok {
+			res = append(res, GrenadePosition{GPI.UniqueID, lerpVector(position, q.m.Position(succ.Position), f-float64(frame))})
+		}
+	}
+	return res, nil
+}
+
+func (q *Query) playerPosition(PMI app.PlayerMovementInfo) PlayerPosition {
+	return PlayerPosition{PMI.SteamID, q.m.Position(PMI.Position), float64(PMI.ViewX), float64(PMI.ViewY)}
+}
+
+// interpolatePlayer returns the position the fraction t of the way from a to b,
+// turning the short way around between their view angles.
+func interpolatePlayer(a, b PlayerPosition, t float64) PlayerPosition {
+	return PlayerPosition{
+		a.SteamID,
+		lerpVector(a.Position, b.Position, t),
+		lerpAngle(a.ViewX, b.ViewX, t),
+		lerpAngle(a.ViewY, b.ViewY, t),
+	}
+}
+
+func lerpVector(a, b r3.Vector, t float64) r3.Vector {
+	return a.Add(b.Sub(a).Mul(t))
+}
+
+// lerpAngle interpolates between two angles in degrees the short way around, into [0, 360).
+func lerpAngle(a, b, t float64) float64 {
+	v := math.Mod(a+math.Remainder(b-a, 360)*t, 360)
+	if v < 0 {
+		v += 360
+	}
+	return v
+}
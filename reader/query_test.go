@@ -0,0 +1,93 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"github.com/golang/geo/r3"
+	"reflect"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	frame := func(n int, players ...app.PlayerMovementInfo) app.FramePositions {
+		return app.FramePositions{FrameNumber: n, PlayersPositions: players}
+	}
+	player := func(SteamID int64, x int32, viewX float32) app.PlayerMovementInfo {
+		return app.PlayerMovementInfo{SteamID: SteamID, Position: app.FixedVector3{X: x}, ViewX: viewX}
+	}
+	grenade := func(n int, x int32) app.FrameProjectiles {
+		return app.FrameProjectiles{FrameNumber: n, GrenadesPositions: []app.GrenadePositionInfo{{UniqueID: 7, Position: app.FixedVector3{X: x}}}}
+	}
+	src := MemorySource{
+		// 4 ticks a frame
+		app.ClGameState: {
+			app.GameStateInfo{FrameNumber: 1, Tick: 100},
+			app.GameStateInfo{FrameNumber: 5, Tick: 116},
+		},
+		app.ClPositions: {
+			frame(1, player(1, 10, 350), player(2, 200, 0)),
+			frame(2, player(1, 20, 10), player(2, 300, 0)),
+			frame(3, player(1, 30, 10)),
+			frame(4, player(1, 40, 10)),
+		},
+		app.ClProjectiles: {grenade(2, 0), grenade(3, 8)},
+	}
+	victim, killer := player(2, 500, 0), player(1, 30, 10)
+	m := &Match{src: src, Events: []Event{
+		{FrameNumber: 2, Tick: 106, RoundNumber: 1, Type: app.Kill, Data: &KillData{Victim: 2, Killer: 1, VictimMovement: &victim, KillerMovement: &killer}},
+	}}
+
+	q, err := m.Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := q.FrameAt(102); res != 1.5 {
+		t.Error("FrameAt failed, got ", res, " instead of ", 1.5)
+	}
+	if res := q.FrameAt(120); res != 6 {
+		t.Error("FrameAt failed after the last game state, got ", res, " instead of ", 6)
+	}
+	if res := q.TickAt(3); res != 108 {
+		t.Error("TickAt failed, got ", res, " instead of ", 108)
+	}
+
+	at := func(SteamID int64, x, viewX float64) PlayerPosition {
+		return PlayerPosition{SteamID: SteamID, Position: r3.Vector{X: x}, ViewX: viewX}
+	}
+	for _, c := range []struct {
+		tick     float64
+		expected []PlayerPosition
+	}{
+		// view turns the short way around
+		{102, []PlayerPosition{at(1, 15, 0), at(2, 250, 0)}},
+		{104, []PlayerPosition{at(1, 20, 10), at(2, 300, 0)}},
+		// up to the positions at the kill
+		{105, []PlayerPosition{at(1, 25, 10), at(2, 400, 0)}},
+		{106, []PlayerPosition{at(1, 30, 10), at(2, 500, 0)}},
+		// the victim is dead after it
+		{107, []PlayerPosition{at(1, 30, 10)}},
+		// nothing to interpolate to after the last frame
+		{113, nil},
+	} {
+		if res, err := q.PlayersAt(c.tick); err != nil || !reflect.DeepEqual(res, c.expected) {
+			t.Error("PlayersAt failed at tick ", c.tick, ", got ", res, err, " instead of ", c.expected)
+		}
+	}
+
+	for _, c := range []struct {
+		tick     float64
+		expected []GrenadePosition
+	}{
+		{104, []GrenadePosition{{7, r3.Vector{}}}},
+		{107, []GrenadePosition{{7, r3.Vector{X: 6}}}},
+		{108, []GrenadePosition{{7, r3.Vector{X: 8}}}},
+		{109, nil},
+	} {
+		if res, err := q.GrenadesAt(c.tick); err != nil || !reflect.DeepEqual(res, c.expected) {
+			t.Error("GrenadesAt failed at tick ", c.tick, ", got ", res, err, " instead of ", c.expected)
+		}
+	}
+
+	if _, err := (&Match{src: MemorySource{app.ClGameState: {app.GameStateInfo{FrameNumber: 1, Tick: -1}}}}).Query(); err == nil {
+		t.Error("Query succeeded without ticks to tell the tick of a frame")
+	}
+}
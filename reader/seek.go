@@ -0,0 +1,193 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"go.mongodb.org/mongo-driver/bson"
+	"sort"
+)
+
+// PositionSeeker looks up where players and grenades are in any frame of a match, for replays that jump around.
+// Encoded movements are kept encoded in memory and only the samples of the frame looked up are decoded,
+// from the keyframe before it if the streams have keyframes. Only the rounds and grenades whose frames
+// the frame falls in are looked at.
+type PositionSeeker struct {
+	rounds			[]seekRound
+	roundRanges		frameRanges
+	grenades		[]app.GrenadePositionInfoEncoded
+	grenadeRanges	frameRanges
+	// positions stored without encoding, by frame
+	frames		map[int]app.FramePositions
+	projectiles	map[int]app.FrameProjectiles
+}
+
+// seekRound holds the encoded movements of a round, which are sampled in no frame before first or after last.
+type seekRound struct {
+	first, last	int
+	movements	[]app.PlayerMovementInfoEncoded
+}
+
+// frameRanges indexes things sampled from a first to a last frame, sorted by their first frame.
+// reach is the last frame of any of them up to and including each, so looking up a frame stops
+// at the first one before it that nothing from there on back reaches.
+type frameRanges struct {
+	first, last, reach []int
+}
+
+func newFrameRanges(n int, bounds func(i int) (int, int)) frameRanges {
+	r := frameRanges{make([]int, n), make([]int, n), make([]int, n)}
+	for i := 0; i < n; i++ {
+		r.first[i], r.last[i] = bounds(i)
+		r.reach[i] = r.last[i]
+		if i > 0 && r.reach[i-1] > r.reach[i] {
+			r.reach[i] = r.reach[i-1]
+		}
+	}
+	return r
+}
+
+// covering calls f with the index of everything the frame is between the first and last frame of.
+func (r frameRanges) covering(frame int, f func(i int) error) error {
+	for i := sort.SearchInts(r.first, frame+1) - 1; i >= 0 && r.reach[i] >= frame; i-- {
+		if r.last[i] < frame {
+			continue
+		}
+		if err := f(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Seeker reads the positions of the match into a PositionSeeker.
+func (m *Match) Seeker() (*PositionSeeker, error) {
+	s := &PositionSeeker{frames: make(map[int]app.FramePositions), projectiles: make(map[int]app.FrameProjectiles)}
+
+	if err := readAll(m.src, app.ClPositions, func(c Cursor) error {
+		var raw bson.Raw
+		if err := c.Decode(&raw); err != nil {
+			return err
+		}
+		if _, err := raw.LookupErr("PlayerMovements"); err != nil {
+			var FP app.FramePositions
+			err := bson.Unmarshal(raw, &FP)
+			s.frames[FP.FrameNumber] = FP
+			return err
+		}
+		var RM app.RoundMovement
+		if err := bson.Unmarshal(raw, &RM); err != nil {
+			return err
+		}
+		if len(RM.PlayerMovements) == 0 {
+			return nil
+		}
+		round := seekRound{first: RM.PlayerMovements[0].StartFrame, last: RM.PlayerMovements[0].StartFrame}
+		for _, PMIE := range RM.PlayerMovements {
+			if PMIE.Samples == 0 {
+				// documents stored before the number of samples was recorded have to be decoded to count them
+				PM, err := app.DecodePlayerMovement(PMIE)
+				if err != nil {
+					return err
+				}
+				PMIE.Samples = len(PM.PositionX)
+			}
+			// samples are taken up to the frame the movement was stored in, every frame of them if none were left out
+			last := PMIE.StartFrame + PMIE.Samples - 1
+			if PMIE.EndFrame > last {
+				last = PMIE.EndFrame
+			}
+			if PMIE.StartFrame < round.first {
+				round.first = PMIE.StartFrame
+			}
+			if last > round.last {
+				round.last = last
+			}
+			round.movements = append(round.movements, PMIE)
+		}
+		s.rounds = append(s.rounds, round)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := readAll(m.src, app.ClProjectiles, func(c Cursor) error {
+		var raw bson.Raw
+		if err := c.Decode(&raw); err != nil {
+			return err
+		}
+		if _, err := raw.LookupErr("GrenadePositions"); err == nil {
+			var FP app.FrameProjectiles
+			err := bson.Unmarshal(raw, &FP)
+			s.projectiles[FP.FrameNumber] = FP
+			return err
+		}
+		var GPIE app.GrenadePositionInfoEncoded
+		if err := bson.Unmarshal(raw, &GPIE); err != nil {
+			return err
+		}
+		if GPIE.Samples == 0 {
+			GM, err := app.DecodeGrenadeMovement(GPIE)
+			if err != nil {
+				return err
+			}
+			GPIE.Samples = len(GM.PositionX)
+		}
+		s.grenades = append(s.grenades, GPIE)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(s.rounds, func(i, j int) bool { return s.rounds[i].first < s.rounds[j].first })
+	s.roundRanges = newFrameRanges(len(s.rounds), func(i int) (int, int) { return s.rounds[i].first, s.rounds[i].last })
+	sort.SliceStable(s.grenades, func(i, j int) bool { return s.grenades[i].StartFrame < s.grenades[j].StartFrame })
+	s.grenadeRanges = newFrameRanges(len(s.grenades), func(i int) (int, int) {
+		return s.grenades[i].StartFrame, s.grenades[i].StartFrame + s.grenades[i].Samples - 1
+	})
+	return s, nil
+}
+
+// PositionsAt returns the positions of the players in a frame, sorted by SteamID like the iterator's.
+// Players adaptive sampling left out of the frame are interpolated, as the iterator does.
+// A frame that wasn't saved has no positions.
+func (s *PositionSeeker) PositionsAt(frame int) (app.FramePositions, error) {
+	FP := s.frames[frame]
+	FP.FrameNumber = frame
+	if err := s.roundRanges.covering(frame, func(i int) error {
+		for _, PMIE := range s.rounds[i].movements {
+			if frame < PMIE.StartFrame {
+				continue
+			}
+			PMI, ok, err := app.PlayerMovementAt(PMIE, frame)
+			if err != nil {
+				return err
+			}
+			if ok {
+				FP.PlayersPositions = append(FP.PlayersPositions, PMI)
+			}
+		}
+		return nil
+	}); err != nil {
+		return app.FramePositions{}, err
+	}
+	sort.Slice(FP.PlayersPositions, func(i, j int) bool {
+		return FP.PlayersPositions[i].SteamID < FP.PlayersPositions[j].SteamID
+	})
+	return FP, nil
+}
+
+// ProjectilesAt returns the positions of the grenades flying in a frame, sorted by UniqueID like the iterator's.
+func (s *PositionSeeker) ProjectilesAt(frame int) (app.FrameProjectiles, error) {
+	FP := s.projectiles[frame]
+	FP.FrameNumber = frame
+	if err := s.grenadeRanges.covering(frame, func(i int) error {
+		GPI, err := app.GrenadePositionAt(s.grenades[i], frame)
+		FP.GrenadesPositions = append(FP.GrenadesPositions, GPI)
+		return err
+	}); err != nil {
+		return app.FrameProjectiles{}, err
+	}
+	sort.Slice(FP.GrenadesPositions, func(i, j int) bool {
+		return FP.GrenadesPositions[i].UniqueID < FP.GrenadesPositions[j].UniqueID
+	})
+	return FP, nil
+}
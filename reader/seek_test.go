@@ -0,0 +1,127 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"reflect"
+	"testing"
+)
+
+func TestSeeker(t *testing.T) {
+	codecs := app.DefaultStreamCodecs
+	codecs.KeyframeInterval = 4
+	movement := func(start, n, step int) *app.PlayerMovement {
+		PM := &app.PlayerMovement{StartFrame: start}
+		for i := 0; i < n; i++ {
+			PM.PositionX = append(PM.PositionX, 100+i*step)
+			PM.PositionY = append(PM.PositionY, -i*i)
+			PM.PositionZ = append(PM.PositionZ, 64)
+			PM.ViewX = append(PM.ViewX, (350+i*step)%360)
+			PM.ViewY = append(PM.ViewY, i)
+		}
+		return PM
+	}
+	legacy := app.NewPlayerMovementInfoEncoded(3, movement(1, 5, 1), 5, app.DefaultStreamCodecs)
+	legacy.Samples = 0
+	src := MemorySource{
+		app.ClPositions: {
+			app.RoundMovement{RoundNumber: 1, PlayerMovements: []app.PlayerMovementInfoEncoded{
+				app.NewPlayerMovementInfoEncoded(2, movement(1, 10, 3), 10, codecs),
+				app.NewPlayerMovementInfoEncoded(1, movement(3, 8, -5), 10, codecs),
+				legacy,
+			}},
+		},
+		app.ClProjectiles: {
+			app.NewGrenadePositionInfoEncoded(7, &app.GrenadeMovement{StartFrame: 4, PositionX: []int{0, 1, 2, 3, 4, 5}, PositionY: []int{0, 0, 0, 0, 0, 0}, PositionZ: []int{9, 8, 6, 3, -1, -6}}, codecs),
+		},
+	}
+	m := &Match{src: src}
+
+	seeker, err := m.Seeker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions, err := m.Positions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for positions.Next() {
+		expected := positions.Value()
+		if res, err := seeker.PositionsAt(expected.FrameNumber); err != nil || !reflect.DeepEqual(res, expected) {
+			t.Error("PositionsAt failed, got ", res, err, " instead of ", expected)
+		}
+	}
+	if res, err := seeker.PositionsAt(11); err != nil || len(res.PlayersPositions) != 0 {
+		t.Error("PositionsAt failed after the last frame, got ", res, err)
+	}
+
+	projectiles, err := m.Projectiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for projectiles.Next() {
+		expected := projectiles.Value()
+		if res, err := seeker.ProjectilesAt(expected.FrameNumber); err != nil || !reflect.DeepEqual(res, expected) {
+			t.Error("ProjectilesAt failed, got ", res, err, " instead of ", expected)
+		}
+	}
+}
+
+func TestSeekerRounds(t *testing.T) {
+	linear := func(from, step float64) func(int) float64 {
+		return func(f int) float64 { return from + step*float64(f) }
+	}
+	players := []roundTripPlayer{
+		{1, 1, 1, 9, linear(-300, 17), linear(1200, -3), linear(64, 0), linear(10, 1), linear(5, 0)},
+		{2, 1, 1, 4, linear(800, -40), linear(-20, 0), linear(-160, 2), linear(90, 0), linear(0, 0)},
+		{2, 2, 13, 20, linear(500, 9), linear(-700, 11), linear(0, 1), linear(170, 7.5), linear(340, 0.5)},
+		{1, 2, 10, 20, linear(0, -5), linear(0, 5), linear(0, 0), linear(10, 0.125), linear(2, 0)},
+	}
+	grenades := []roundTripGrenade{
+		{9, 15, 19, func(f int) int { return -f }, func(f int) int { return f * f }, func(f int) int { return 0 }},
+		{7, 3, 8, func(f int) int { return 100 * f }, func(f int) int { return -30 * f }, func(f int) int { return 200 - (f-5)*(f-5) }},
+		{8, 5, 6, func(f int) int { return 1 }, func(f int) int { return 2 }, func(f int) int { return 3 }},
+	}
+	codecs := app.DefaultStreamCodecs
+	codecs.ViewPrecision, codecs.KeyframeInterval = 3, 4
+	plain, elias := storedAs(players, grenades, codecs)
+	// the rounds are looked up by their frames, whatever order they were stored in
+	elias[app.ClPositions][0], elias[app.ClPositions][1] = elias[app.ClPositions][1], elias[app.ClPositions][0]
+
+	seeker, err := (&Match{src: elias}).Seeker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPositions := make(map[int]app.FramePositions)
+	for _, doc := range plain[app.ClPositions] {
+		expectedPositions[doc.(app.FramePositions).FrameNumber] = doc.(app.FramePositions)
+	}
+	expectedProjectiles := make(map[int]app.FrameProjectiles)
+	for _, doc := range plain[app.ClProjectiles] {
+		expectedProjectiles[doc.(app.FrameProjectiles).FrameNumber] = doc.(app.FrameProjectiles)
+	}
+	for frame := 0; frame <= 21; frame++ {
+		expected := expectedPositions[frame]
+		expected.FrameNumber = frame
+		if res, err := seeker.PositionsAt(frame); err != nil || !reflect.DeepEqual(res, expected) {
+			t.Error("PositionsAt failed in frame ", frame, ", got ", res, err, " instead of ", expected)
+		}
+		expectedFP := expectedProjectiles[frame]
+		expectedFP.FrameNumber = frame
+		if res, err := seeker.ProjectilesAt(frame); err != nil || !reflect.DeepEqual(res, expectedFP) {
+			t.Error("ProjectilesAt failed in frame ", frame, ", got ", res, err, " instead of ", expectedFP)
+		}
+	}
+
+	bounds := [][2]int{{0, 10}, {2, 3}, {4, 6}, {12, 12}}
+	ranges := newFrameRanges(len(bounds), func(i int) (int, int) { return bounds[i][0], bounds[i][1] })
+	for frame, expected := range map[int][]int{-1: nil, 0: {0}, 3: {1, 0}, 5: {2, 0}, 11: nil, 12: {3}, 13: nil} {
+		var res []int
+		ranges.covering(frame, func(i int) error {
+			res = append(res, i)
+			return nil
+		})
+		if !reflect.DeepEqual(res, expected) {
+			t.Error("covering failed in frame ", frame, ", got ", res, " instead of ", expected)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"github.com/markus-wa/demoinfocs-golang/common"
+)
+
+// SmokeCoverage is what a smoke hid while it was up.
+type SmokeCoverage struct {
+	app.SmokeLifecycle
+	// Team of the thrower
+	Team common.Team
+	// BlockedSightlines counts the sightlines between opposing players alive that passed through the smoke,
+	// once in every saved frame, and Pairs the pairs of players they were between
+	BlockedSightlines int
+	Pairs             int
+	// Value is how long the smoke blocked sightlines in seconds, summed over the pairs of players:
+	// a smoke that hides two enemies from one player for ten seconds is worth 20
+	Value float64
+	// KillsThrough are the kills whose victim the smoke hid from the killer
+	KillsThrough []Event
+}
+
+// Smokes returns the smokes of the match, in the order they faded.
+// Smokes up when their round or the demo ended come last in their round.
+func (m *Match) Smokes() ([]app.SmokeLifecycle, error) {
+	var res []app.SmokeLifecycle
+	err := readAll(m.src, app.ClSmokes, func(c Cursor) error {
+		var S app.SmokeLifecycle
+		err := c.Decode(&S)
+		res = append(res, S)
+		return err
+	})
+	return res, err
+}
+
+// SmokeCoverage returns what every smoke of the match hid, in the order of Smokes. A sightline is the line between
+// the eyes of two players of opposing teams, whatever they look at, and a smoke is taken for a sphere of
+// app.SmokeRadius (see app.SmokeBlocks). Smokes that didn't fade are taken to be up until their round ended.
+func (m *Match) SmokeCoverage() ([]SmokeCoverage, error) {
+	smokes, err := m.Smokes()
+	if err != nil {
+		return nil, err
+	}
+	teams, err := m.Teams()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]SmokeCoverage, len(smokes))
+	// the frames every smoke was up in, from first to last
+	first, last := make([]int, len(smokes)), make([]int, len(smokes))
+	roundEnds := make(map[int]int, len(m.Rounds))
+	for _, r := range m.Rounds {
+		roundEnds[r.Number] = r.EndFrame
+	}
+	for i, S := range smokes {
+		res[i] = SmokeCoverage{SmokeLifecycle: S, Team: teams.Team(S.RoundNumber, S.Thrower)}
+		first[i], last[i] = S.StartFrame, S.ExpireFrame
+		if last[i] < 0 {
+			last[i] = roundEnds[S.RoundNumber]
+		}
+	}
+	if len(smokes) == 0 {
+		return res, nil
+	}
+
+	rate, err := m.SampleRate()
+	if err != nil {
+		return nil, err
+	}
+	seeker, err := m.Seeker()
+	if err != nil {
+		return nil, err
+	}
+	type pair struct{ a, b int64 }
+	pairs := make([]map[pair]bool, len(smokes))
+	for i := range pairs {
+		pairs[i] = make(map[pair]bool)
+	}
+	// frames are looked up once for all smokes up in them
+	from, to := first[0], last[0]
+	for i := range smokes {
+		if first[i] < from {
+			from = first[i]
+		}
+		if last[i] > to {
+			to = last[i]
+		}
+	}
+	for frame := from; frame <= to; frame++ {
+		var up []int
+		for i := range smokes {
+			if first[i] <= frame && frame <= last[i] {
+				up = append(up, i)
+			}
+		}
+		if len(up) == 0 {
+			continue
+		}
+		positions, err := seeker.PositionsAt(frame)
+		if err != nil {
+			return nil, err
+		}
+		players := positions.PlayersPositions
+		for _, i := range up {
+			smoke := m.Position(smokes[i].Position)
+			for a := range players {
+				for b := a + 1; b < len(players); b++ {
+					ta, tb := teams.Team(smokes[i].RoundNumber, players[a].SteamID), teams.Team(smokes[i].RoundNumber, players[b].SteamID)
+					if ta == tb || ta == common.TeamUnassigned || tb == common.TeamUnassigned {
+						continue
+					}
+					if app.SmokeBlocks(smoke, m.Position(players[a].Position), m.Position(players[b].Position)) {
+						res[i].BlockedSightlines++
+						pairs[i][pair{players[a].SteamID, players[b].SteamID}] = true
+					}
+				}
+			}
+		}
+	}
+	for i := range res {
+		res[i].Pairs = len(pairs[i])
+		res[i].Value = float64(res[i].BlockedSightlines) / rate
+	}
+
+	for _, ev := range m.EventsOfType(app.Kill) {
+		data := ev.Data.(*KillData)
+		if data.VictimMovement == nil || data.KillerMovement == nil {
+			continue
+		}
+		for i, S := range smokes {
+			if S.RoundNumber == ev.RoundNumber && first[i] <= ev.FrameNumber && ev.FrameNumber <= last[i] &&
+				app.SmokeBlocks(m.Position(S.Position), m.Position(data.KillerMovement.Position), m.Position(data.VictimMovement.Position)) {
+				res[i].KillsThrough = append(res[i].KillsThrough, ev)
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,63 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"testing"
+)
+
+func TestSmokeCoverage(t *testing.T) {
+	T, CT := common.TeamTerrorists, common.TeamCounterTerrorists
+	player := func(SteamID int64, x, y int32) app.PlayerMovementInfo {
+		return app.PlayerMovementInfo{SteamID: SteamID, Position: app.FixedVector3{X: x, Y: y}}
+	}
+	var positions []interface{}
+	for frame := 10; frame <= 14; frame++ {
+		// 1 and 2 see each other through the smoke, 1 and 3 past it
+		positions = append(positions, app.FramePositions{FrameNumber: frame, PlayersPositions: []app.PlayerMovementInfo{
+			player(1, -500, 0), player(2, 500, 0), player(3, -500, 1000),
+		}})
+	}
+	kill := func(frame int, movements bool) Event {
+		data := map[string]interface{}{"Victim": int64(1), "Killer": int64(2), "Assister": -1, "Weapon": -1}
+		if movements {
+			data["VictimMovement"], data["KillerMovement"] = player(1, -500, 0), player(2, 500, 0)
+		}
+		return decodeStored(t, app.EventInfo{FrameNumber: frame, RoundNumber: 1, EventType: app.Kill, Data: data})
+	}
+
+	m := &Match{
+		Header: Header{SampleRate: 2},
+		src: MemorySource{
+			app.ClGameState: {app.GameStateInfo{RoundNumber: 1, Players: []app.PlayerStateInfo{
+				{SteamID: 1, Team: T}, {SteamID: 2, Team: CT}, {SteamID: 3, Team: CT},
+			}}},
+			app.ClPositions: positions,
+			app.ClSmokes: {
+				app.SmokeLifecycle{Projectile: 5, RoundNumber: 1, StartFrame: 10, ExpireFrame: 12, Thrower: 3},
+				// up until the round ended, away from everyone
+				app.SmokeLifecycle{Projectile: 6, RoundNumber: 1, StartFrame: 11, ExpireFrame: -1, Thrower: 1, Position: app.FixedVector3{Y: 5000}},
+			},
+		},
+		Rounds: []Round{{Number: 1, EndFrame: 13}},
+		Events: []Event{kill(11, true), kill(12, false), kill(14, true)},
+	}
+
+	coverage, err := m.SmokeCoverage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coverage) != 2 {
+		t.Fatal("SmokeCoverage failed, got ", coverage)
+	}
+	c := coverage[0]
+	if c.Projectile != 5 || c.Team != CT || c.BlockedSightlines != 3 || c.Pairs != 1 || c.Value != 1.5 {
+		t.Error("SmokeCoverage failed, got ", c)
+	}
+	if len(c.KillsThrough) != 1 || c.KillsThrough[0].FrameNumber != 11 {
+		t.Error("SmokeCoverage failed on the kills through the smoke, got ", c.KillsThrough)
+	}
+	if c := coverage[1]; c.Team != T || c.BlockedSightlines != 0 || c.Value != 0 || len(c.KillsThrough) != 0 {
+		t.Error("SmokeCoverage failed on a smoke that blocked nothing, got ", c)
+	}
+}
@@ -0,0 +1,110 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/zones"
+	"github.com/golang/geo/r3"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"math"
+)
+
+// PlayerPlace is where a player was.
+type PlayerPlace struct {
+	SteamID  int64
+	Position r3.Vector
+	// Callout of the place, "" if no zone contains the position or there are no zones of the map
+	Callout string
+}
+
+// Snapshot is where the alive players of a team were some seconds into a round.
+type Snapshot struct {
+	// At is the seconds after the freeze time ended
+	At      float64
+	Players []PlayerPlace
+}
+
+// RoundSnapshots is where a team was at given times of a round.
+type RoundSnapshots struct {
+	Round Round
+	Team  common.Team
+	// EquipmentValue is the summed equipment value of the team in the first game state of the round saved
+	// when its freeze time ended or later, -1 if there is none
+	EquipmentValue int
+	Snapshots      []Snapshot
+}
+
+// TeamSnapshots returns where the alive players of a team were the given seconds after the freeze time ended,
+// in every round whose freeze time ended. Snapshots after the round ended have no players. Positions stored
+// without their callout are named with the zones of reg, which may be nil.
+func (m *Match) TeamSnapshots(team common.Team, times []float64, reg zones.Registry) ([]RoundSnapshots, error) {
+	var res []RoundSnapshots
+	index := make(map[int]int)
+	for _, r := range m.Rounds {
+		if r.FreezetimeEndFrame >= 0 {
+			index[r.Number] = len(res)
+			res = append(res, RoundSnapshots{Round: r, Team: team, EquipmentValue: -1})
+		}
+	}
+	if len(res) == 0 {
+		return res, nil
+	}
+
+	it, err := m.GameStates()
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	for it.Next() {
+		GSI := it.Value()
+		i, ok := index[GSI.RoundNumber]
+		if !ok || res[i].EquipmentValue >= 0 || GSI.FrameNumber < res[i].Round.FreezetimeEndFrame {
+			continue
+		}
+		res[i].EquipmentValue = 0
+		for _, p := range GSI.Players {
+			if p.Team == team {
+				res[i].EquipmentValue += p.CurrentEquipmentValue
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	rate, err := m.SampleRate()
+	if err != nil {
+		return nil, err
+	}
+	teams, err := m.Teams()
+	if err != nil {
+		return nil, err
+	}
+	seeker, err := m.Seeker()
+	if err != nil {
+		return nil, err
+	}
+	for i := range res {
+		r := res[i].Round
+		for _, at := range times {
+			S := Snapshot{At: at}
+			frame := r.FreezetimeEndFrame + int(math.Round(at*rate))
+			if r.EndFrame < 0 || frame <= r.EndFrame {
+				positions, err := seeker.PositionsAt(frame)
+				if err != nil {
+					return nil, err
+				}
+				for _, PMI := range positions.PlayersPositions {
+					if teams.Team(r.Number, PMI.SteamID) != team {
+						continue
+					}
+					callout := PMI.Callout
+					if callout == "" {
+						callout, _ = m.Callout(PMI.Position, reg)
+					}
+					S.Players = append(S.Players, PlayerPlace{PMI.SteamID, m.Position(PMI.Position), callout})
+				}
+			}
+			res[i].Snapshots = append(res[i].Snapshots, S)
+		}
+	}
+	return res, nil
+}
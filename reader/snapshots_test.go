@@ -0,0 +1,48 @@
+package reader
+
+import (
+	"csgo-parser-mongodb/app"
+	"github.com/golang/geo/r3"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"reflect"
+	"testing"
+)
+
+func TestTeamSnapshots(t *testing.T) {
+	T, CT := common.TeamTerrorists, common.TeamCounterTerrorists
+	state := func(frame int, values ...int) app.GameStateInfo {
+		return app.GameStateInfo{FrameNumber: frame, RoundNumber: 1, Players: []app.PlayerStateInfo{
+			{SteamID: 1, Team: T, CurrentEquipmentValue: values[0]},
+			{SteamID: 2, Team: CT, CurrentEquipmentValue: values[1]},
+			{SteamID: 3, Team: T, CurrentEquipmentValue: values[2]},
+		}}
+	}
+	m := &Match{
+		Header: Header{SampleRate: 2},
+		src: MemorySource{
+			// bought when the freeze time ended
+			app.ClGameState: {state(8, 800, 800, 800), state(12, 4000, 5000, 2500), state(16, 0, 0, 0)},
+			app.ClPositions: {app.FramePositions{FrameNumber: 14, PlayersPositions: []app.PlayerMovementInfo{
+				{SteamID: 1, Position: app.FixedVector3{X: 100}, Callout: "Banana"},
+				{SteamID: 2, Position: app.FixedVector3{X: 200}, Callout: "B Site"},
+				{SteamID: 3, Position: app.FixedVector3{X: 300}},
+			}}},
+		},
+		Rounds: []Round{{Number: 1, FreezetimeEndFrame: 10, EndFrame: 20}, {Number: 2, FreezetimeEndFrame: -1, EndFrame: -1}},
+	}
+	res, err := m.TeamSnapshots(T, []float64{2, 10}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Round.Number != 1 || res[0].Team != T || res[0].EquipmentValue != 6500 {
+		t.Fatal("TeamSnapshots failed, got ", res)
+	}
+	expected := []Snapshot{
+		{At: 2, Players: []PlayerPlace{{1, r3.Vector{X: 100}, "Banana"}, {3, r3.Vector{X: 300}, ""}}},
+		// the round ended
+		{At: 10},
+	}
+	if !reflect.DeepEqual(res[0].Snapshots, expected) {
+		t.Error("TeamSnapshots failed, got ", res[0].Snapshots, " instead of ", expected)
+	}
+}
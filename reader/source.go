@@ -0,0 +1,118 @@
+// Package reader loads matches stored by the parser back into typed structs.
+package reader
+
+import (
+	"context"
+	"csgo-parser-mongodb/app"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Cursor iterates over the documents of a collection in the order they were stored.
+type Cursor interface {
+	Next() bool
+	Decode(v interface{}) error
+	Err() error
+	Close() error
+}
+
+// Source gives access to the collections of a single parsed match.
+// MongoSource reads a database written by the parser; other sinks only need to provide the same documents.
+type Source interface {
+	// Header decodes the match's header document into v.
+	Header(v interface{}) error
+	// Find returns a cursor over the documents of a collection.
+	Find(cl app.ClIndex) (Cursor, error)
+}
+
+// MongoSource reads a match from the database the parser wrote it to.
+type MongoSource struct {
+	db              *mongo.Database
+	collectionNames map[app.ClIndex]string
+}
+
+// NewMongoSource returns a Source reading the given database.
+// If collectionNames is nil, app.DefaultCollectionNames are used.
+func NewMongoSource(db *mongo.Database, collectionNames map[app.ClIndex]string) *MongoSource {
+	if collectionNames == nil {
+		collectionNames = app.DefaultCollectionNames
+	}
+	return &MongoSource{db, collectionNames}
+}
+
+func (s *MongoSource) Header(v interface{}) error {
+	return s.db.Collection(s.collectionNames[app.ClHeader]).FindOne(context.TODO(), bson.M{}).Decode(v)
+}
+
+func (s *MongoSource) Find(cl app.ClIndex) (Cursor, error) {
+	cursor, err := s.db.Collection(s.collectionNames[cl]).Find(context.TODO(), bson.M{},
+		options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	return mongoCursor{cursor}, nil
+}
+
+// Database returns the database the source reads from.
+func (s *MongoSource) Database() *mongo.Database {
+	return s.db
+}
+
+type mongoCursor struct {
+	*mongo.Cursor
+}
+
+func (c mongoCursor) Next() bool {
+	return c.Cursor.Next(context.TODO())
+}
+
+func (c mongoCursor) Close() error {
+	return c.Cursor.Close(context.TODO())
+}
+
+// MemorySource is a match kept in memory, e.g. to test code reading matches: the documents of every collection
+// in the order they were stored. The header is the first ClHeader document, the zero Header if there is none.
+type MemorySource map[app.ClIndex][]interface{}
+
+func (s MemorySource) Header(v interface{}) error {
+	if len(s[app.ClHeader]) == 0 {
+		return nil
+	}
+	return decodeDocument(s[app.ClHeader][0], v)
+}
+
+func (s MemorySource) Find(cl app.ClIndex) (Cursor, error) {
+	return &memoryCursor{docs: s[cl], i: -1}, nil
+}
+
+type memoryCursor struct {
+	docs []interface{}
+	i    int
+}
+
+func (c *memoryCursor) Next() bool {
+	c.i++
+	return c.i < len(c.docs)
+}
+
+// Decode round-trips the document through BSON, so it is read as it would be from MongoDB.
+func (c *memoryCursor) Decode(v interface{}) error {
+	return decodeDocument(c.docs[c.i], v)
+}
+
+func (c *memoryCursor) Err() error {
+	return nil
+}
+
+func (c *memoryCursor) Close() error {
+	return nil
+}
+
+func decodeDocument(doc, v interface{}) error {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, v)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"csgo-parser-mongodb/minimap"
+	"csgo-parser-mongodb/overview"
+	"csgo-parser-mongodb/reader"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runReplay animates a round of a parsed match top-down over its map's radar, to an animated GIF or SVG.
+func runReplay(args []string) {
+	var mongoUri, dbName, out, radarImage, overviewsDir string
+	var round, size int
+	var fps float64
+
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.StringVar(&mongoUri, "uri", "localhost:27017", "MongoDB connection URI.")
+	flags.StringVar(&dbName, "dbname", "test", "Database name of the parsed match.")
+	flags.IntVar(&round, "round", 1, "Number of the round to animate.")
+	flags.StringVar(&out, "out", "replay.gif", "File to animate to, an animated GIF if it ends in .gif or an SVG animated with SMIL if it ends in .svg.")
+	flags.StringVar(&radarImage, "radarimage", "", "PNG or JPEG radar image of the map to draw over, e.g. converted from the game's .dds. Draws over a plain background if empty.")
+	flags.StringVar(&overviewsDir, "overviews", "", "Folder of the maps' overview files, for matches stored without the overview of their map.")
+	flags.IntVar(&size, "size", 512, "Width and height of the animation in pixels.")
+	flags.Float64Var(&fps, "fps", 8, "Frames per second of game time to animate. Can't be more than the match's sample rate.")
+	checkError(flags.Parse(args))
+
+	format := strings.ToLower(filepath.Ext(out))
+	switch {
+	case format != ".gif" && format != ".svg":
+		fmt.Printf("Unknown format of %q, -out must end in .gif or .svg.\n", out)
+		os.Exit(2)
+	case size <= 0 || fps <= 0:
+		fmt.Println("-size and -fps must be positive.")
+		os.Exit(2)
+	}
+
+	var overviews overview.Registry
+	var err error
+	if overviewsDir != "" {
+		if overviews, err = overview.LoadDir(overviewsDir); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+	var background image.Image
+	if radarImage != "" {
+		f, err := os.Open(radarImage)
+		checkError(err)
+		background, _, err = image.Decode(f)
+		f.Close()
+		checkError(err)
+	}
+
+	client := connect_to_mongo("mongodb://"+mongoUri, 2*time.Second)
+	defer close_connection_to_mongo(client)
+
+	match, err := reader.Load(reader.NewMongoSource(client.Database(dbName), clNames))
+	checkError(err)
+	OV, ok := match.Overview(overviews)
+	if !ok {
+		fmt.Printf("No overview of %s, see -overviews.\n", match.Header.MapName)
+		os.Exit(1)
+	}
+	scene, err := minimap.Build(match, round, OV, size, fps)
+	checkError(err)
+	scene.Background = background
+
+	f, err := os.Create(out)
+	checkError(err)
+	defer f.Close()
+	if format == ".gif" {
+		checkError(scene.EncodeGIF(f))
+	} else {
+		checkError(scene.EncodeSVG(f))
+	}
+	fmt.Printf("Animated round %d of %s in %d frames to %s.\n", round, dbName, len(scene.Frames), out)
+}
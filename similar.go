@@ -0,0 +1,97 @@
+package main
+
+import (
+	"csgo-parser-mongodb/reader"
+	"csgo-parser-mongodb/similarity"
+	"flag"
+	"fmt"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"os"
+	"time"
+)
+
+// runSimilar prints the rounds of every match registered in meta_info on the same map most alike a round of a match,
+// as played by one side. The vectors of the rounds are kept in an index file, so only matches parsed since
+// the last search are read.
+func runSimilar(args []string) {
+	var mongoUri, dbName, side, indexPath string
+	var round, k int
+	var rebuild bool
+	opts := similarity.DefaultOptions
+
+	flags := flag.NewFlagSet("similar", flag.ExitOnError)
+	flags.StringVar(&mongoUri, "uri", "localhost:27017", "MongoDB connection URI.")
+	flags.StringVar(&dbName, "dbname", "test", "Database name of the match of the round.")
+	flags.IntVar(&round, "round", 0, "Number of the round to find alike ones of.")
+	flags.StringVar(&side, "side", "t", "Side whose play is compared, t or ct.")
+	flags.IntVar(&k, "k", 10, "How many rounds to print.")
+	flags.StringVar(&indexPath, "index", "similar.json", "File the vectors of the rounds are indexed in.")
+	flags.BoolVar(&rebuild, "rebuild", false, "Reads every match again instead of the ones missing from the index.")
+	flags.Float64Var(&opts.Step, "step", opts.Step, "Seconds between the times the positions of the side are compared after the freeze time ended.")
+	flags.IntVar(&opts.Steps, "steps", opts.Steps, "How many times the positions are compared. Utility thrown up to the last one counts.")
+	checkError(flags.Parse(args))
+
+	team, err := parseSide(side)
+	if err != nil || team == common.TeamUnassigned {
+		fmt.Println("-side must be t or ct.")
+		os.Exit(2)
+	}
+	if round <= 0 || k <= 0 {
+		fmt.Println("-round and -k must be positive.")
+		os.Exit(2)
+	}
+	if opts.Step <= 0 || opts.Steps <= 0 {
+		fmt.Println("-step and -steps must be positive.")
+		os.Exit(2)
+	}
+
+	index, err := similarity.LoadIndex(indexPath, opts)
+	checkError(err)
+	if rebuild || index.Options != opts {
+		index = similarity.NewIndex(opts)
+	}
+
+	client := connect_to_mongo("mongodb://"+mongoUri, 2*time.Second)
+	defer close_connection_to_mongo(client)
+
+	added := 0
+	for _, name := range append([]string{dbName}, databaseNames(client, dbName, true)...) {
+		if index.Has(name) {
+			continue
+		}
+		match, err := reader.Load(reader.NewMongoSource(client.Database(name), clNames))
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			continue
+		}
+		rounds, err := similarity.Features(name, match, common.TeamTerrorists, opts)
+		if err == nil {
+			var CTRounds []similarity.Round
+			CTRounds, err = similarity.Features(name, match, common.TeamCounterTerrorists, opts)
+			rounds = append(rounds, CTRounds...)
+		}
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			continue
+		}
+		index.Add(name, match.Header.MapName, rounds)
+		added++
+	}
+	if added > 0 {
+		checkError(index.Save(indexPath))
+		fmt.Printf("Indexed %d matches, %d rounds in %s.\n", added, len(index.Rounds), indexPath)
+	}
+
+	q, ok := index.Round(dbName, round, team)
+	if !ok {
+		fmt.Printf("%s has no round %d whose freeze time ended.\n", dbName, round)
+		os.Exit(1)
+	}
+	for _, hit := range index.Search(q, k) {
+		result := "lost"
+		if hit.Won {
+			result = "won"
+		}
+		fmt.Printf("%.3f %s round %d (%s)\n", hit.Distance, hit.Match, hit.Number, result)
+	}
+}
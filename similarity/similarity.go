@@ -0,0 +1,229 @@
+// Package similarity finds the rounds of matches on the same map most alike a given one, e.g. every time a team ran
+// the same execute. A team's round is turned into a feature vector of where its players were at fixed times after
+// the freeze time ended, the utility it threw and what it bought, and vectors are compared by Euclidean distance
+// in an index kept in a local file.
+package similarity
+
+import (
+	"csgo-parser-mongodb/reader"
+	"encoding/json"
+	"fmt"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Options are how rounds are turned into vectors. Vectors built with other options can't be compared.
+type Options struct {
+	// Step is the seconds between the times the positions are looked at after the freeze time ended,
+	// Steps how many times they are. Utility thrown up to the last time counts.
+	Step  float64
+	Steps int
+}
+
+// DefaultOptions look at the first minute after the freeze time ended every five seconds.
+var DefaultOptions = Options{Step: 5, Steps: 12}
+
+// scales the features are divided by, so that positions, utility and equipment weigh about the same
+const (
+	// positionScale is the world units a feature of a position is 1 in
+	positionScale = 1000
+	// teamSize is the players a team has, for the share alive and the utility thrown
+	teamSize = 5
+	// equipmentScale is the equipment value a team's feature of it is 1 in, about a full buy
+	equipmentScale = 25000
+)
+
+// grenades are the grenades whose throws are features, molotovs standing for incendiaries too.
+var grenades = []common.EquipmentElement{common.EqSmoke, common.EqFlash, common.EqHE, common.EqMolotov}
+
+// Round is the vector of a team's round of a match.
+type Round struct {
+	// Match is the database name of the match
+	Match  string
+	Map    string
+	Number int
+	Team   common.Team
+	Won    bool
+	Vector []float64
+}
+
+// Features returns the vectors of a team's rounds in a match, the rounds whose freeze time ended.
+// Per time looked at, the vector has the share of the team alive and the mean and standard deviations
+// of its positions along the axes of the map, kept from the time before once every player died or the round ended.
+// Then for every grenade how many the team threw and the mean of where they detonated, and its equipment value.
+func Features(name string, m *reader.Match, team common.Team, opts Options) ([]Round, error) {
+	times := make([]float64, opts.Steps)
+	for i := range times {
+		times[i] = float64(i+1) * opts.Step
+	}
+	snapshots, err := m.TeamSnapshots(team, times, nil)
+	if err != nil {
+		return nil, err
+	}
+	throws, err := m.GrenadeThrows(nil)
+	if err != nil {
+		return nil, err
+	}
+	// without the tick rate the whole round's utility counts
+	window := math.MaxInt32
+	if m.Header.PlaybackTime > 0 {
+		window = int(float64(opts.Steps) * opts.Step * float64(m.Header.PlaybackTicks) / m.Header.PlaybackTime.Seconds())
+	}
+
+	var res []Round
+	for _, RS := range snapshots {
+		R := Round{Match: name, Map: m.Header.MapName, Number: RS.Round.Number, Team: team, Won: RS.Round.Winner == team}
+		var x, y float64
+		for _, S := range RS.Snapshots {
+			var sx, sy float64
+			if n := float64(len(S.Players)); n > 0 {
+				x, y = 0, 0
+				for _, p := range S.Players {
+					x += p.Position.X / n
+					y += p.Position.Y / n
+				}
+				for _, p := range S.Players {
+					sx += (p.Position.X - x) * (p.Position.X - x) / n
+					sy += (p.Position.Y - y) * (p.Position.Y - y) / n
+				}
+			}
+			R.Vector = append(R.Vector, float64(len(S.Players))/teamSize,
+				x/positionScale, y/positionScale, math.Sqrt(sx)/positionScale, math.Sqrt(sy)/positionScale)
+		}
+		for _, g := range grenades {
+			var count, dx, dy float64
+			for _, t := range throws {
+				weapon := t.Weapon
+				if weapon == common.EqIncendiary {
+					weapon = common.EqMolotov
+				}
+				if t.Round != RS.Round.Number || t.Team != team || weapon != g ||
+					t.Tick < RS.Round.FreezetimeEndTick || t.Tick > RS.Round.FreezetimeEndTick+window {
+					continue
+				}
+				count++
+				dx += t.Detonation.X
+				dy += t.Detonation.Y
+			}
+			if count > 0 {
+				dx, dy = dx/count, dy/count
+			}
+			R.Vector = append(R.Vector, count/teamSize, dx/positionScale, dy/positionScale)
+		}
+		equipment := 0.0
+		if RS.EquipmentValue > 0 {
+			equipment = float64(RS.EquipmentValue) / equipmentScale
+		}
+		R.Vector = append(R.Vector, equipment)
+		res = append(res, R)
+	}
+	return res, nil
+}
+
+// Distance returns the Euclidean distance of two vectors, +Inf if they have different lengths.
+func Distance(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return math.Inf(1)
+	}
+	var sum float64
+	for i := range a {
+		sum += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return math.Sqrt(sum)
+}
+
+// Index holds the vectors of the rounds of matches to search them.
+type Index struct {
+	Options Options
+	// Matches are the names of the maps of the matches indexed by their database names,
+	// so matches without rounds aren't read again
+	Matches map[string]string
+	Rounds  []Round
+}
+
+// NewIndex returns an empty index of vectors built with the given options.
+func NewIndex(opts Options) *Index {
+	return &Index{Options: opts, Matches: make(map[string]string)}
+}
+
+// LoadIndex reads an index saved to a file, an empty one if there is no such file.
+func LoadIndex(path string, opts Options) (*Index, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return NewIndex(opts), nil
+	} else if err != nil {
+		return nil, err
+	}
+	var ix Index
+	if err := json.Unmarshal(data, &ix); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if ix.Matches == nil {
+		ix.Matches = make(map[string]string)
+	}
+	return &ix, nil
+}
+
+// Save writes the index to a file.
+func (ix *Index) Save(path string) error {
+	data, err := json.Marshal(ix)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Has tells whether a match was indexed.
+func (ix *Index) Has(match string) bool {
+	_, ok := ix.Matches[match]
+	return ok
+}
+
+// Add indexes the rounds of a match of a map, replacing the ones indexed before.
+func (ix *Index) Add(match, mapName string, rounds []Round) {
+	kept := ix.Rounds[:0]
+	for _, R := range ix.Rounds {
+		if R.Match != match {
+			kept = append(kept, R)
+		}
+	}
+	ix.Rounds = append(kept, rounds...)
+	ix.Matches[match] = mapName
+}
+
+// Round returns the indexed round of a team in a match, false if there is none.
+func (ix *Index) Round(match string, number int, team common.Team) (Round, bool) {
+	for _, R := range ix.Rounds {
+		if R.Match == match && R.Number == number && R.Team == team {
+			return R, true
+		}
+	}
+	return Round{}, false
+}
+
+// Hit is a round found alike the one searched for.
+type Hit struct {
+	Round
+	Distance float64
+}
+
+// Search returns the k rounds of the same team side on the same map nearest to a round, the nearest first,
+// leaving out the round itself.
+func (ix *Index) Search(q Round, k int) []Hit {
+	var res []Hit
+	for _, R := range ix.Rounds {
+		if R.Team != q.Team || !strings.EqualFold(R.Map, q.Map) || R.Match == q.Match && R.Number == q.Number {
+			continue
+		}
+		res = append(res, Hit{R, Distance(q.Vector, R.Vector)})
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Distance < res[j].Distance })
+	if len(res) > k {
+		res = res[:k]
+	}
+	return res
+}
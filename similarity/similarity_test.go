@@ -0,0 +1,147 @@
+package similarity
+
+import (
+	"csgo-parser-mongodb/app"
+	"csgo-parser-mongodb/reader"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFeatures(t *testing.T) {
+	T, CT := common.TeamTerrorists, common.TeamCounterTerrorists
+	event := func(frame, tick int, evType app.EvType, data map[string]interface{}) app.EventInfo {
+		return app.EventInfo{FrameNumber: frame, Tick: tick, RoundNumber: 1, EventType: evType, Data: data}
+	}
+	throw := func(frame, tick int, projectile int64, weapon common.EquipmentElement) []interface{} {
+		return []interface{}{
+			event(frame, tick, app.GrenadeProjectileThrow, map[string]interface{}{
+				"Projectile": projectile, "Weapon": weapon, "Thrower": int64(1),
+				"ThrowerMovement": app.PlayerMovementInfo{SteamID: 1},
+			}),
+			event(frame+1, tick+64, app.GrenadeProjectileDestroy, map[string]interface{}{
+				"Projectile": projectile, "Weapon": weapon, "Thrower": int64(1),
+				"Position": app.FixedVector3{X: 1000, Y: 2000},
+			}),
+		}
+	}
+	events := []interface{}{
+		event(0, 0, app.RoundStart, map[string]interface{}{}),
+		event(10, 640, app.RoundFreezetimeEnd, map[string]interface{}{}),
+	}
+	events = append(events, throw(10, 700, 5, common.EqSmoke)...)
+	// thrown after the last time looked at
+	events = append(events, throw(15, 1000, 6, common.EqFlash)...)
+	events = append(events, event(20, 1280, app.RoundEnd, map[string]interface{}{"Winner": T}))
+	positions := func(frame int, players ...app.PlayerMovementInfo) app.FramePositions {
+		return app.FramePositions{FrameNumber: frame, PlayersPositions: players}
+	}
+	src := reader.MemorySource{
+		app.ClHeader: {reader.Header{
+			MapName: "de_inferno", SchemaVersion: app.SchemaVersion, SampleRate: 1,
+			PlaybackTime: 100 * time.Second, PlaybackTicks: 6400,
+		}},
+		app.ClEvents: events,
+		app.ClGameState: {app.GameStateInfo{FrameNumber: 10, RoundNumber: 1, Players: []app.PlayerStateInfo{
+			{SteamID: 1, Team: T, CurrentEquipmentValue: 5000},
+			{SteamID: 2, Team: CT, CurrentEquipmentValue: 2000},
+			{SteamID: 3, Team: T, CurrentEquipmentValue: 5000},
+		}}},
+		app.ClPositions: {
+			positions(11, app.PlayerMovementInfo{SteamID: 1}, app.PlayerMovementInfo{SteamID: 2, Position: app.FixedVector3{X: 500}},
+				app.PlayerMovementInfo{SteamID: 3, Position: app.FixedVector3{X: 2000}}),
+			// 3 died
+			positions(12, app.PlayerMovementInfo{SteamID: 1}, app.PlayerMovementInfo{SteamID: 2, Position: app.FixedVector3{X: 500}}),
+		},
+	}
+	m, err := reader.Load(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Features("match1", m, T, Options{Step: 1, Steps: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Round{{Match: "match1", Map: "de_inferno", Number: 1, Team: T, Won: true, Vector: []float64{
+		0.4, 1, 0, 1, 0,
+		0.2, 0, 0, 0, 0,
+		// smoke, flash, HE, molotov
+		0.2, 1, 2,
+		0, 0, 0,
+		0, 0, 0,
+		0, 0, 0,
+		0.4,
+	}}}
+	if !reflect.DeepEqual(res, expected) {
+		t.Error("Features failed, got ", res, " instead of ", expected)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	if d := Distance([]float64{0, 3}, []float64{4, 0}); d != 5 {
+		t.Error("Distance failed, got ", d, " instead of 5")
+	}
+	if d := Distance([]float64{0}, []float64{0, 0}); !math.IsInf(d, 1) {
+		t.Error("Distance failed on vectors of other lengths, got ", d)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	T, CT := common.TeamTerrorists, common.TeamCounterTerrorists
+	round := func(match, mapName string, number int, team common.Team, x float64) Round {
+		return Round{Match: match, Map: mapName, Number: number, Team: team, Vector: []float64{x, 0}}
+	}
+	ix := NewIndex(DefaultOptions)
+	ix.Add("match1", "de_inferno", []Round{round("match1", "de_inferno", 1, T, 0), round("match1", "de_inferno", 2, T, 5)})
+	ix.Add("match2", "de_inferno", []Round{
+		round("match2", "de_inferno", 1, T, 1),
+		round("match2", "de_inferno", 2, T, 3),
+		round("match2", "de_inferno", 3, CT, 0),
+	})
+	ix.Add("match3", "de_nuke", []Round{round("match3", "de_nuke", 1, T, 0)})
+	// read again, e.g. after a migration
+	ix.Add("match1", "de_inferno", []Round{round("match1", "de_inferno", 1, T, 0), round("match1", "de_inferno", 2, T, 4)})
+
+	if len(ix.Rounds) != 6 || !ix.Has("match3") || ix.Has("match4") {
+		t.Error("Add failed, got ", ix.Rounds, ix.Matches)
+	}
+	q, ok := ix.Round("match1", 1, T)
+	if !ok {
+		t.Fatal("Round failed to find an indexed round")
+	}
+	if _, ok := ix.Round("match1", 1, CT); ok {
+		t.Error("Round failed, found a round of the other side")
+	}
+	var res []int
+	for _, hit := range ix.Search(q, 2) {
+		res = append(res, int(hit.Distance))
+	}
+	if expected := []int{1, 3}; !reflect.DeepEqual(res, expected) {
+		t.Error("Search failed, got the distances ", res, " instead of ", expected)
+	}
+	if hits := ix.Search(q, 10); len(hits) != 3 || hits[2].Match != "match1" || hits[2].Number != 2 {
+		t.Error("Search failed to leave out the round itself, other sides and maps, got ", hits)
+	}
+
+	dir, err := ioutil.TempDir("", "similarity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "similar.json")
+	if empty, err := LoadIndex(path, DefaultOptions); err != nil || len(empty.Rounds) != 0 || empty.Options != DefaultOptions {
+		t.Error("LoadIndex failed without a file, got ", empty, err)
+	}
+	if err := ix.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadIndex(path, Options{})
+	if err != nil || !reflect.DeepEqual(loaded, ix) {
+		t.Error("LoadIndex failed to read a saved index back, got ", loaded, err)
+	}
+}
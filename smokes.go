@@ -0,0 +1,43 @@
+package main
+
+import (
+	"csgo-parser-mongodb/reader"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runSmokes prints every smoke of a match with what it hid as JSON lines, its Value being the smoke value:
+// the seconds of sightlines between enemies it blocked, summed over the pairs of players.
+func runSmokes(args []string) {
+	var mongoUri, dbName string
+	var round int
+
+	flags := flag.NewFlagSet("smokes", flag.ExitOnError)
+	flags.StringVar(&mongoUri, "uri", "localhost:27017", "MongoDB connection URI.")
+	flags.StringVar(&dbName, "dbname", "test", "Database name of the parsed match.")
+	flags.IntVar(&round, "round", 0, "Prints only the smokes of this round. 0 prints every round's.")
+	checkError(flags.Parse(args))
+
+	if round < 0 {
+		fmt.Println("-round must not be negative.")
+		os.Exit(2)
+	}
+
+	client := connect_to_mongo("mongodb://"+mongoUri, 2*time.Second)
+	defer close_connection_to_mongo(client)
+
+	match, err := reader.Load(reader.NewMongoSource(client.Database(dbName), clNames))
+	checkError(err)
+	coverage, err := match.SmokeCoverage()
+	checkError(err)
+
+	out := json.NewEncoder(os.Stdout)
+	for _, c := range coverage {
+		if round == 0 || c.RoundNumber == round {
+			checkError(out.Encode(c))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"csgo-parser-mongodb/app"
+	"csgo-parser-mongodb/reader"
+	"csgo-parser-mongodb/strategy"
+	"csgo-parser-mongodb/zones"
+	"flag"
+	"fmt"
+	"github.com/markus-wa/demoinfocs-golang/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"os"
+	"strings"
+	"time"
+)
+
+// classifiedRound is a round of one of the matches the strategies command classifies.
+type classifiedRound struct {
+	db string
+	reader.RoundSnapshots
+	label string
+}
+
+// runStrategies labels the strategy the terrorists played in every round of a match, or every match of its map
+// registered in meta_info, by the rules of a JSON file (see strategy.Config) and stores it on the round summaries.
+// Rounds no rule matches can be clustered into groups of alike rounds, which are labeled with their number and places.
+func runStrategies(args []string) {
+	var mongoUri, dbName, rulesPath, zonesDir string
+	var all bool
+	var maxDistance float64
+
+	flags := flag.NewFlagSet("strategies", flag.ExitOnError)
+	flags.StringVar(&mongoUri, "uri", "localhost:27017", "MongoDB connection URI.")
+	flags.StringVar(&dbName, "dbname", "test", "Database name of the parsed match.")
+	flags.BoolVar(&all, "all", false, "Classifies every database registered in meta_info of the same map as the first one together, skipping the others.")
+	flags.StringVar(&rulesPath, "rules", "", "JSON file of the times to look at the terrorists and the rules labeling the rounds, tried in order.")
+	flags.StringVar(&zonesDir, "zones", "", "Folder of the maps' zones in JSON or GeoJSON to name the places of the players, for matches stored without callouts.")
+	flags.Float64Var(&maxDistance, "cluster", 0, "Clusters the rounds no rule matches into groups whose players were spread this alike, from 0 for the same places to 1 for none in common. 0 leaves them unlabeled.")
+	checkError(flags.Parse(args))
+
+	if rulesPath == "" {
+		fmt.Println("-rules is required.")
+		os.Exit(2)
+	}
+	config, err := strategy.Load(rulesPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if maxDistance < 0 || maxDistance > 1 {
+		fmt.Println("-cluster must be between 0 and 1.")
+		os.Exit(2)
+	}
+	var zoneRegistry zones.Registry
+	if zonesDir != "" {
+		if zoneRegistry, err = zones.LoadDir(zonesDir); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+
+	client := connect_to_mongo("mongodb://"+mongoUri, 2*time.Second)
+	defer close_connection_to_mongo(client)
+
+	var rounds []classifiedRound
+	mapName := ""
+	for _, name := range databaseNames(client, dbName, all) {
+		match, err := reader.Load(reader.NewMongoSource(client.Database(name), clNames))
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			continue
+		}
+		if mapName == "" {
+			mapName = match.Header.MapName
+		} else if !strings.EqualFold(match.Header.MapName, mapName) {
+			fmt.Printf("%s: skipped, its map %s isn't %s.\n", name, match.Header.MapName, mapName)
+			continue
+		}
+		snapshots, err := match.TeamSnapshots(common.TeamTerrorists, config.Times, zoneRegistry)
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			continue
+		}
+		for _, r := range snapshots {
+			label, _ := config.Label(mapName, r)
+			rounds = append(rounds, classifiedRound{name, r, label})
+		}
+	}
+
+	if maxDistance > 0 {
+		var unlabeled []reader.RoundSnapshots
+		var indexes []int
+		for i, r := range rounds {
+			if r.label == "" {
+				unlabeled = append(unlabeled, r.RoundSnapshots)
+				indexes = append(indexes, i)
+			}
+		}
+		for g, group := range strategy.Cluster(unlabeled, maxDistance) {
+			label := fmt.Sprintf("group %d", g+1)
+			if len(group.Places) > 0 {
+				label += ": " + strings.Join(group.Places, ", ")
+			}
+			for _, i := range group.Rounds {
+				rounds[indexes[i]].label = label
+			}
+		}
+	}
+
+	counts := make(map[string]int)
+	missing := 0
+	for _, r := range rounds {
+		res, err := client.Database(r.db).Collection(clNames[app.ClRoundSummaries]).UpdateOne(context.TODO(),
+			bson.M{"RoundNumber": r.Round.Number},
+			bson.M{"$set": bson.M{"Strategy": r.label}})
+		checkError(err)
+		if res.MatchedCount == 0 {
+			fmt.Printf("%s round %d: %s, not stored as the round has no summary\n", r.db, r.Round.Number, r.label)
+			missing++
+		} else {
+			fmt.Printf("%s round %d: %s\n", r.db, r.Round.Number, r.label)
+		}
+		counts[r.label]++
+	}
+	fmt.Printf("Classified %d rounds of %s, %d unlabeled.\n", len(rounds), mapName, counts[""])
+	if missing > 0 {
+		fmt.Printf("%d rounds have no summary to store their strategy on, parse their matches again.\n", missing)
+	}
+}
@@ -0,0 +1,244 @@
+// Package strategy labels the strategies a team played in rounds, e.g. "rush A" or "mid control", by rules on where
+// its players were some seconds after the freeze time ended, and groups the rounds no rule labels by where they were.
+package strategy
+
+import (
+	"csgo-parser-mongodb/reader"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Condition is where the alive players of the team have to be at a time of the round.
+type Condition struct {
+	// At is the seconds after the freeze time ended, one of the Config's Times
+	At float64
+	// Callouts are the places the players are counted in, every place if empty
+	Callouts []string
+	// Min and Max bound how many players are in the places, Max only if given
+	Min int
+	Max *int
+	// MinPlaces is how many different places the counted players have to be spread over at least
+	MinPlaces int
+}
+
+// Rule labels the rounds that match all its conditions.
+type Rule struct {
+	Label string
+	// Map restricts the rule to the rounds of a map, e.g. de_inferno, compared without case. Empty matches every map.
+	Map string
+	// MinEquipment and MaxEquipment bound the team's summed equipment value when the freeze time ended, if given
+	MinEquipment, MaxEquipment *int
+	When                       []Condition
+}
+
+// Config is the rules rounds are labeled by, read from JSON such as
+//
+//	{
+//		"Times": [20, 40],
+//		"Rules": [
+//			{"Label": "eco stack", "MaxEquipment": 8000, "When": [{"At": 20, "Callouts": ["Banana"], "Min": 4}]},
+//			{"Label": "rush B", "When": [{"At": 20, "Callouts": ["B Site"], "Min": 3}]},
+//			{"Label": "split A", "When": [{"At": 40, "Callouts": ["Apartments"], "Min": 2}, {"At": 40, "Callouts": ["Middle"], "Min": 2}]},
+//			{"Label": "default", "When": [{"At": 20, "MinPlaces": 4}]}
+//		]
+//	}
+//
+// Times are the seconds after the freeze time ended the team is looked at, the rules are tried in order.
+type Config struct {
+	Times []float64
+	Rules []Rule
+}
+
+// Parse reads rules from JSON, see Config. Fields it doesn't know are errors, so typos don't go unnoticed.
+func Parse(r io.Reader) (Config, error) {
+	var c Config
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return c, err
+	}
+	if len(c.Times) == 0 {
+		return c, fmt.Errorf("rules have no times to look at the rounds")
+	}
+	times := make(map[float64]bool, len(c.Times))
+	for _, t := range c.Times {
+		if t < 0 {
+			return c, fmt.Errorf("time %v is before the freeze time ended", t)
+		}
+		times[t] = true
+	}
+	for i, rule := range c.Rules {
+		if rule.Label == "" {
+			return c, fmt.Errorf("rule %d has no label", i+1)
+		}
+		for _, cond := range rule.When {
+			if !times[cond.At] {
+				return c, fmt.Errorf("rule %q looks at %v seconds, which aren't among the times %v", rule.Label, cond.At, c.Times)
+			}
+		}
+	}
+	return c, nil
+}
+
+// Load reads rules from a JSON file, see Parse.
+func Load(path string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+	defer f.Close()
+	c, err := Parse(f)
+	if err != nil {
+		return c, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Label returns the label of the first rule a round of a map matches, false if none does.
+// The round has to be looked at the Config's Times, see reader.Match.TeamSnapshots.
+func (c Config) Label(mapName string, r reader.RoundSnapshots) (string, bool) {
+	for _, rule := range c.Rules {
+		if rule.matches(mapName, r) {
+			return rule.Label, true
+		}
+	}
+	return "", false
+}
+
+func (rule Rule) matches(mapName string, r reader.RoundSnapshots) bool {
+	if rule.Map != "" && !strings.EqualFold(rule.Map, mapName) {
+		return false
+	}
+	if (rule.MinEquipment != nil || rule.MaxEquipment != nil) && r.EquipmentValue < 0 ||
+		rule.MinEquipment != nil && r.EquipmentValue < *rule.MinEquipment ||
+		rule.MaxEquipment != nil && r.EquipmentValue > *rule.MaxEquipment {
+		return false
+	}
+	for _, cond := range rule.When {
+		if !cond.holds(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cond Condition) holds(r reader.RoundSnapshots) bool {
+	for _, S := range r.Snapshots {
+		if S.At != cond.At {
+			continue
+		}
+		count := 0
+		places := make(map[string]bool)
+		for _, p := range S.Players {
+			if len(cond.Callouts) == 0 || contains(cond.Callouts, p.Callout) {
+				count++
+				places[p.Callout] = true
+			}
+		}
+		return count >= cond.Min && (cond.Max == nil || count <= *cond.Max) && len(places) >= cond.MinPlaces
+	}
+	return false
+}
+
+func contains(callouts []string, callout string) bool {
+	for _, c := range callouts {
+		if strings.EqualFold(c, callout) {
+			return true
+		}
+	}
+	return false
+}
+
+// Group is rounds in which the team was spread over the same places.
+type Group struct {
+	// Rounds are indexes into the rounds clustered
+	Rounds []int
+	// Places are where the players of the group's first round were at the last time looked at, the most taken first
+	Places []string
+}
+
+// Cluster groups rounds by where the players of the team were: a round joins the first group whose first round is
+// within maxDistance of it, or starts a new one. The distance of two rounds is the share of players that would have
+// to be in another place for the rounds to be spread alike, averaged over the times they were looked at:
+// 0 if they were spread alike, 1 if no place was taken in both.
+// Groups are returned from the largest on.
+func Cluster(rounds []reader.RoundSnapshots, maxDistance float64) []Group {
+	var res []Group
+	for i, r := range rounds {
+		joined := false
+		for g := range res {
+			if distance(rounds[res[g].Rounds[0]], r) <= maxDistance {
+				res[g].Rounds = append(res[g].Rounds, i)
+				joined = true
+				break
+			}
+		}
+		if !joined {
+			res = append(res, Group{Rounds: []int{i}, Places: places(r)})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return len(res[i].Rounds) > len(res[j].Rounds) })
+	return res
+}
+
+// distance is the total variation distance of the shares of the players in each place, averaged over the times.
+func distance(a, b reader.RoundSnapshots) float64 {
+	n := len(a.Snapshots)
+	if len(b.Snapshots) < n {
+		n = len(b.Snapshots)
+	}
+	if n == 0 {
+		return 0
+	}
+	var sum float64
+	for i := 0; i < n; i++ {
+		sa, sb := shares(a.Snapshots[i]), shares(b.Snapshots[i])
+		switch {
+		case len(sa) == 0 && len(sb) == 0:
+		case len(sa) == 0 || len(sb) == 0:
+			sum++
+		default:
+			var d float64
+			for place, s := range sa {
+				if s > sb[place] {
+					d += s - sb[place]
+				}
+			}
+			sum += d
+		}
+	}
+	return sum / float64(n)
+}
+
+// shares returns the share of the players in each place.
+func shares(S reader.Snapshot) map[string]float64 {
+	res := make(map[string]float64)
+	for _, p := range S.Players {
+		res[p.Callout] += 1 / float64(len(S.Players))
+	}
+	return res
+}
+
+// places returns the places the players were in at the last time of a round, the most taken first.
+func places(r reader.RoundSnapshots) []string {
+	if len(r.Snapshots) == 0 {
+		return nil
+	}
+	counts := make(map[string]int)
+	var res []string
+	for _, p := range r.Snapshots[len(r.Snapshots)-1].Players {
+		if p.Callout == "" {
+			continue
+		}
+		if counts[p.Callout] == 0 {
+			res = append(res, p.Callout)
+		}
+		counts[p.Callout]++
+	}
+	sort.SliceStable(res, func(i, j int) bool { return counts[res[i]] > counts[res[j]] })
+	return res
+}
@@ -0,0 +1,89 @@
+package strategy
+
+import (
+	"csgo-parser-mongodb/reader"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const rules = `{
+	"Times": [20, 40],
+	"Rules": [
+		{"Label": "eco stack", "MaxEquipment": 8000, "When": [{"At": 20, "Callouts": ["Banana"], "Min": 4}]},
+		{"Label": "rush B", "Map": "de_inferno", "When": [{"At": 20, "Callouts": ["b site"], "Min": 3}]},
+		{"Label": "split A", "When": [{"At": 40, "Callouts": ["Apartments"], "Min": 2}, {"At": 40, "Callouts": ["Middle"], "Min": 2, "Max": 3}]},
+		{"Label": "default", "When": [{"At": 20, "MinPlaces": 4}]}
+	]
+}`
+
+// round returns a round whose players were in the given places at 20 and 40 seconds.
+func round(equipment int, at20, at40 []string) reader.RoundSnapshots {
+	snapshot := func(at float64, callouts []string) reader.Snapshot {
+		S := reader.Snapshot{At: at}
+		for i, c := range callouts {
+			S.Players = append(S.Players, reader.PlayerPlace{SteamID: int64(i + 1), Callout: c})
+		}
+		return S
+	}
+	return reader.RoundSnapshots{EquipmentValue: equipment, Snapshots: []reader.Snapshot{snapshot(20, at20), snapshot(40, at40)}}
+}
+
+func TestLabel(t *testing.T) {
+	c, err := Parse(strings.NewReader(rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	banana := []string{"Banana", "Banana", "Banana", "Banana", "Middle"}
+	for _, test := range []struct {
+		mapName  string
+		round    reader.RoundSnapshots
+		expected string
+	}{
+		{"de_inferno", round(4000, banana, banana), "eco stack"},
+		// bought, so not an eco
+		{"de_inferno", round(20000, banana, banana), ""},
+		{"de_inferno", round(-1, banana, banana), ""},
+		{"de_inferno", round(20000, []string{"B Site", "B Site", "B Site"}, nil), "rush B"},
+		{"de_mirage", round(20000, []string{"B Site", "B Site", "B Site"}, nil), ""},
+		{"de_mirage", round(20000, nil, []string{"Apartments", "Apartments", "Middle", "Middle", "Middle"}), "split A"},
+		{"de_mirage", round(20000, nil, []string{"Apartments", "Apartments", "Middle", "Middle", "Middle", "Middle"}), ""},
+		{"de_mirage", round(20000, []string{"Banana", "Middle", "Apartments", "T Spawn"}, nil), "default"},
+	} {
+		label, ok := c.Label(test.mapName, test.round)
+		if label != test.expected || ok != (test.expected != "") {
+			t.Error("Label failed on ", test.round, ", got ", label, " instead of ", test.expected)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, doc := range []string{
+		`{"Rules": []}`,
+		`{"Times": [20], "Rules": [{"Label": "rush", "When": [{"At": 30}]}]}`,
+		`{"Times": [20], "Rules": [{"When": []}]}`,
+		`{"Times": [20], "Rules": [{"Label": "rush", "Whne": []}]}`,
+	} {
+		if _, err := Parse(strings.NewReader(doc)); err == nil {
+			t.Error("Parse failed to reject ", doc)
+		}
+	}
+}
+
+func TestCluster(t *testing.T) {
+	rounds := []reader.RoundSnapshots{
+		round(0, []string{"Banana", "Banana", "Middle"}, []string{"B Site", "B Site", "B Site"}),
+		round(0, []string{"Apartments", "Apartments"}, []string{"A Site", "A Site"}),
+		// one player of three elsewhere at 40 seconds
+		round(0, []string{"Banana", "Banana", "Middle"}, []string{"B Site", "B Site", "Banana"}),
+		round(0, []string{"Banana", "Middle", "Middle"}, []string{"B Site", "B Site", "B Site"}),
+	}
+	res := Cluster(rounds, 0.25)
+	expected := []Group{{Rounds: []int{0, 2, 3}, Places: []string{"B Site"}}, {Rounds: []int{1}, Places: []string{"A Site"}}}
+	if !reflect.DeepEqual(res, expected) {
+		t.Error("Cluster failed, got ", res, " instead of ", expected)
+	}
+	if d := distance(rounds[0], rounds[1]); d != 1 {
+		t.Error("distance failed, got ", d, " instead of 1")
+	}
+}
@@ -0,0 +1,61 @@
+package bitarray
+
+func andDenseWithDenseBitArray(ba, other *BitArray) AbstractBitArray {
+	long, short := ba, other
+	if len(other.BlocksArray) > len(ba.BlocksArray) {
+		long, short = other, ba
+	}
+
+	// bits past the shorter array can't be set in both
+	result := short.copy()
+	for i, block := range long.BlocksArray[:len(short.BlocksArray)] {
+		result.BlocksArray[i] = result.BlocksArray[i].and(block)
+	}
+	result.setLowestAndHighest()
+	return &result
+}
+
+func andSparseWithSparseBitArray(sba, other *SparseBitArray) AbstractBitArray {
+	result := &SparseBitArray{}
+
+	i, j := 0, 0
+	for i < len(sba.indices) && j < len(other.indices) {
+		switch {
+		case sba.indices[i] < other.indices[j]:
+			i++
+		case other.indices[j] < sba.indices[i]:
+			j++
+		default:
+			if block := sba.blocks[i].and(other.blocks[j]); block != 0 {
+				result.indices = append(result.indices, sba.indices[i])
+				result.blocks = append(result.blocks, block)
+			}
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// andSparseWithDenseBitArray returns a sparse bit array, as no more bits are set than in the sparse one.
+func andSparseWithDenseBitArray(sba *SparseBitArray, other *BitArray) AbstractBitArray {
+	result := &SparseBitArray{}
+	for i, index := range sba.indices {
+		if index >= uint64(len(other.BlocksArray)) {
+			break
+		}
+		if block := sba.blocks[i].and(other.BlocksArray[index]); block != 0 {
+			result.indices = append(result.indices, index)
+			result.blocks = append(result.blocks, block)
+		}
+	}
+	return result
+}
+
+func andDenseWithBlocks(ba *BitArray, other AbstractBitArray) AbstractBitArray {
+	return andSparseWithDenseBitArray(sparseBlocks(other), ba)
+}
+
+func andSparseWithBlocks(sba *SparseBitArray, other AbstractBitArray) AbstractBitArray {
+	return andSparseWithSparseBitArray(sba, sparseBlocks(other))
+}
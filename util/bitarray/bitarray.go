@@ -48,14 +48,16 @@ type Iterator interface {
 	Value() (uint64, block)
 }
 
-// bitArray is a struct that maintains state of a bit array.
+// BitArray is a dense bit array, a block for every 64 bits of its capacity.
 type BitArray struct {
 	BlocksArray []block `bson:"BlocksArray"`
-	Lowest      uint64        `bson:"Lowest"`
-	Highest     uint64        `bson:"Highest"`
-	Anyset      bool          `bson:"Anyset"`
+	Lowest      uint64  `bson:"Lowest"`
+	Highest     uint64  `bson:"Highest"`
+	Anyset      bool    `bson:"Anyset"`
 }
 
+var _ AbstractBitArray = (*BitArray)(nil)
+
 func getIndexAndRemainder(k uint64) (uint64, uint64) {
 	return k / s, k % s
 }
@@ -144,7 +146,7 @@ func (ba *BitArray) GetBit(k uint64) (bool, error) {
 	return result, nil
 }
 
-//ClearBit will unset a bit at the given index if it is set.
+// ClearBit will unset a bit at the given index if it is set.
 func (ba *BitArray) ClearBit(k uint64) error {
 	if k >= ba.Capacity() {
 		return OutOfRangeError(k)
@@ -171,54 +173,56 @@ func (ba *BitArray) Reset() {
 		ba.BlocksArray[i] &= block(0)
 	}
 	ba.Anyset = false
+	ba.Lowest = 0
+	ba.Highest = 0
 }
 
 // Equals returns a bool indicating if these two bit arrays are equal.
+// Bit arrays of different kinds or capacities are equal if the same bits are set.
 func (ba *BitArray) Equals(other AbstractBitArray) bool {
-	if other.Capacity() == 0 && ba.Highest > 0 {
-		return false
-	}
+	return equals(ba, other)
+}
 
-	if other.Capacity() == 0 && !ba.Anyset {
-		return true
+// Intersects returns a bool indicating if the supplied bitarray intersects
+// this bitarray, that is whether every bit set in the supplied bitarray is set
+// in this one as well.  If the supplied bitarray is longer than this bitarray,
+// this function returns false.
+func (ba *BitArray) Intersects(other AbstractBitArray) bool {
+	if other.Capacity() > ba.Capacity() {
+		return false
 	}
 
-	var selfIndex uint64
-	for iter := other.Blocks(); iter.Next(); {
-		toIndex, otherBlock := iter.Value()
-		if toIndex > selfIndex {
-			for i := selfIndex; i < toIndex; i++ {
-				if ba.BlocksArray[i] > 0 {
-					return false
-				}
-			}
-		}
-
-		selfIndex = toIndex
-		if !ba.BlocksArray[selfIndex].equals(otherBlock) {
-			return false
-		}
-		selfIndex++
+	switch other := other.(type) {
+	case *BitArray:
+		return ba.intersectsDenseBitArray(other)
+	case *SparseBitArray:
+		return ba.intersectsSparseBitArray(other)
 	}
+	return intersects(ba, other)
+}
 
-	lastIndex, _ := getIndexAndRemainder(ba.Highest)
-	if lastIndex >= selfIndex {
-		return false
+// Or will bitwise or two bit arrays and return a new bit array
+// representing the result.
+func (ba *BitArray) Or(other AbstractBitArray) AbstractBitArray {
+	switch other := other.(type) {
+	case *BitArray:
+		return orDenseWithDenseBitArray(ba, other)
+	case *SparseBitArray:
+		return orSparseWithDenseBitArray(other, ba)
 	}
-
-	return true
+	return orDenseWithBlocks(ba, other)
 }
 
-// Intersects returns a bool indicating if the supplied bitarray intersects
-// this bitarray.  This will check for intersection up to the length of the supplied
-// bitarray.  If the supplied bitarray is longer than this bitarray, this
-// function returns false.
-func (ba *BitArray) Intersects(other *BitArray) bool {
-	if other.Capacity() > ba.Capacity() {
-		return false
+// And will bitwise and two bit arrays and return a new bit array
+// representing the result.
+func (ba *BitArray) And(other AbstractBitArray) AbstractBitArray {
+	switch other := other.(type) {
+	case *BitArray:
+		return andDenseWithDenseBitArray(ba, other)
+	case *SparseBitArray:
+		return andSparseWithDenseBitArray(other, ba)
 	}
-
-	return ba.intersectsDenseBitArray(other)
+	return andDenseWithBlocks(ba, other)
 }
 
 // Blocks will return an iterator over this bit array.
@@ -240,7 +244,7 @@ func (ba *BitArray) complement() {
 
 func (ba *BitArray) intersectsDenseBitArray(other *BitArray) bool {
 	for i, block := range other.BlocksArray {
-		if !!ba.BlocksArray[i].intersects(block) {
+		if !ba.BlocksArray[i].intersects(block) {
 			return false
 		}
 	}
@@ -248,6 +252,43 @@ func (ba *BitArray) intersectsDenseBitArray(other *BitArray) bool {
 	return true
 }
 
+func (ba *BitArray) intersectsSparseBitArray(other *SparseBitArray) bool {
+	for i, index := range other.indices {
+		if index >= uint64(len(ba.BlocksArray)) || !ba.BlocksArray[index].intersects(other.blocks[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// setLowestAndHighest restores the bookkeeping fields after the blocks were changed directly.
+func (ba *BitArray) setLowestAndHighest() {
+	ba.setLowest()
+	if ba.Anyset {
+		ba.setHighest()
+	}
+}
+
+// equals compares the bits set in any two bit arrays.
+func equals(ba, other AbstractBitArray) bool {
+	a, b := sparseBlocks(ba), sparseBlocks(other)
+	if len(a.indices) != len(b.indices) {
+		return false
+	}
+	for i := range a.indices {
+		if a.indices[i] != b.indices[i] || !a.blocks[i].equals(b.blocks[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// intersects tells whether every bit set in other is set in ba, for any two bit arrays.
+func intersects(ba, other AbstractBitArray) bool {
+	return equals(other.And(ba), other)
+}
+
 type blocks []block
 
 func (ba *BitArray) copy() BitArray {
@@ -318,3 +359,23 @@ func newDenseBitArray(size uint64, args ...bool) BitArray {
 func NewDenseBitArray(size uint64, args ...bool) BitArray {
 	return newDenseBitArray(size, args...)
 }
+
+// NewBitArrayFromWords returns a BitArray holding the given words,
+// bit k being bit k%64 of words[k/64].
+func NewBitArrayFromWords(words []uint64) BitArray {
+	ba := BitArray{BlocksArray: make([]block, len(words))}
+	for i, w := range words {
+		ba.BlocksArray[i] = block(w)
+	}
+	ba.setLowestAndHighest()
+	return ba
+}
+
+// Words returns the bit array as words, the way NewBitArrayFromWords takes them.
+func (ba *BitArray) Words() []uint64 {
+	words := make([]uint64, len(ba.BlocksArray))
+	for i, b := range ba.BlocksArray {
+		words[i] = uint64(b)
+	}
+	return words
+}
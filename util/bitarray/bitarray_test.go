@@ -0,0 +1,93 @@
+package bitarray
+
+import (
+	"reflect"
+	"testing"
+)
+
+func denseWith(size uint64, bits ...uint64) *BitArray {
+	ba := newBitArray(size)
+	for _, k := range bits {
+		if err := ba.SetBit(k); err != nil {
+			panic(err)
+		}
+	}
+	return ba
+}
+
+func TestSetGetClearBit(t *testing.T) {
+	ba := denseWith(200, 3, 64, 130)
+	if ba.Lowest != 3 || ba.Highest != 130 || !ba.Anyset {
+		t.Errorf("bookkeeping failed: %d %d %v", ba.Lowest, ba.Highest, ba.Anyset)
+	}
+	if set, err := ba.GetBit(64); !set || err != nil {
+		t.Error("GetBit failed on a set bit")
+	}
+	if set, err := ba.GetBit(65); set || err != nil {
+		t.Error("GetBit failed on a cleared bit")
+	}
+	if _, err := ba.GetBit(ba.Capacity()); err == nil {
+		t.Error("GetBit didn't fail out of range")
+	}
+	if err := ba.ClearBit(130); err != nil || ba.Highest != 64 {
+		t.Error("ClearBit failed to move Highest, got ", ba.Highest)
+	}
+	if nums := ba.ToNums(); !reflect.DeepEqual(nums, []uint64{3, 64}) {
+		t.Error("ToNums failed, got ", nums)
+	}
+	ba.Reset()
+	if ba.Anyset || ba.Blocks().Next() {
+		t.Error("Reset failed")
+	}
+}
+
+func TestIntersects(t *testing.T) {
+	ba := denseWith(128, 1, 5, 70)
+	if !ba.Intersects(denseWith(128, 5, 70)) {
+		t.Error("Intersects failed on a subset")
+	}
+	if ba.Intersects(denseWith(128, 5, 71)) {
+		t.Error("Intersects succeeded on bits that aren't set")
+	}
+	if ba.Intersects(denseWith(256, 5)) {
+		t.Error("Intersects succeeded on a longer bit array")
+	}
+}
+
+func TestDenseOrAnd(t *testing.T) {
+	a, b := denseWith(64, 1, 2, 63), denseWith(192, 2, 100, 191)
+	or := a.Or(b)
+	if !or.Equals(denseWith(192, 1, 2, 63, 100, 191)) {
+		t.Error("Or failed, got ", or.ToNums())
+	}
+	and := b.And(a)
+	if !and.Equals(denseWith(64, 2)) {
+		t.Error("And failed, got ", and.ToNums())
+	}
+	if nums := a.ToNums(); !reflect.DeepEqual(nums, []uint64{1, 2, 63}) {
+		t.Error("Or changed its receiver, got ", nums)
+	}
+}
+
+func TestEquals(t *testing.T) {
+	if !denseWith(64, 3).Equals(denseWith(256, 3)) {
+		t.Error("Equals failed with the same bits in different capacities")
+	}
+	if denseWith(64, 3).Equals(denseWith(256, 3, 200)) {
+		t.Error("Equals succeeded with different bits")
+	}
+	if !newBitArray(0).Equals(newBitArray(128)) {
+		t.Error("Equals failed on empty bit arrays")
+	}
+}
+
+func TestWords(t *testing.T) {
+	words := []uint64{0, 1 << 63, 5}
+	ba := NewBitArrayFromWords(words)
+	if ba.Lowest != 127 || ba.Highest != 130 {
+		t.Errorf("bookkeeping failed: %d %d", ba.Lowest, ba.Highest)
+	}
+	if res := ba.Words(); !reflect.DeepEqual(res, words) {
+		t.Error("Words failed, got ", res)
+	}
+}
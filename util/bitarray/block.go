@@ -21,7 +21,7 @@ const maximumBlock = block(0) | ^block(0)
 
 func (b block) toNums(offset uint64, nums *[]uint64) {
 	for i := uint64(0); i < s; i++ {
-		if b&block(1<<i) > 0 {
+		if b&block(1<<i) != 0 {
 			*nums = append(*nums, i+offset)
 		}
 	}
@@ -0,0 +1,115 @@
+package bitarray
+
+import "encoding/binary"
+
+// A marshaled bit array starts with a byte telling its kind, followed by the number of
+// its blocks as a little endian uint64. Dense arrays are followed by all their blocks,
+// sparse arrays by the index and the block of every block that has bits set, all little endian.
+const (
+	denseMarker  = 'D'
+	sparseMarker = 'S'
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (ba *BitArray) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 9, 9+8*len(ba.BlocksArray))
+	data[0] = denseMarker
+	binary.LittleEndian.PutUint64(data[1:], uint64(len(ba.BlocksArray)))
+	for _, block := range ba.BlocksArray {
+		data = appendUint64(data, uint64(block))
+	}
+	return data, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (ba *BitArray) UnmarshalBinary(data []byte) error {
+	count, data, err := readHeader(data, denseMarker, 8)
+	if err != nil {
+		return err
+	}
+
+	ba.BlocksArray = make([]block, count)
+	for i := range ba.BlocksArray {
+		ba.BlocksArray[i] = block(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+	ba.setLowestAndHighest()
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (sba *SparseBitArray) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 9, 9+16*len(sba.blocks))
+	data[0] = sparseMarker
+	binary.LittleEndian.PutUint64(data[1:], uint64(len(sba.blocks)))
+	for i, block := range sba.blocks {
+		data = appendUint64(data, sba.indices[i])
+		data = appendUint64(data, uint64(block))
+	}
+	return data, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (sba *SparseBitArray) UnmarshalBinary(data []byte) error {
+	count, data, err := readHeader(data, sparseMarker, 16)
+	if err != nil {
+		return err
+	}
+
+	sba.indices = make([]uint64, count)
+	sba.blocks = make(blocks, count)
+	for i := range sba.blocks {
+		sba.indices[i] = binary.LittleEndian.Uint64(data[16*i:])
+		sba.blocks[i] = block(binary.LittleEndian.Uint64(data[16*i+8:]))
+		if i > 0 && sba.indices[i] <= sba.indices[i-1] {
+			return MalformedBinaryError("block indices aren't sorted")
+		}
+	}
+	return nil
+}
+
+// Unmarshal returns the dense or sparse bit array data was marshaled from.
+func Unmarshal(data []byte) (AbstractBitArray, error) {
+	if len(data) == 0 {
+		return nil, MalformedBinaryError("no data")
+	}
+
+	var ba interface {
+		AbstractBitArray
+		UnmarshalBinary(data []byte) error
+	}
+	switch data[0] {
+	case denseMarker:
+		ba = &BitArray{}
+	case sparseMarker:
+		ba = &SparseBitArray{}
+	default:
+		return nil, MalformedBinaryError("unknown kind of bitarray")
+	}
+	if err := ba.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return ba, nil
+}
+
+func appendUint64(data []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(data, b[:]...)
+}
+
+// readHeader checks the kind of a marshaled bitarray and whether it is as long as its number
+// of blocks says, returning that number and the data after the header.
+func readHeader(data []byte, marker byte, blockSize uint64) (uint64, []byte, error) {
+	if len(data) < 9 {
+		return 0, nil, MalformedBinaryError("too short")
+	}
+	if data[0] != marker {
+		return 0, nil, MalformedBinaryError("wrong kind of bitarray")
+	}
+	count := binary.LittleEndian.Uint64(data[1:])
+	data = data[9:]
+	if count > uint64(len(data))/blockSize || uint64(len(data)) != count*blockSize {
+		return 0, nil, MalformedBinaryError("length doesn't match the number of blocks")
+	}
+	return count, data, nil
+}
@@ -10,3 +10,11 @@ type OutOfRangeError uint64
 func (err OutOfRangeError) Error() string {
 	return fmt.Sprintf(`Index %d is out of range.`, err)
 }
+
+// MalformedBinaryError is an error caused by unmarshaling data that isn't a marshaled bitarray.
+type MalformedBinaryError string
+
+// Error returns a human readable description of what is wrong with the data.
+func (err MalformedBinaryError) Error() string {
+	return fmt.Sprintf(`Malformed bitarray binary: %s.`, string(err))
+}
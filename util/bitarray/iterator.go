@@ -19,6 +19,10 @@ func (iter *bitArrayIterator) Value() (uint64, block) {
 }
 
 func newBitArrayIterator(ba *BitArray) *bitArrayIterator {
+	if !ba.Anyset {
+		// no blocks to iterate over, there may not even be any
+		return &bitArrayIterator{ba: ba, index: 0, stopIndex: 0}
+	}
 	stop, _ := getIndexAndRemainder(ba.Highest)
 	start, _ := getIndexAndRemainder(ba.Lowest)
 	return &bitArrayIterator{
@@ -27,3 +31,27 @@ func newBitArrayIterator(ba *BitArray) *bitArrayIterator {
 		stopIndex: stop,
 	}
 }
+
+type sparseBitArrayIterator struct {
+	index int64
+	sba   *SparseBitArray
+}
+
+// Next increments the index and returns a bool indicating if any further
+// items exist.
+func (iter *sparseBitArrayIterator) Next() bool {
+	iter.index++
+	return iter.index < int64(len(iter.sba.indices))
+}
+
+// Value returns an index and the block at this index.
+func (iter *sparseBitArrayIterator) Value() (uint64, block) {
+	return iter.sba.indices[iter.index], iter.sba.blocks[iter.index]
+}
+
+func newSparseBitArrayIterator(sba *SparseBitArray) *sparseBitArrayIterator {
+	return &sparseBitArrayIterator{
+		sba:   sba,
+		index: -1,
+	}
+}
@@ -0,0 +1,78 @@
+package bitarray
+
+func orDenseWithDenseBitArray(ba, other *BitArray) AbstractBitArray {
+	long, short := ba, other
+	if len(other.BlocksArray) > len(ba.BlocksArray) {
+		long, short = other, ba
+	}
+
+	result := long.copy()
+	for i, block := range short.BlocksArray {
+		result.BlocksArray[i] = result.BlocksArray[i].or(block)
+	}
+	result.setLowestAndHighest()
+	return &result
+}
+
+func orSparseWithSparseBitArray(sba, other *SparseBitArray) AbstractBitArray {
+	result := &SparseBitArray{
+		blocks:  make(blocks, 0, len(sba.blocks)+len(other.blocks)),
+		indices: make([]uint64, 0, len(sba.indices)+len(other.indices)),
+	}
+
+	i, j := 0, 0
+	for i < len(sba.indices) || j < len(other.indices) {
+		switch {
+		case j == len(other.indices) || i < len(sba.indices) && sba.indices[i] < other.indices[j]:
+			result.indices = append(result.indices, sba.indices[i])
+			result.blocks = append(result.blocks, sba.blocks[i])
+			i++
+		case i == len(sba.indices) || other.indices[j] < sba.indices[i]:
+			result.indices = append(result.indices, other.indices[j])
+			result.blocks = append(result.blocks, other.blocks[j])
+			j++
+		default:
+			result.indices = append(result.indices, sba.indices[i])
+			result.blocks = append(result.blocks, sba.blocks[i].or(other.blocks[j]))
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// orSparseWithDenseBitArray returns a dense bit array, large enough for the bits of both.
+func orSparseWithDenseBitArray(sba *SparseBitArray, other *BitArray) AbstractBitArray {
+	return orDenseWithBlocks(other, sba)
+}
+
+// orDenseWithBlocks ors the blocks of any bit array into a copy of a dense one.
+func orDenseWithBlocks(ba *BitArray, other AbstractBitArray) AbstractBitArray {
+	result := ba.copy()
+	for iter := other.Blocks(); iter.Next(); {
+		index, block := iter.Value()
+		for uint64(len(result.BlocksArray)) <= index {
+			result.BlocksArray = append(result.BlocksArray, 0)
+		}
+		result.BlocksArray[index] = result.BlocksArray[index].or(block)
+	}
+	result.setLowestAndHighest()
+	return &result
+}
+
+func orSparseWithBlocks(sba *SparseBitArray, other AbstractBitArray) AbstractBitArray {
+	return orSparseWithSparseBitArray(sba, sparseBlocks(other))
+}
+
+// sparseBlocks returns the blocks of any bit array that have bits set as a sparse bit array.
+func sparseBlocks(ba AbstractBitArray) *SparseBitArray {
+	result := &SparseBitArray{}
+	for iter := ba.Blocks(); iter.Next(); {
+		index, block := iter.Value()
+		if block != 0 {
+			result.indices = append(result.indices, index)
+			result.blocks = append(result.blocks, block)
+		}
+	}
+	return result
+}
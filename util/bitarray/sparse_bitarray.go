@@ -0,0 +1,137 @@
+package bitarray
+
+import "sort"
+
+// SparseBitArray only stores the blocks that have bits set, sorted by their indices.
+// It suits bit arrays with few bits set far apart and never runs out of capacity.
+type SparseBitArray struct {
+	blocks  blocks
+	indices []uint64
+}
+
+var _ AbstractBitArray = (*SparseBitArray)(nil)
+
+// NewSparseBitArray returns an empty sparse bit array.
+func NewSparseBitArray() *SparseBitArray {
+	return &SparseBitArray{}
+}
+
+// search returns the position of the block with the given index, or where it would have to be inserted.
+func (sba *SparseBitArray) search(index uint64) (int, bool) {
+	i := sort.Search(len(sba.indices), func(i int) bool { return sba.indices[i] >= index })
+	return i, i < len(sba.indices) && sba.indices[i] == index
+}
+
+// SetBit sets the bit at the given position.
+func (sba *SparseBitArray) SetBit(k uint64) error {
+	index, pos := getIndexAndRemainder(k)
+	i, ok := sba.search(index)
+	if ok {
+		sba.blocks[i] = sba.blocks[i].insert(pos)
+		return nil
+	}
+
+	sba.blocks = append(sba.blocks, 0)
+	copy(sba.blocks[i+1:], sba.blocks[i:])
+	sba.blocks[i] = block(0).insert(pos)
+	sba.indices = append(sba.indices, 0)
+	copy(sba.indices[i+1:], sba.indices[i:])
+	sba.indices[i] = index
+	return nil
+}
+
+// GetBit gets the bit at the given position.
+func (sba *SparseBitArray) GetBit(k uint64) (bool, error) {
+	index, pos := getIndexAndRemainder(k)
+	i, ok := sba.search(index)
+	return ok && sba.blocks[i].get(pos), nil
+}
+
+// ClearBit clears the bit at the given position, dropping its block if no other bit is set in it.
+func (sba *SparseBitArray) ClearBit(k uint64) error {
+	index, pos := getIndexAndRemainder(k)
+	i, ok := sba.search(index)
+	if !ok {
+		return nil
+	}
+
+	sba.blocks[i] = sba.blocks[i].remove(pos)
+	if sba.blocks[i] == 0 {
+		sba.blocks = append(sba.blocks[:i], sba.blocks[i+1:]...)
+		sba.indices = append(sba.indices[:i], sba.indices[i+1:]...)
+	}
+	return nil
+}
+
+// Reset sets all values to zero.
+func (sba *SparseBitArray) Reset() {
+	sba.blocks = sba.blocks[:0]
+	sba.indices = sba.indices[:0]
+}
+
+// Blocks returns an iterator over the blocks that have bits set.
+func (sba *SparseBitArray) Blocks() Iterator {
+	return newSparseBitArrayIterator(sba)
+}
+
+// Equals returns a bool indicating if these two bit arrays are equal.
+func (sba *SparseBitArray) Equals(other AbstractBitArray) bool {
+	return equals(sba, other)
+}
+
+// Intersects returns a bool indicating if every bit set in the supplied
+// bit array is set in this one as well.
+func (sba *SparseBitArray) Intersects(other AbstractBitArray) bool {
+	return intersects(sba, other)
+}
+
+// Capacity returns the capacity up to the highest block that has bits set.
+func (sba *SparseBitArray) Capacity() uint64 {
+	if len(sba.indices) == 0 {
+		return 0
+	}
+	return (sba.indices[len(sba.indices)-1] + 1) * s
+}
+
+// Or will bitwise or two bit arrays and return a new bit array
+// representing the result.
+func (sba *SparseBitArray) Or(other AbstractBitArray) AbstractBitArray {
+	switch other := other.(type) {
+	case *SparseBitArray:
+		return orSparseWithSparseBitArray(sba, other)
+	case *BitArray:
+		return orSparseWithDenseBitArray(sba, other)
+	}
+	return orSparseWithBlocks(sba, other)
+}
+
+// And will bitwise and two bit arrays and return a new bit array
+// representing the result.
+func (sba *SparseBitArray) And(other AbstractBitArray) AbstractBitArray {
+	switch other := other.(type) {
+	case *SparseBitArray:
+		return andSparseWithSparseBitArray(sba, other)
+	case *BitArray:
+		return andSparseWithDenseBitArray(sba, other)
+	}
+	return andSparseWithBlocks(sba, other)
+}
+
+// ToNums converts this bit array to the list of numbers contained within it.
+func (sba *SparseBitArray) ToNums() []uint64 {
+	var nums []uint64
+	for i, block := range sba.blocks {
+		block.toNums(sba.indices[i]*s, &nums)
+	}
+	return nums
+}
+
+func (sba *SparseBitArray) copy() *SparseBitArray {
+	result := &SparseBitArray{
+		blocks:  make(blocks, len(sba.blocks)),
+		indices: make([]uint64, len(sba.indices)),
+	}
+	copy(result.blocks, sba.blocks)
+	copy(result.indices, sba.indices)
+	return result
+}
@@ -0,0 +1,102 @@
+package bitarray
+
+import (
+	"reflect"
+	"testing"
+)
+
+func sparseWith(bits ...uint64) *SparseBitArray {
+	sba := NewSparseBitArray()
+	for _, k := range bits {
+		if err := sba.SetBit(k); err != nil {
+			panic(err)
+		}
+	}
+	return sba
+}
+
+func TestSparseSetGetClearBit(t *testing.T) {
+	sba := sparseWith(1<<40, 5, 700, 6)
+	if nums := sba.ToNums(); !reflect.DeepEqual(nums, []uint64{5, 6, 700, 1 << 40}) {
+		t.Error("ToNums failed, got ", nums)
+	}
+	if set, _ := sba.GetBit(700); !set {
+		t.Error("GetBit failed on a set bit")
+	}
+	if set, _ := sba.GetBit(701); set {
+		t.Error("GetBit failed on a cleared bit")
+	}
+	if sba.Capacity() != (1<<40/s+1)*s {
+		t.Error("Capacity failed, got ", sba.Capacity())
+	}
+	sba.ClearBit(700)
+	sba.ClearBit(1 << 40)
+	if len(sba.blocks) != 1 || sba.Capacity() != s {
+		t.Error("ClearBit didn't drop empty blocks, got ", sba.indices)
+	}
+	sba.Reset()
+	if sba.Blocks().Next() || sba.Capacity() != 0 {
+		t.Error("Reset failed")
+	}
+}
+
+func TestSparseOrAnd(t *testing.T) {
+	a, b := sparseWith(1, 100, 1000), sparseWith(100, 101, 5000)
+	if or := a.Or(b); !or.Equals(sparseWith(1, 100, 101, 1000, 5000)) {
+		t.Error("Or failed, got ", or.ToNums())
+	}
+	if and := a.And(b); !and.Equals(sparseWith(100)) {
+		t.Error("And failed, got ", and.ToNums())
+	}
+	if and := a.And(sparseWith(2, 5000)); and.Capacity() != 0 {
+		t.Error("And kept empty blocks, got ", and.ToNums())
+	}
+}
+
+func TestMixedOrAnd(t *testing.T) {
+	dense, sparse := denseWith(128, 1, 70), sparseWith(70, 71, 300)
+	expectedOr := sparseWith(1, 70, 71, 300)
+	for _, or := range []AbstractBitArray{dense.Or(sparse), sparse.Or(dense)} {
+		if _, ok := or.(*BitArray); !ok || !or.Equals(expectedOr) {
+			t.Errorf("Or failed, got %T %v", or, or.ToNums())
+		}
+	}
+	for _, and := range []AbstractBitArray{dense.And(sparse), sparse.And(dense)} {
+		if _, ok := and.(*SparseBitArray); !ok || !and.Equals(denseWith(128, 70)) {
+			t.Errorf("And failed, got %T %v", and, and.ToNums())
+		}
+	}
+
+	if !dense.Intersects(sparseWith(70)) || dense.Intersects(sparseWith(71)) {
+		t.Error("dense Intersects failed with a sparse bit array")
+	}
+	if !sparse.Intersects(denseWith(128, 71)) || sparse.Intersects(dense) {
+		t.Error("sparse Intersects failed with a dense bit array")
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	for _, ba := range []AbstractBitArray{
+		denseWith(300, 0, 64, 299),
+		newBitArray(0),
+		sparseWith(3, 1<<33),
+		NewSparseBitArray(),
+	} {
+		data, err := ba.(interface{ MarshalBinary() ([]byte, error) }).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := Unmarshal(data)
+		if err != nil || reflect.TypeOf(res) != reflect.TypeOf(ba) || !res.Equals(ba) || res.Capacity() != ba.Capacity() {
+			t.Errorf("Unmarshal failed on %v, got %v %v", ba.ToNums(), res, err)
+		}
+	}
+
+	data, _ := denseWith(128, 1).MarshalBinary()
+	if _, err := Unmarshal(data[:len(data)-1]); err == nil {
+		t.Error("Unmarshal didn't fail on truncated data")
+	}
+	if err := NewSparseBitArray().UnmarshalBinary(data); err == nil {
+		t.Error("UnmarshalBinary didn't fail on the wrong kind of bit array")
+	}
+}
@@ -0,0 +1,160 @@
+// Package bitio writes and reads streams of bits packed into 64 bit words.
+// Bit k of a stream is bit k%64 of word k/64, the layout bitarray.BitArray uses,
+// so streams can be stored as bit arrays without being rearranged.
+package bitio
+
+import "math/bits"
+
+const wordSize = 64
+
+// BitWriter appends bits to a stream.
+type BitWriter struct {
+	words	[]uint64
+	length	uint64
+}
+
+// NewBitWriter returns a writer with room for sizeHint bits before it has to grow.
+func NewBitWriter(sizeHint uint64) *BitWriter {
+	return &BitWriter{words: make([]uint64, 0, (sizeHint+wordSize-1)/wordSize)}
+}
+
+func (w *BitWriter) grow(n uint64) {
+	need := (w.length + n + wordSize - 1) / wordSize
+	for uint64(len(w.words)) < need {
+		w.words = append(w.words, 0)
+	}
+}
+
+// WriteBit appends a single bit.
+func (w *BitWriter) WriteBit(bit bool) {
+	w.grow(1)
+	if bit {
+		w.words[w.length/wordSize] |= 1 << (w.length % wordSize)
+	}
+	w.length++
+}
+
+// WriteBits appends the n low bits of v, most significant first. n must not exceed 64.
+func (w *BitWriter) WriteBits(v uint64, n uint) {
+	if n == 0 {
+		return
+	}
+	w.grow(uint64(n))
+	// the stream is filled from the least significant bit of a word on,
+	// so the value is mirrored to have its most significant bit written first
+	r := bits.Reverse64(v) >> (wordSize - n)
+	i, off := w.length/wordSize, uint(w.length%wordSize)
+	w.words[i] |= r << off
+	if off+n > wordSize {
+		w.words[i+1] |= r >> (wordSize - off)
+	}
+	w.length += uint64(n)
+}
+
+// WriteZeros appends n zero bits.
+func (w *BitWriter) WriteZeros(n uint64) {
+	w.grow(n)
+	w.length += n
+}
+
+// WriteStream appends n bits of another stream, starting at its bit from.
+func (w *BitWriter) WriteStream(words []uint64, from, n uint64) {
+	r := &BitReader{words: words, pos: from}
+	w.grow(n)
+	for n > 0 {
+		k := uint(wordSize)
+		if n < wordSize {
+			k = uint(n)
+		}
+		v := r.readRaw(k)
+		i, off := w.length/wordSize, uint(w.length%wordSize)
+		w.words[i] |= v << off
+		if off+k > wordSize {
+			w.words[i+1] |= v >> (wordSize - off)
+		}
+		w.length += uint64(k)
+		n -= uint64(k)
+	}
+}
+
+// Len returns the number of bits written.
+func (w *BitWriter) Len() uint64 {
+	return w.length
+}
+
+// Words returns the stream written so far. Bits past Len are zero.
+func (w *BitWriter) Words() []uint64 {
+	return w.words
+}
+
+// BitReader reads bits from a stream.
+// Bits past the end of its words read as zero, as if the stream went on unset.
+type BitReader struct {
+	words	[]uint64
+	pos		uint64
+}
+
+func NewBitReader(words []uint64) *BitReader {
+	return &BitReader{words: words}
+}
+
+// Pos returns the number of bits read.
+func (r *BitReader) Pos() uint64 {
+	return r.pos
+}
+
+func (r *BitReader) word(i uint64) uint64 {
+	if i < uint64(len(r.words)) {
+		return r.words[i]
+	}
+	return 0
+}
+
+// ReadBit reads a single bit.
+func (r *BitReader) ReadBit() bool {
+	bit := r.word(r.pos/wordSize)>>(r.pos%wordSize)&1 == 1
+	r.pos++
+	return bit
+}
+
+// ReadBits reads n bits and returns them with the first one read as the most significant.
+// n must not exceed 64.
+func (r *BitReader) ReadBits(n uint) uint64 {
+	if n == 0 {
+		return 0
+	}
+	return bits.Reverse64(r.readRaw(n)) >> (wordSize - n)
+}
+
+// readRaw reads 0 < n <= 64 bits in stream order, the first one read as the least significant.
+func (r *BitReader) readRaw(n uint) uint64 {
+	i, off := r.pos/wordSize, uint(r.pos%wordSize)
+	v := r.word(i) >> off
+	if off+n > wordSize {
+		v |= r.word(i+1) << (wordSize - off)
+	}
+	if n < wordSize {
+		v &= 1<<n - 1
+	}
+	r.pos += uint64(n)
+	return v
+}
+
+// ReadUnary counts the zero bits up to the next set bit and reads past that bit too.
+// If no bit is set in the rest of the stream, it reports false and the reader is left at the end of its words.
+func (r *BitReader) ReadUnary() (uint64, bool) {
+	var count uint64
+	for i := r.pos / wordSize; i < uint64(len(r.words)); i++ {
+		off := r.pos % wordSize
+		w := r.words[i] >> off
+		if w == 0 {
+			count += wordSize - off
+			r.pos += wordSize - off
+			continue
+		}
+		zeros := uint64(bits.TrailingZeros64(w))
+		r.pos += zeros + 1
+		return count + zeros, true
+	}
+	return count, false
+}
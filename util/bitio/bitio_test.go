@@ -0,0 +1,84 @@
+package bitio
+
+import "testing"
+
+func TestWriteReadBits(t *testing.T) {
+	values := []struct {
+		v	uint64
+		n	uint
+	}{
+		{1, 1}, {0, 3}, {5, 3}, {0xdeadbeef, 32}, {1<<63 | 1, 64}, {0, 0}, {6, 7}, {^uint64(0), 64}, {2, 2},
+	}
+
+	w := NewBitWriter(0)
+	var length uint64
+	for _, value := range values {
+		w.WriteBits(value.v, value.n)
+		length += uint64(value.n)
+	}
+	w.WriteZeros(70)
+	w.WriteBit(true)
+	if w.Len() != length+71 {
+		t.Fatal("Len failed, got ", w.Len(), " instead of ", length+71)
+	}
+
+	r := NewBitReader(w.Words())
+	for _, value := range values {
+		if res := r.ReadBits(value.n); res != value.v {
+			t.Error("ReadBits failed, got ", res, " instead of ", value.v)
+		}
+	}
+	if zeros, ok := r.ReadUnary(); !ok || zeros != 70 {
+		t.Error("ReadUnary failed, got ", zeros, ok, " instead of 70 true")
+	}
+	if r.Pos() != w.Len() {
+		t.Error("Pos failed, got ", r.Pos(), " instead of ", w.Len())
+	}
+	if _, ok := r.ReadUnary(); ok {
+		t.Error("ReadUnary found a set bit past the end of the stream")
+	}
+	if r.ReadBits(64) != 0 || r.ReadBit() {
+		t.Error("bits past the end of the stream aren't zero")
+	}
+}
+
+func TestStreamLayout(t *testing.T) {
+	// the first bit written is the lowest bit of the first word, as in bitarray.BitArray
+	w := NewBitWriter(128)
+	w.WriteBits(0x5, 3)
+	w.WriteZeros(62)
+	w.WriteBit(true)
+	if words := w.Words(); len(words) != 2 || words[0] != 0x5 || words[1] != 0x2 {
+		t.Errorf("unexpected layout %#x", words)
+	}
+}
+
+func TestWriteStream(t *testing.T) {
+	src := NewBitWriter(200)
+	for i := uint64(0); i < 150; i++ {
+		src.WriteBit(i%3 == 0 || i%7 == 0)
+	}
+	for _, c := range []struct{ prefix, from, n uint64 }{{0, 0, 150}, {5, 0, 150}, {3, 10, 130}, {64, 70, 64}, {1, 149, 1}, {7, 20, 0}} {
+		w := NewBitWriter(0)
+		w.WriteZeros(c.prefix)
+		w.WriteStream(src.Words(), c.from, c.n)
+		w.WriteBit(true)
+		if w.Len() != c.prefix+c.n+1 {
+			t.Error("WriteStream failed with ", c, ", wrote ", w.Len(), " bits")
+		}
+		r := NewBitReader(w.Words())
+		for i := uint64(0); i < c.prefix; i++ {
+			if r.ReadBit() {
+				t.Error("WriteStream failed with ", c, ", set bit ", i)
+			}
+		}
+		for i := c.from; i < c.from+c.n; i++ {
+			if expected := i%3 == 0 || i%7 == 0; r.ReadBit() != expected {
+				t.Error("WriteStream failed with ", c, ", bit ", i, " isn't ", expected)
+			}
+		}
+		if !r.ReadBit() {
+			t.Error("WriteStream failed with ", c, ", overwrote the next bit")
+		}
+	}
+}
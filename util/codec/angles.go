@@ -0,0 +1,48 @@
+package codec
+
+// Angles are whole units, turn of them making a full circle, e.g. 360 for whole degrees.
+
+// UnwrapAngles removes the jumps of angles wrapping around, so every step is the shortest arc
+// between two angles. Turning across 0 then costs a small delta instead of almost a full turn.
+func UnwrapAngles(angles []int, turn int) []int {
+	unwrapped := make([]int, len(angles))
+	for i, a := range angles {
+		if i == 0 {
+			unwrapped[i] = a
+			continue
+		}
+		step := (a - angles[i-1]) % turn
+		if step > turn/2 {
+			step -= turn
+		} else if step < -turn/2 {
+			step += turn
+		}
+		unwrapped[i] = unwrapped[i-1] + step
+	}
+	return unwrapped
+}
+
+// SignedAngles maps angles in [0, turn) to [-turn/2, turn/2], e.g. a pitch of 350 degrees to -10.
+func SignedAngles(angles []int, turn int) []int {
+	signed := make([]int, len(angles))
+	for i, a := range angles {
+		if a > turn/2 {
+			a -= turn
+		}
+		signed[i] = a
+	}
+	return signed
+}
+
+// WrapAngles maps angles back to [0, turn), undoing both UnwrapAngles and SignedAngles.
+func WrapAngles(angles []int, turn int) []int {
+	wrapped := make([]int, len(angles))
+	for i, a := range angles {
+		a %= turn
+		if a < 0 {
+			a += turn
+		}
+		wrapped[i] = a
+	}
+	return wrapped
+}
@@ -0,0 +1,100 @@
+// Package codec provides the integer codecs encoded position streams can be stored with.
+// Every encoded document records the ID of the codec its streams were encoded with,
+// so they can be decoded whichever codec was picked when the demo was parsed.
+package codec
+
+import (
+	"csgo-parser-mongodb/util/elias"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ID identifies a codec in stored documents. IDs must never be reused.
+type ID int
+
+const (
+	// Gamma is the zero value, as documents stored before codecs could be picked are all gamma coded.
+	Gamma ID = iota
+	Delta
+	Varint
+	Rice
+	EliasFano
+)
+
+// Codec encodes series of integers, usually deltas, which may be negative.
+type Codec interface {
+	ID() ID
+	Name() string
+	Encode(values []int) elias.BitArrayWithLength
+	// Decode restores n values. Codecs that can tell where their stream ends
+	// also accept n < 0 to decode every value in ba, the others fail on it.
+	Decode(ba elias.BitArrayWithLength, n int) ([]int, error)
+}
+
+var codecs = map[ID]Codec{
+	Gamma:     gamma{},
+	Delta:     delta{},
+	Varint:    varint{},
+	Rice:      rice{},
+	EliasFano: eliasFano{},
+}
+
+// Get returns the codec with the given ID.
+func Get(id ID) (Codec, error) {
+	if c, ok := codecs[id]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown codec %d", id)
+}
+
+// ByName returns the codec with the given name, as used in command line flags.
+func ByName(name string) (Codec, error) {
+	for _, c := range codecs {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown codec %q, must be one of %s", name, strings.Join(Names(), ", "))
+}
+
+// Names returns the names of all codecs.
+func Names() []string {
+	var names []string
+	for _, c := range codecs {
+		names = append(names, c.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (id ID) String() string {
+	if c, ok := codecs[id]; ok {
+		return c.Name()
+	}
+	return fmt.Sprintf("codec(%d)", int(id))
+}
+
+// zigzag maps integers to naturals, 0, -1, 1, -2, 2... to 0, 1, 2, 3, 4...
+func zigzag(v int) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func unzigzag(u uint64) int {
+	return int(u>>1) ^ -int(u&1)
+}
+
+// checkCount fails on n < 0 for codecs that can't tell where their stream ends.
+func checkCount(c Codec, n int) error {
+	if n < 0 {
+		return fmt.Errorf("%s streams can't be decoded without their number of values", c.Name())
+	}
+	return nil
+}
+
+func truncate(values []int, n int) []int {
+	if n >= 0 && len(values) > n {
+		return values[:n]
+	}
+	return values
+}
@@ -0,0 +1,192 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testValues() map[string][]int {
+	walk := make([]int, 500)
+	for i := range walk {
+		walk[i] = (i*7919)%61 - 30
+	}
+	return map[string][]int{
+		"empty":    {},
+		"zeros":    {0, 0, 0, 0, 0},
+		"small":    {3, -15, 123, -31, 0, 42, 0, 4, -1},
+		"outliers": {0, 1, -1, 0, 1 << 20, -(1 << 33), 2, 0, 1 << 40},
+		"walk":     walk,
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, name := range Names() {
+		c, err := ByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for valuesName, values := range testValues() {
+			res, err := c.Decode(c.Encode(values), len(values))
+			if err != nil {
+				t.Errorf("%s failed on %s values: %v", name, valuesName, err)
+			} else if !reflect.DeepEqual(res, values) && !(len(res) == 0 && len(values) == 0) {
+				t.Errorf("%s failed on %s values, got %v instead of %v", name, valuesName, res, values)
+			}
+		}
+	}
+}
+
+func TestDecodeWithoutCount(t *testing.T) {
+	values := []int{3, -1, 0, 0, 7}
+	for _, name := range Names() {
+		c, _ := ByName(name)
+		res, err := c.Decode(c.Encode(values), -1)
+		switch c.ID() {
+		case Gamma, Delta:
+			if err != nil || !reflect.DeepEqual(res, values) {
+				t.Error(name, " failed, got ", res, err, " instead of ", values)
+			}
+		default:
+			if err == nil {
+				t.Error(name, " didn't fail without the number of values")
+			}
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	for id := Gamma; id <= EliasFano; id++ {
+		c, err := Get(id)
+		if err != nil || c.ID() != id {
+			t.Errorf("Get(%d) failed: %v", id, err)
+		}
+	}
+	if _, err := Get(EliasFano + 1); err == nil {
+		t.Error("Get didn't fail on an unknown codec")
+	}
+	if _, err := ByName("huffman"); err == nil {
+		t.Error("ByName didn't fail on an unknown codec")
+	}
+}
+
+func TestZigzag(t *testing.T) {
+	for i, v := range []int{0, -1, 1, -2, 2} {
+		if u := zigzag(v); u != uint64(i) || unzigzag(u) != v {
+			t.Errorf("zigzag(%d) = %d", v, u)
+		}
+	}
+}
+
+func benchmarkEncode(b *testing.B, id ID) {
+	c, _ := Get(id)
+	values := testValues()["walk"]
+	var size uint64
+	for i := 0; i < b.N; i++ {
+		ba := c.Encode(values)
+		size = uint64(len(ba.Words()))
+	}
+	b.ReportMetric(float64(size*64)/float64(len(values)), "bits/value")
+}
+
+func BenchmarkGamma(b *testing.B)     { benchmarkEncode(b, Gamma) }
+func BenchmarkDelta(b *testing.B)     { benchmarkEncode(b, Delta) }
+func BenchmarkVarint(b *testing.B)    { benchmarkEncode(b, Varint) }
+func BenchmarkRice(b *testing.B)      { benchmarkEncode(b, Rice) }
+func BenchmarkEliasFano(b *testing.B) { benchmarkEncode(b, EliasFano) }
+
+func TestPrediction(t *testing.T) {
+	// a grenade falling: constant velocity in x, accelerating in z
+	x := []int{100, 110, 120, 130, 140, 150}
+	z := []int{64, 80, 90, 94, 92, 84}
+	if res := Residuals(x, 2); !reflect.DeepEqual(res, []int{100, 10, 0, 0, 0, 0}) {
+		t.Error("Residuals of order 2 failed, got ", res)
+	}
+	if res := Residuals(z, 1); !reflect.DeepEqual(res, []int{64, 16, 10, 4, -2, -8}) {
+		t.Error("Residuals of order 1 failed, got ", res)
+	}
+	for order := 0; order <= MaxOrder; order++ {
+		for _, values := range [][]int{x, z, {}, {-5}} {
+			if res := Restore(Residuals(values, order), order); !reflect.DeepEqual(res, values) {
+				t.Errorf("order %d failed, got %v instead of %v", order, res, values)
+			}
+		}
+	}
+	if CheckOrder(MaxOrder+1) == nil || CheckOrder(-1) == nil {
+		t.Error("CheckOrder accepted an unsupported order")
+	}
+}
+
+func TestAngles(t *testing.T) {
+	yaw := []int{350, 355, 359, 2, 10, 5, 358, 180, 0}
+	unwrapped := UnwrapAngles(yaw, 360)
+	if expected := []int{350, 355, 359, 362, 370, 365, 358, 180, 0}; !reflect.DeepEqual(unwrapped, expected) {
+		t.Error("UnwrapAngles failed, got ", unwrapped, " instead of ", expected)
+	}
+	if res := WrapAngles(unwrapped, 360); !reflect.DeepEqual(res, yaw) {
+		t.Error("WrapAngles failed on unwrapped angles, got ", res, " instead of ", yaw)
+	}
+
+	pitch := []int{0, 89, 271, 359}
+	signed := SignedAngles(pitch, 360)
+	if expected := []int{0, 89, -89, -1}; !reflect.DeepEqual(signed, expected) {
+		t.Error("SignedAngles failed, got ", signed, " instead of ", expected)
+	}
+	if res := WrapAngles(signed, 360); !reflect.DeepEqual(res, pitch) {
+		t.Error("WrapAngles failed on signed angles, got ", res, " instead of ", pitch)
+	}
+}
+
+func TestKeyframed(t *testing.T) {
+	for _, name := range Names() {
+		c, _ := ByName(name)
+		for order := 0; order <= MaxOrder; order++ {
+			for _, interval := range []int{1, 3, 64, 1000} {
+				k := Keyframed{c, order, interval}
+				for valuesName, values := range testValues() {
+					ba, keyframes := k.Encode(values)
+					res, err := k.Decode(ba, keyframes, len(values))
+					if err != nil || !reflect.DeepEqual(res, values) && !(len(res) == 0 && len(values) == 0) {
+						t.Errorf("%s of order %d every %d failed on %s values, got %v %v", name, order, interval, valuesName, res, err)
+						continue
+					}
+					for i, v := range values {
+						if res, err := k.DecodeAt(ba, keyframes, len(values), i); err != nil || res != v {
+							t.Errorf("%s of order %d every %d failed on %s value %d, got %d %v instead of %d", name, order, interval, valuesName, i, res, err, v)
+						}
+						for _, to := range []int{i + 1, i + 2, i + 2*interval + 1} {
+							if to > len(values) {
+								continue
+							}
+							if res, err := k.DecodeRange(ba, keyframes, len(values), i, to); err != nil || !reflect.DeepEqual(res, values[i:to]) {
+								t.Errorf("%s of order %d every %d failed on %s values %d to %d, got %v %v", name, order, interval, valuesName, i, to, res, err)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	k := Keyframed{gamma{}, 1, 4}
+	values := []int{1, 2, 3, 4, 5, 6}
+	ba, keyframes := k.Encode(values)
+	if expected := []int{1, 5}; len(keyframes) != 2 || keyframes[0].Value != 1 || keyframes[1].Value != 5 {
+		t.Error("Encode failed, got keyframes ", keyframes, " instead of values ", expected)
+	}
+	if _, err := k.Decode(ba, keyframes[:1], len(values)); err == nil {
+		t.Error("Decode didn't fail on missing keyframes")
+	}
+	if _, err := k.DecodeAt(ba, keyframes, len(values), len(values)); err == nil {
+		t.Error("DecodeAt didn't fail past the end")
+	}
+	if _, err := k.DecodeRange(ba, keyframes, len(values), 3, len(values)+1); err == nil {
+		t.Error("DecodeRange didn't fail past the end")
+	}
+	keyframes[1].Offset = ba.Len() + 1
+	if _, err := k.DecodeAt(ba, keyframes, len(values), 5); err == nil {
+		t.Error("DecodeAt didn't fail on a keyframe past the end")
+	}
+	if CheckInterval(-1) == nil || CheckInterval(0) != nil {
+		t.Error("CheckInterval failed")
+	}
+}
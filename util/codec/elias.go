@@ -0,0 +1,39 @@
+package codec
+
+import "csgo-parser-mongodb/util/elias"
+
+type gamma struct{}
+
+func (gamma) ID() ID {
+	return Gamma
+}
+
+func (gamma) Name() string {
+	return "gamma"
+}
+
+func (gamma) Encode(values []int) elias.BitArrayWithLength {
+	return elias.EliasGammaNegative(values...)
+}
+
+func (gamma) Decode(ba elias.BitArrayWithLength, n int) ([]int, error) {
+	return truncate(elias.EliasGammaDecode(ba, true), n), nil
+}
+
+type delta struct{}
+
+func (delta) ID() ID {
+	return Delta
+}
+
+func (delta) Name() string {
+	return "delta"
+}
+
+func (delta) Encode(values []int) elias.BitArrayWithLength {
+	return elias.EliasDeltaNegative(values...)
+}
+
+func (delta) Decode(ba elias.BitArrayWithLength, n int) ([]int, error) {
+	return truncate(elias.EliasDeltaDecode(ba, true), n), nil
+}
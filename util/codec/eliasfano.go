@@ -0,0 +1,79 @@
+package codec
+
+import (
+	"csgo-parser-mongodb/util/bitio"
+	"csgo-parser-mongodb/util/elias"
+	"math/bits"
+)
+
+// eliasFano codes the running sums of the zigzag mapped values, which never decrease.
+// Every sum is split into its low bits, stored as they are, and its high bits,
+// stored as the unary coded gaps between them. The stream starts with the number of low bits in 6 bits.
+type eliasFano struct{}
+
+func (eliasFano) ID() ID {
+	return EliasFano
+}
+
+func (eliasFano) Name() string {
+	return "eliasfano"
+}
+
+func (eliasFano) Encode(values []int) elias.BitArrayWithLength {
+	w := bitio.NewBitWriter(uint64(len(values)) * 4)
+	if len(values) == 0 {
+		return elias.FromBitWriter(w)
+	}
+
+	sums := make([]uint64, len(values))
+	var sum uint64
+	for i, v := range values {
+		sum += zigzag(v)
+		sums[i] = sum
+	}
+
+	var low uint
+	if ratio := (sum + 1) / uint64(len(values)); ratio > 0 {
+		low = uint(bits.Len64(ratio) - 1)
+	}
+	w.WriteBits(uint64(low), 6)
+	for _, s := range sums {
+		w.WriteBits(s, low)
+	}
+	var high uint64
+	for _, s := range sums {
+		w.WriteZeros(s>>low - high)
+		w.WriteBit(true)
+		high = s >> low
+	}
+	return elias.FromBitWriter(w)
+}
+
+func (c eliasFano) Decode(ba elias.BitArrayWithLength, n int) ([]int, error) {
+	if err := checkCount(c, n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return []int{}, nil
+	}
+	r := bitio.NewBitReader(ba.Words())
+	low := uint(r.ReadBits(6))
+	sums := make([]uint64, n)
+	for i := range sums {
+		sums[i] = r.ReadBits(low)
+	}
+	var high uint64
+	for i := range sums {
+		gap, _ := r.ReadUnary()
+		high += gap
+		sums[i] |= high << low
+	}
+
+	values := make([]int, n)
+	var prev uint64
+	for i, s := range sums {
+		values[i] = unzigzag(s - prev)
+		prev = s
+	}
+	return values, nil
+}
@@ -0,0 +1,157 @@
+package codec
+
+import (
+	"csgo-parser-mongodb/util/bitio"
+	"csgo-parser-mongodb/util/elias"
+	"fmt"
+)
+
+// Keyframe lets a stream be decoded from the middle. Keyframed streams are split into segments
+// of Interval samples, each encoded on its own: Value is the absolute value of the first sample
+// of a segment and Offset the bit the codes of the rest of it start at.
+type Keyframe struct {
+	Offset uint64 `bson:"Offset"`
+	Value  int    `bson:"Value"`
+}
+
+// CheckInterval returns an error if streams can't be keyframed every interval samples.
+// An interval of 0 stores no keyframes.
+func CheckInterval(interval int) error {
+	if interval < 0 {
+		return fmt.Errorf("invalid keyframe interval %d, must not be negative", interval)
+	}
+	return nil
+}
+
+// Keyframed encodes streams with a codec and a prediction order in segments of Interval samples,
+// so any sample can be decoded without decoding more than the segment it is in.
+type Keyframed struct {
+	Codec    Codec
+	Order    int
+	Interval int
+}
+
+// Encode returns the codes of all segments one after another and the keyframe of every segment.
+// Samples are predicted from the first one of their segment on, which is stored in its keyframe,
+// so a segment of one sample takes no bits.
+func (k Keyframed) Encode(values []int) (elias.BitArrayWithLength, []Keyframe) {
+	w := bitio.NewBitWriter(uint64(len(values)) * 4)
+	keyframes := make([]Keyframe, 0, (len(values)+k.Interval-1)/k.Interval)
+	for start := 0; start < len(values); start += k.Interval {
+		end := start + k.Interval
+		if end > len(values) {
+			end = len(values)
+		}
+		first := values[start]
+		relative := make([]int, end-start)
+		for i, v := range values[start:end] {
+			relative[i] = v - first
+		}
+		// the first residual is always 0
+		codes := k.Codec.Encode(Residuals(relative, k.Order)[1:])
+		keyframes = append(keyframes, Keyframe{w.Len(), first})
+		w.WriteStream(codes.Words(), 0, codes.Len())
+	}
+	return elias.FromBitWriter(w), keyframes
+}
+
+// Decode restores the n values Encode encoded.
+func (k Keyframed) Decode(ba elias.BitArrayWithLength, keyframes []Keyframe, n int) ([]int, error) {
+	if err := k.check(keyframes, n); err != nil {
+		return nil, err
+	}
+	values := make([]int, 0, n)
+	for segment := range keyframes {
+		decoded, err := k.decodeSegment(ba, keyframes, n, segment)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, decoded...)
+	}
+	return values, nil
+}
+
+// DecodeAt restores value i of the n values Encode encoded, decoding only the segment it is in.
+func (k Keyframed) DecodeAt(ba elias.BitArrayWithLength, keyframes []Keyframe, n, i int) (int, error) {
+	if err := k.check(keyframes, n); err != nil {
+		return 0, err
+	}
+	if i < 0 || i >= n {
+		return 0, fmt.Errorf("sample %d out of range, the stream has %d", i, n)
+	}
+	values, err := k.decodeSegment(ba, keyframes, n, i/k.Interval)
+	if err != nil {
+		return 0, err
+	}
+	return values[i%k.Interval], nil
+}
+
+// DecodeRange restores values from to to-1 of the n values Encode encoded, decoding each segment
+// they are in once. The first sample of a segment is its keyframe's value and needs no decoding.
+func (k Keyframed) DecodeRange(ba elias.BitArrayWithLength, keyframes []Keyframe, n, from, to int) ([]int, error) {
+	if err := k.check(keyframes, n); err != nil {
+		return nil, err
+	}
+	if from < 0 || from > to || to > n {
+		return nil, fmt.Errorf("samples %d to %d out of range, the stream has %d", from, to, n)
+	}
+	values := make([]int, 0, to-from)
+	for i := from; i < to; {
+		segment, start := i/k.Interval, i%k.Interval
+		end := to - segment*k.Interval
+		if end > k.Interval {
+			end = k.Interval
+		}
+		if start == 0 && end == 1 {
+			values = append(values, keyframes[segment].Value)
+			i++
+			continue
+		}
+		decoded, err := k.decodeSegment(ba, keyframes, n, segment)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, decoded[start:end]...)
+		i += end - start
+	}
+	return values, nil
+}
+
+func (k Keyframed) check(keyframes []Keyframe, n int) error {
+	if k.Interval <= 0 {
+		return fmt.Errorf("invalid keyframe interval %d", k.Interval)
+	}
+	if n < 0 || len(keyframes) != (n+k.Interval-1)/k.Interval {
+		return fmt.Errorf("%d keyframes don't fit %d samples with an interval of %d", len(keyframes), n, k.Interval)
+	}
+	return nil
+}
+
+func (k Keyframed) decodeSegment(ba elias.BitArrayWithLength, keyframes []Keyframe, n, segment int) ([]int, error) {
+	from, to := keyframes[segment].Offset, ba.Len()
+	if segment+1 < len(keyframes) {
+		to = keyframes[segment+1].Offset
+	}
+	if from > to || to > ba.Len() {
+		return nil, fmt.Errorf("keyframe %d points to bits %d to %d of a stream of %d", segment, from, to, ba.Len())
+	}
+	count := n - segment*k.Interval
+	if count > k.Interval {
+		count = k.Interval
+	}
+
+	w := bitio.NewBitWriter(to - from)
+	w.WriteStream(ba.Words(), from, to-from)
+	residuals, err := k.Codec.Decode(elias.FromBitWriter(w), count-1)
+	if err != nil {
+		return nil, err
+	}
+	if len(residuals) != count-1 {
+		return nil, fmt.Errorf("segment %d has %d samples, expected %d", segment, len(residuals)+1, count)
+	}
+	values := Restore(append([]int{0}, residuals...), k.Order)
+	for i := range values {
+		values[i] += keyframes[segment].Value
+	}
+	return values, nil
+}
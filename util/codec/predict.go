@@ -0,0 +1,50 @@
+package codec
+
+import "fmt"
+
+// MaxOrder is the highest prediction order supported.
+//   - order 0 stores the values themselves,
+//   - order 1 predicts a value to be the previous one and stores the deltas,
+//   - order 2 predicts a value from the previous one and the last velocity and stores the deltas of the deltas.
+//
+// Smooth movement and ballistic trajectories leave much smaller residuals with order 2.
+const MaxOrder = 2
+
+// CheckOrder returns an error if the prediction order isn't supported.
+func CheckOrder(order int) error {
+	if order < 0 || order > MaxOrder {
+		return fmt.Errorf("unsupported prediction order %d, must be between 0 and %d", order, MaxOrder)
+	}
+	return nil
+}
+
+// predict returns the value x[i] is predicted to be from the ones before it.
+// The first values have not enough history and are predicted with a lower order.
+func predict(x []int, i, order int) int {
+	switch {
+	case order == 0 || i == 0:
+		return 0
+	case order == 1 || i == 1:
+		return x[i-1]
+	default:
+		return 2*x[i-1] - x[i-2]
+	}
+}
+
+// Residuals returns how far the values are from their prediction of the given order.
+func Residuals(values []int, order int) []int {
+	residuals := make([]int, len(values))
+	for i, v := range values {
+		residuals[i] = v - predict(values, i, order)
+	}
+	return residuals
+}
+
+// Restore returns the values Residuals was given.
+func Restore(residuals []int, order int) []int {
+	values := make([]int, len(residuals))
+	for i, r := range residuals {
+		values[i] = r + predict(values, i, order)
+	}
+	return values
+}
@@ -0,0 +1,97 @@
+package codec
+
+import (
+	"csgo-parser-mongodb/util/bitio"
+	"csgo-parser-mongodb/util/elias"
+	"math/bits"
+)
+
+const (
+	// riceLimit is the longest quotient written in unary, larger values are escaped into a gamma code
+	riceLimit = 24
+	// the statistics are halved every riceReset values, so the parameter follows the recent values
+	riceReset = 64
+)
+
+// rice is a Golomb-Rice code whose parameter adapts to the magnitude of the values seen so far,
+// the way LOCO-I picks it. The decoder keeps the same statistics, so the parameter isn't stored.
+type rice struct{}
+
+func (rice) ID() ID {
+	return Rice
+}
+
+func (rice) Name() string {
+	return "rice"
+}
+
+type riceState struct {
+	sum, count uint64
+}
+
+func newRiceState() riceState {
+	return riceState{4, 1}
+}
+
+func (s riceState) parameter() uint {
+	k := uint(0)
+	for s.count<<k < s.sum && k < 32 {
+		k++
+	}
+	return k
+}
+
+func (s *riceState) update(u uint64) {
+	s.sum += u
+	s.count++
+	if s.count >= riceReset {
+		s.sum >>= 1
+		s.count >>= 1
+	}
+}
+
+func (rice) Encode(values []int) elias.BitArrayWithLength {
+	w := bitio.NewBitWriter(uint64(len(values)) * 4)
+	s := newRiceState()
+	for _, v := range values {
+		u := zigzag(v)
+		k := s.parameter()
+		if q := u >> k; q < riceLimit {
+			w.WriteZeros(q)
+			w.WriteBit(true)
+			w.WriteBits(u, k)
+		} else {
+			// the gamma code starts with zeros as well, the decoder reads them together with the limit
+			w.WriteZeros(riceLimit)
+			n := uint(bits.Len64(u+1) - 1)
+			w.WriteZeros(uint64(n))
+			w.WriteBits(u+1, n+1)
+		}
+		s.update(u)
+	}
+	return elias.FromBitWriter(w)
+}
+
+// Decode needs n, the zeros padding the last word would read as the start of another value.
+func (c rice) Decode(ba elias.BitArrayWithLength, n int) ([]int, error) {
+	if err := checkCount(c, n); err != nil {
+		return nil, err
+	}
+	r := bitio.NewBitReader(ba.Words())
+	values := make([]int, 0, n)
+	s := newRiceState()
+	for i := 0; i < n; i++ {
+		var u uint64
+		k := s.parameter()
+		q, _ := r.ReadUnary()
+		if q < riceLimit {
+			u = q<<k | r.ReadBits(k)
+		} else {
+			width := uint(q - riceLimit)
+			u = (1<<width | r.ReadBits(width)) - 1
+		}
+		values = append(values, unzigzag(u))
+		s.update(u)
+	}
+	return values, nil
+}
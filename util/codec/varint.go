@@ -0,0 +1,52 @@
+package codec
+
+import (
+	"csgo-parser-mongodb/util/bitio"
+	"csgo-parser-mongodb/util/elias"
+)
+
+// varint stores zigzag mapped values in groups of 7 bits, least significant first,
+// each preceded by a bit telling whether another group follows.
+type varint struct{}
+
+func (varint) ID() ID {
+	return Varint
+}
+
+func (varint) Name() string {
+	return "varint"
+}
+
+func (varint) Encode(values []int) elias.BitArrayWithLength {
+	w := bitio.NewBitWriter(uint64(len(values)) * 8)
+	for _, v := range values {
+		u := zigzag(v)
+		for u >= 0x80 {
+			w.WriteBits(0x80|u&0x7f, 8)
+			u >>= 7
+		}
+		w.WriteBits(u, 8)
+	}
+	return elias.FromBitWriter(w)
+}
+
+// Decode needs n, the last group of a stream may well be all zeros.
+func (c varint) Decode(ba elias.BitArrayWithLength, n int) ([]int, error) {
+	if err := checkCount(c, n); err != nil {
+		return nil, err
+	}
+	r := bitio.NewBitReader(ba.Words())
+	values := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		var u uint64
+		for shift := uint(0); ; shift += 7 {
+			group := r.ReadBits(8)
+			u |= (group & 0x7f) << shift
+			if group&0x80 == 0 {
+				break
+			}
+		}
+		values = append(values, unzigzag(u))
+	}
+	return values, nil
+}
@@ -0,0 +1,64 @@
+package elias
+
+import (
+	"csgo-parser-mongodb/util/bitarray"
+	"encoding/binary"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// BinarySubtype is the user defined BSON binary subtype bit arrays are stored as.
+// The binary holds the bit length as a little endian uint64, followed by the bits,
+// bit k being bit k%8 of byte k/8.
+const BinarySubtype = 0x80
+
+// MarshalBSONValue stores the bit array as a single binary instead of a document with an array of blocks.
+func (ba BitArrayWithLength) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	length := ba.codesEnd()
+	words := ba.Words()
+	data := make([]byte, 8+(length+7)/8)
+	binary.LittleEndian.PutUint64(data, length)
+	for i := uint64(0); i < (length+7)/8 && i/8 < uint64(len(words)); i++ {
+		data[8+i] = byte(words[i/8] >> (8 * (i % 8)))
+	}
+	return bsontype.Binary, bsoncore.AppendBinary(nil, BinarySubtype, data), nil
+}
+
+// UnmarshalBSONValue reads bit arrays stored as binaries as well as the documents they used to be stored as,
+// which have no length.
+func (ba *BitArrayWithLength) UnmarshalBSONValue(t bsontype.Type, value []byte) error {
+	switch t {
+	case bsontype.Binary:
+		subtype, data, _, ok := bsoncore.ReadBinary(value)
+		if !ok {
+			return fmt.Errorf("malformed bit array binary")
+		}
+		if subtype != BinarySubtype || len(data) < 8 {
+			return fmt.Errorf("binary of subtype %#x and length %d is no bit array", subtype, len(data))
+		}
+		length := binary.LittleEndian.Uint64(data)
+		data = data[8:]
+		if uint64(len(data)) < (length+7)/8 {
+			return fmt.Errorf("bit array of length %d has only %d bytes", length, len(data))
+		}
+		words := make([]uint64, (len(data)+7)/8)
+		for i, b := range data {
+			words[i/8] |= uint64(b) << (8 * uint(i%8))
+		}
+		*ba = BitArrayWithLength{length, bitarray.NewBitArrayFromWords(words)}
+		return nil
+	case bsontype.EmbeddedDocument:
+		var legacy struct {
+			BitArray bitarray.BitArray `bson:"bitarray"`
+		}
+		if err := bson.Unmarshal(value, &legacy); err != nil {
+			return err
+		}
+		*ba = BitArrayWithLength{0, legacy.BitArray}
+		return nil
+	default:
+		return fmt.Errorf("cannot read a bit array from BSON %v", t)
+	}
+}
@@ -0,0 +1,77 @@
+package elias
+
+import (
+	"csgo-parser-mongodb/util/bitarray"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+type stream struct {
+	X BitArrayWithLength `bson:"X"`
+}
+
+func TestBSONBinary(t *testing.T) {
+	var xTest = []int{3, -15, 123, -31, 0, 42, 0, 4, -1, 1 << 40}
+	data, err := bson.Marshal(stream{EliasDeltaNegative(xTest...)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subtype, _ := bson.Raw(data).Lookup("X").Binary(); subtype != BinarySubtype {
+		t.Errorf("stored as subtype %#x", subtype)
+	}
+
+	var res stream
+	if err := bson.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.X.Len() != EliasDeltaNegative(xTest...).Len() {
+		t.Error("length wasn't stored, got ", res.X.Len())
+	}
+	if xRes := EliasDeltaDecode(res.X, true); !IntArrayEquals(xRes, xTest) {
+		t.Error("EliasDeltaDecode failed after BSON, got ", xRes, " instead of ", xTest)
+	}
+
+	empty, err := bson.Marshal(stream{EliasGammaNegative()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bson.Unmarshal(empty, &res); err != nil || len(EliasGammaDecode(res.X, true)) != 0 {
+		t.Error("empty bit array failed after BSON: ", err)
+	}
+}
+
+func TestBSONLegacyDocument(t *testing.T) {
+	// bit arrays used to be stored as the document the driver made of the struct
+	var xTest = []int{3, -15, 123, -31, 0, 42, 0, 4}
+	ba := EliasGammaNegative(xTest...)
+	legacy := struct {
+		X struct {
+			BitArray bitarray.BitArray `bson:"bitarray"`
+		} `bson:"X"`
+	}{}
+	legacy.X.BitArray = ba.BitArray
+	data, err := bson.Marshal(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res stream
+	if err := bson.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if xRes := EliasGammaDecode(res.X, true); !IntArrayEquals(xRes, xTest) {
+		t.Error("EliasGammaDecode failed on the legacy layout, got ", xRes, " instead of ", xTest)
+	}
+
+	// and are rewritten as binaries without losing codes
+	data, err = bson.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bson.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+	if xRes := EliasGammaDecode(res.X, true); !IntArrayEquals(xRes, xTest) {
+		t.Error("EliasGammaDecode failed on the rewritten legacy layout, got ", xRes, " instead of ", xTest)
+	}
+}
@@ -1,12 +1,10 @@
 package elias
 
 import (
-	"fmt"
 	//"github.com/golang-collections/go-datastructures/bitarray"
 	"csgo-parser-mongodb/util/bitarray"
+	"csgo-parser-mongodb/util/bitio"
 	"math/bits"
-	"strconv"
-	"strings"
 )
 
 type BitArrayWithLength struct {
@@ -14,195 +12,135 @@ type BitArrayWithLength struct {
 	bitarray.BitArray
 }
 
-//func (ba BitArrayWithLength) GetBSON() (interface{}, error) {
-//	return struct {
-//		Length		uint64
-//		BlocksArray []int64
-//		Lowest      uint64
-//		Highest     uint64
-//		Anyset      bool
-//	}{
-//		Length:			ba.length,
-//		BlocksArray:	ba.BlocksArray,
-//		Lowest:			ba.Lowest,
-//		Highest:		ba.Highest,
-//		Anyset:			ba.Anyset,
-//	}, nil
-//}
-
-func stringToBitArray(bs string) BitArrayWithLength {
-	result := BitArrayWithLength {
-		uint64(len(bs)),
-		bitarray.NewDenseBitArray(uint64(len(bs))),
+// Len returns the number of bits the codes take. It is 0 for arrays read back from the documents
+// bit arrays were stored as before they were stored as binaries, which have no length.
+func (ba BitArrayWithLength) Len() uint64 {
+	return ba.length
+}
+
+// IsZero reports whether the array holds no codes, so optional streams can be left out of documents.
+func (ba BitArrayWithLength) IsZero() bool {
+	return ba.codesEnd() == 0
+}
+
+// codesEnd returns the position after which no code starts. Binaries store the length,
+// but arrays read back from the legacy document layout have none, so their end
+// is restored from the highest set bit: every code has a 1 in its prefix.
+func (ba BitArrayWithLength) codesEnd() uint64 {
+	if ba.length > 0 {
+		return ba.length
 	}
-	for i, c := range bs {
-		if c == '1' {
-			err := result.SetBit(uint64(i))
-			checkError(err)
-		}
+	if ba.Anyset {
+		return ba.Highest + 1
 	}
-	return result
+	return 0
 }
 
-func binary(x, l uint) string {
-	return fmt.Sprintf("%0"+fmt.Sprint(l)+"s", strconv.FormatInt(int64(x), 2))
+// FromBitWriter returns the stream written by w as a bit array.
+func FromBitWriter(w *bitio.BitWriter) BitArrayWithLength {
+	return BitArrayWithLength{w.Len(), bitarray.NewBitArrayFromWords(w.Words())}
 }
 
-func unary(x uint) string {
-	return strings.Repeat("0", int(x-1)) + "1"
+// writeGamma appends the Elias gamma code of a > 0: as many zeros as a has bits after
+// its leading one, then a itself.
+func writeGamma(w *bitio.BitWriter, a uint64) {
+	n := uint(bits.Len64(a) - 1)
+	w.WriteZeros(uint64(n))
+	w.WriteBits(a, n+1)
 }
 
-func eliasGeneric(lencoding func(uint) string, a uint) string {
-	if a == 1 {
-		return "1"
-	}
-	n := uint(bits.Len(a) - 1)
-	a1 := a - (1 << n)
-	return lencoding(n+1) + binary(a1, n)
+// writeDelta appends the Elias delta code of a > 0: the gamma code of its length,
+// then a without its leading one.
+func writeDelta(w *bitio.BitWriter, a uint64) {
+	n := uint(bits.Len64(a) - 1)
+	writeGamma(w, uint64(n+1))
+	w.WriteBits(a, n)
 }
 
-func eliasGammaS(x uint) string {
-	return eliasGeneric(unary, x)
+// zigzag maps integers to positive numbers, negative ones to even and the others to odd numbers.
+func zigzag(v int) uint64 {
+	if v < 0 {
+		return uint64(-v * 2)
+	}
+	return uint64(v*2 + 1)
 }
 
-func eliasDeltaS(x uint) string {
-	return eliasGeneric(eliasGammaS, x)
+func unzigzag(a uint64) int {
+	if a%2 == 0 {
+		return -int(a / 2)
+	}
+	return int((a - 1) / 2)
 }
 
 func EliasGamma(x ...uint) BitArrayWithLength {
-	result := ""
+	w := bitio.NewBitWriter(uint64(len(x)) * 8)
 	for _, v := range x {
-		v++
-		result += eliasGammaS(v)
+		writeGamma(w, uint64(v)+1)
 	}
-	return stringToBitArray(result)
+	return FromBitWriter(w)
 }
 
 func EliasDelta(x ...uint) BitArrayWithLength {
-	result := ""
+	w := bitio.NewBitWriter(uint64(len(x)) * 8)
 	for _, v := range x {
-		v++
-		result += eliasDeltaS(v)
+		writeDelta(w, uint64(v)+1)
 	}
-	return stringToBitArray(result)
+	return FromBitWriter(w)
 }
 
 func EliasGammaNegative(x ...int) BitArrayWithLength {
-	result := ""
+	w := bitio.NewBitWriter(uint64(len(x)) * 8)
 	for _, v := range x {
-		if v < 0 {
-			result += eliasGammaS(uint(-v * 2))
-		} else {
-			result += eliasGammaS(uint(v*2 + 1))
-		}
+		writeGamma(w, zigzag(v))
 	}
-	return stringToBitArray(result)
+	return FromBitWriter(w)
 }
 
 func EliasDeltaNegative(x ...int) BitArrayWithLength {
-	result := ""
+	w := bitio.NewBitWriter(uint64(len(x)) * 8)
 	for _, v := range x {
-		if v < 0 {
-			result += eliasDeltaS(uint(-v * 2))
-		} else {
-			result += eliasDeltaS(uint(v*2 + 1))
-		}
+		writeDelta(w, zigzag(v))
 	}
-	return stringToBitArray(result)
+	return FromBitWriter(w)
 }
 
-func EliasGammaDecode(ba BitArrayWithLength, possibleNegative bool) []int {
+func decodeNumber(a uint64, possibleNegative bool) int {
+	if possibleNegative {
+		return unzigzag(a)
+	}
+	return int(a - 1)
+}
 
+func EliasGammaDecode(ba BitArrayWithLength, possibleNegative bool) []int {
 	var numbers []int
-	interpretAsBinary := false
-	var k, j uint64 = 0, 0
-
-	for j < ba.length {
-		if interpretAsBinary == false {
-			value, err := ba.GetBit(j)
-			checkError(err)
-			j++
-			if value == false {
-				k++
-			} else {
-				interpretAsBinary = true
-			}
-		}
-		if interpretAsBinary {
-			a := 1 << k
-			for	i := k; i > 0; i-- {
-				value, err := ba.GetBit(j)
-				checkError(err)
-				j++
-				if value {
-					a += 1 << (i - 1)
-				}
-			}
-			if !possibleNegative {
-				numbers = append(numbers, a-1)
-			} else {
-				if a % 2 == 0 {
-					numbers = append(numbers, -a/2)
-				} else {
-					numbers = append(numbers, (a-1)/2)
-				}
-			}
-			interpretAsBinary = false
-			k = 0
+	r := bitio.NewBitReader(ba.Words())
+	end := ba.codesEnd()
+
+	for r.Pos() < end {
+		k, ok := r.ReadUnary()
+		if !ok || r.Pos() > end {
+			break
 		}
+		a := 1<<k | r.ReadBits(uint(k))
+		numbers = append(numbers, decodeNumber(a, possibleNegative))
 	}
 
 	return numbers
 }
 
 func EliasDeltaDecode(ba BitArrayWithLength, possibleNegative bool) []int {
-
 	var numbers []int
-	interpretAsBinary := false
-	var k, j uint64 = 0, 0
-
-	for j < ba.length {
-		if interpretAsBinary == false {
-			value, err := ba.GetBit(j)
-			checkError(err)
-			j++
-			if value == false {
-				k++
-			} else {
-				interpretAsBinary = true
-			}
-		}
-		if interpretAsBinary {
-			a := uint(1 << k)
-			for	i := k; i > 0; i-- {
-				value, err := ba.GetBit(j)
-				checkError(err)
-				j++
-				if value {
-					a += 1 << (i - 1)
-				}
-			}
-			b := 1 << (a-1)
-			for i := a-1; i > 0; i-- {
-				value, err := ba.GetBit(j)
-				checkError(err)
-				j++
-				if value {
-					b += 1 << (i - 1)
-				}
-			}
-			if !possibleNegative {
-				numbers = append(numbers, b-1)
-			} else {
-				if b % 2 == 0 {
-					numbers = append(numbers, -b/2)
-				} else {
-					numbers = append(numbers, (b-1)/2)
-				}
-			}
-			interpretAsBinary = false
-			k = 0
+	r := bitio.NewBitReader(ba.Words())
+	end := ba.codesEnd()
+
+	for r.Pos() < end {
+		k, ok := r.ReadUnary()
+		if !ok || r.Pos() > end {
+			break
 		}
+		n := 1<<k | r.ReadBits(uint(k))
+		b := 1<<(n-1) | r.ReadBits(uint(n-1))
+		numbers = append(numbers, decodeNumber(b, possibleNegative))
 	}
 
 	return numbers
@@ -220,8 +158,15 @@ func ArrayToDeltas(x []int) []int {
 	return xDeltas
 }
 
-func checkError(err error) {
-	if err != nil {
-		panic(err)
+// DeltasToArray restores the values ArrayToDeltas was given.
+func DeltasToArray(xDeltas []int) []int {
+	x := make([]int, len(xDeltas))
+	for i, v := range xDeltas {
+		if i == 0 {
+			x[i] = v
+			continue
+		}
+		x[i] = x[i-1] + v
 	}
-}
\ No newline at end of file
+	return x
+}
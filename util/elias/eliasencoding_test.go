@@ -1,6 +1,12 @@
 package elias
 
 import (
+	"csgo-parser-mongodb/util/bitarray"
+	"fmt"
+	"math/bits"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -56,3 +62,188 @@ func TestEliasGamma(t *testing.T) {
 		t.Error("EliasGamma failed with negative values, got ", xResNeg, " instead of ", xTestNeg)
 	}
 }
+
+func TestEliasGammaDecodeWithoutLength(t *testing.T) {
+	// arrays read back from MongoDB lose their length
+	var xTest = []int{3, -15, 123, -31, 0, 42, 0, 4}
+	ba := EliasGammaNegative(xTest...)
+	ba.length = 0
+	xRes := EliasGammaDecode(ba, true)
+	if !IntArrayEquals(xRes, xTest) {
+		t.Error("EliasGamma failed without length, got ", xRes, " instead of ", xTest)
+	}
+	if xDeltas := DeltasToArray(ArrayToDeltas(xTest)); !IntArrayEquals(xDeltas, xTest) {
+		t.Error("DeltasToArray failed, got ", xDeltas, " instead of ", xTest)
+	}
+}
+
+func TestEncodingMatchesLegacy(t *testing.T) {
+	// arrays already stored in MongoDB have to decode the same way
+	var xTest = []int{3, -15, 123, -31, 0, 42, 0, 4, -1, 1 << 40, -(1 << 40)}
+	if res, expected := EliasGammaNegative(xTest...), legacyEliasGammaNegative(xTest...); !reflect.DeepEqual(res, expected) {
+		t.Error("EliasGammaNegative differs from the legacy encoding")
+	}
+	if res, expected := EliasDeltaNegative(xTest...), legacyEliasDeltaNegative(xTest...); !reflect.DeepEqual(res, expected) {
+		t.Error("EliasDeltaNegative differs from the legacy encoding")
+	}
+	legacy := legacyEliasGammaNegative(xTest...)
+	if xRes := EliasGammaDecode(legacy, true); !IntArrayEquals(xRes, xTest) {
+		t.Error("EliasGammaDecode failed on the legacy encoding, got ", xRes, " instead of ", xTest)
+	}
+}
+
+func benchmarkValues() []int {
+	// deltas of a player walking around
+	x := make([]int, 4096)
+	for i := range x {
+		x[i] = (i*7919)%61 - 30
+	}
+	return x
+}
+
+func BenchmarkEliasGammaNegative(b *testing.B) {
+	x := benchmarkValues()
+	for i := 0; i < b.N; i++ {
+		EliasGammaNegative(x...)
+	}
+}
+
+func BenchmarkLegacyEliasGammaNegative(b *testing.B) {
+	x := benchmarkValues()
+	for i := 0; i < b.N; i++ {
+		legacyEliasGammaNegative(x...)
+	}
+}
+
+func BenchmarkEliasDeltaNegative(b *testing.B) {
+	x := benchmarkValues()
+	for i := 0; i < b.N; i++ {
+		EliasDeltaNegative(x...)
+	}
+}
+
+func BenchmarkLegacyEliasDeltaNegative(b *testing.B) {
+	x := benchmarkValues()
+	for i := 0; i < b.N; i++ {
+		legacyEliasDeltaNegative(x...)
+	}
+}
+
+func BenchmarkEliasGammaDecode(b *testing.B) {
+	ba := EliasGammaNegative(benchmarkValues()...)
+	for i := 0; i < b.N; i++ {
+		EliasGammaDecode(ba, true)
+	}
+}
+
+func BenchmarkLegacyEliasGammaDecode(b *testing.B) {
+	ba := EliasGammaNegative(benchmarkValues()...)
+	for i := 0; i < b.N; i++ {
+		legacyEliasGammaDecode(ba, true)
+	}
+}
+
+// The string based implementation the encoders were built on before, kept to compare against.
+
+func legacyStringToBitArray(bs string) BitArrayWithLength {
+	result := BitArrayWithLength{
+		uint64(len(bs)),
+		bitarray.NewDenseBitArray(uint64(len(bs))),
+	}
+	for i, c := range bs {
+		if c == '1' {
+			if err := result.SetBit(uint64(i)); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return result
+}
+
+func legacyBinary(x, l uint) string {
+	return fmt.Sprintf("%0"+fmt.Sprint(l)+"s", strconv.FormatInt(int64(x), 2))
+}
+
+func legacyUnary(x uint) string {
+	return strings.Repeat("0", int(x-1)) + "1"
+}
+
+func legacyEliasGeneric(lencoding func(uint) string, a uint) string {
+	if a == 1 {
+		return "1"
+	}
+	n := uint(bits.Len(a) - 1)
+	a1 := a - (1 << n)
+	return lencoding(n+1) + legacyBinary(a1, n)
+}
+
+func legacyEliasGammaS(x uint) string {
+	return legacyEliasGeneric(legacyUnary, x)
+}
+
+func legacyEliasDeltaS(x uint) string {
+	return legacyEliasGeneric(legacyEliasGammaS, x)
+}
+
+func legacyEliasGammaNegative(x ...int) BitArrayWithLength {
+	result := ""
+	for _, v := range x {
+		if v < 0 {
+			result += legacyEliasGammaS(uint(-v * 2))
+		} else {
+			result += legacyEliasGammaS(uint(v*2 + 1))
+		}
+	}
+	return legacyStringToBitArray(result)
+}
+
+func legacyEliasDeltaNegative(x ...int) BitArrayWithLength {
+	result := ""
+	for _, v := range x {
+		if v < 0 {
+			result += legacyEliasDeltaS(uint(-v * 2))
+		} else {
+			result += legacyEliasDeltaS(uint(v*2 + 1))
+		}
+	}
+	return legacyStringToBitArray(result)
+}
+
+func legacyEliasGammaDecode(ba BitArrayWithLength, possibleNegative bool) []int {
+	var numbers []int
+	var k, j uint64 = 0, 0
+	end := ba.codesEnd()
+
+	for j < end {
+		value, err := ba.GetBit(j)
+		if err != nil {
+			panic(err)
+		}
+		j++
+		if !value {
+			k++
+			continue
+		}
+		a := 1 << k
+		for i := k; i > 0; i-- {
+			value, err := ba.GetBit(j)
+			if err != nil {
+				panic(err)
+			}
+			j++
+			if value {
+				a += 1 << (i - 1)
+			}
+		}
+		if !possibleNegative {
+			numbers = append(numbers, a-1)
+		} else if a%2 == 0 {
+			numbers = append(numbers, -a/2)
+		} else {
+			numbers = append(numbers, (a-1)/2)
+		}
+		k = 0
+	}
+
+	return numbers
+}
@@ -0,0 +1,248 @@
+// Package zones names the places of maps, e.g. "Banana" or "A Site", by the polygons they cover.
+package zones
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Zone is a named place of a map: a polygon in world coordinates, optionally with holes,
+// that spans the altitudes from MinZ up to MaxZ.
+type Zone struct {
+	Name    string
+	Polygon []r2.Point
+	Holes   [][]r2.Point
+	MinZ    float64
+	MaxZ    float64
+}
+
+// Contains reports whether a position is in the zone.
+func (z Zone) Contains(v r3.Vector) bool {
+	if v.Z < z.MinZ || v.Z > z.MaxZ {
+		return false
+	}
+	p := r2.Point{X: v.X, Y: v.Y}
+	if !inPolygon(z.Polygon, p) {
+		return false
+	}
+	for _, hole := range z.Holes {
+		if inPolygon(hole, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// inPolygon tells if a point is in a polygon by counting the edges a ray from the point crosses.
+func inPolygon(polygon []r2.Point, p r2.Point) bool {
+	in := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			in = !in
+		}
+	}
+	return in
+}
+
+// Map is the zones of a map. Zones may overlap, the first one listed that contains a position names it,
+// so smaller places go before the ones they lie in.
+type Map struct {
+	Name  string
+	Zones []Zone
+}
+
+// Callout returns the name of the place a position is in, false if no zone contains it.
+func (m Map) Callout(v r3.Vector) (string, bool) {
+	for _, z := range m.Zones {
+		if z.Contains(v) {
+			return z.Name, true
+		}
+	}
+	return "", false
+}
+
+// Parse reads the zones of a map from a JSON or GeoJSON document.
+//
+// The JSON format lists the zones with their polygons as [x, y] points:
+//
+//	{"map": "de_inferno", "zones": [{"name": "Banana", "polygon": [[x, y], ...], "minZ": 0, "maxZ": 200}]}
+//
+// GeoJSON is a FeatureCollection of Polygon or MultiPolygon features with the name, minZ and maxZ among their
+// properties; the map is named by a "map" member next to the features. minZ and maxZ are optional in both.
+// If the document doesn't name its map, it is given the name passed in, e.g. the name of its file.
+func Parse(r io.Reader, name string) (Map, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Map{}, err
+	}
+	var doc struct {
+		Type     string        `json:"type"`
+		Map      string        `json:"map"`
+		Zones    []jsonZone    `json:"zones"`
+		Features []jsonFeature `json:"features"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return Map{}, err
+	}
+	m := Map{Name: strings.ToLower(doc.Map)}
+	if m.Name == "" {
+		m.Name = strings.ToLower(name)
+	}
+
+	if doc.Type == "FeatureCollection" {
+		for i, f := range doc.Features {
+			zs, err := f.zones()
+			if err != nil {
+				return Map{}, fmt.Errorf("zones of %s: feature %d: %v", m.Name, i, err)
+			}
+			m.Zones = append(m.Zones, zs...)
+		}
+	} else {
+		for i, jz := range doc.Zones {
+			z, err := newZone(jz.Name, jz.MinZ, jz.MaxZ, [][][]float64{jz.Polygon})
+			if err != nil {
+				return Map{}, fmt.Errorf("zones of %s: zone %d: %v", m.Name, i, err)
+			}
+			m.Zones = append(m.Zones, z)
+		}
+	}
+	if len(m.Zones) == 0 {
+		return Map{}, fmt.Errorf("zones of %s: no zones", m.Name)
+	}
+	return m, nil
+}
+
+type jsonZone struct {
+	Name    string      `json:"name"`
+	Polygon [][]float64 `json:"polygon"`
+	MinZ    *float64    `json:"minZ"`
+	MaxZ    *float64    `json:"maxZ"`
+}
+
+type jsonFeature struct {
+	Properties struct {
+		Name string   `json:"name"`
+		MinZ *float64 `json:"minZ"`
+		MaxZ *float64 `json:"maxZ"`
+	} `json:"properties"`
+	Geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+// zones returns a zone for every polygon of the feature, all of the feature's name.
+func (f jsonFeature) zones() ([]Zone, error) {
+	var polygons [][][][]float64
+	switch f.Geometry.Type {
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &rings); err != nil {
+			return nil, err
+		}
+		polygons = [][][][]float64{rings}
+	case "MultiPolygon":
+		if err := json.Unmarshal(f.Geometry.Coordinates, &polygons); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported geometry %q, must be Polygon or MultiPolygon", f.Geometry.Type)
+	}
+	var zs []Zone
+	for _, rings := range polygons {
+		z, err := newZone(f.Properties.Name, f.Properties.MinZ, f.Properties.MaxZ, rings)
+		if err != nil {
+			return nil, err
+		}
+		zs = append(zs, z)
+	}
+	return zs, nil
+}
+
+// newZone makes a zone of the outer ring and holes of a polygon, spanning every altitude unless limited.
+func newZone(name string, minZ, maxZ *float64, rings [][][]float64) (Zone, error) {
+	z := Zone{Name: name, MinZ: math.Inf(-1), MaxZ: math.Inf(1)}
+	if name == "" {
+		return Zone{}, fmt.Errorf("zone without a name")
+	}
+	if minZ != nil {
+		z.MinZ = *minZ
+	}
+	if maxZ != nil {
+		z.MaxZ = *maxZ
+	}
+	if z.MinZ > z.MaxZ {
+		return Zone{}, fmt.Errorf("zone %s has minZ %v above maxZ %v", name, z.MinZ, z.MaxZ)
+	}
+	for i, ring := range rings {
+		var points []r2.Point
+		for _, p := range ring {
+			if len(p) < 2 {
+				return Zone{}, fmt.Errorf("zone %s has a point with %d coordinates", name, len(p))
+			}
+			points = append(points, r2.Point{X: p[0], Y: p[1]})
+		}
+		if len(points) < 3 {
+			return Zone{}, fmt.Errorf("zone %s has a polygon of %d points, needs at least 3", name, len(points))
+		}
+		if i == 0 {
+			z.Polygon = points
+		} else {
+			z.Holes = append(z.Holes, points)
+		}
+	}
+	if z.Polygon == nil {
+		return Zone{}, fmt.Errorf("zone %s has no polygon", name)
+	}
+	return z, nil
+}
+
+// Registry holds the zones of maps by name, e.g. de_inferno, as the header's MapName has it.
+type Registry map[string]Map
+
+// LoadDir reads the zone files, *.json and *.geojson, of a folder. Files that don't name their map
+// are named after their file, e.g. de_inferno.geojson.
+func LoadDir(dir string) (Registry, error) {
+	reg := make(Registry)
+	for _, pattern := range []string{"*.json", "*.geojson"} {
+		paths, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			m, err := parseFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			reg[m.Name] = m
+		}
+	}
+	if len(reg) == 0 {
+		return nil, fmt.Errorf("no zone files in %s", dir)
+	}
+	return reg, nil
+}
+
+func parseFile(path string) (Map, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Map{}, err
+	}
+	defer f.Close()
+	return Parse(f, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+}
+
+// Map returns the zones of a map, its name compared without case.
+func (reg Registry) Map(name string) (Map, bool) {
+	m, ok := reg[strings.ToLower(name)]
+	return m, ok
+}
@@ -0,0 +1,145 @@
+package zones
+
+import (
+	"github.com/golang/geo/r3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const infernoJSON = `{
+	"map": "de_inferno",
+	"zones": [
+		{"name": "Car", "polygon": [[40, 40], [60, 40], [60, 60], [40, 60]]},
+		{"name": "Banana", "polygon": [[0, 0], [100, 0], [100, 100], [0, 100]], "minZ": 0, "maxZ": 200},
+		{"name": "Under Banana", "polygon": [[0, 0], [100, 0], [100, 100], [0, 100]], "maxZ": 0}
+	]
+}`
+
+const nukeGeoJSON = `{
+	"type": "FeatureCollection",
+	"features": [
+		{
+			"type": "Feature",
+			"properties": {"name": "Outside"},
+			"geometry": {"type": "Polygon", "coordinates": [
+				[[0, 0], [100, 0], [100, 100], [0, 100], [0, 0]],
+				[[25, 25], [75, 25], [75, 75], [25, 75], [25, 25]]
+			]}
+		},
+		{
+			"type": "Feature",
+			"properties": {"name": "Silo", "minZ": -100},
+			"geometry": {"type": "MultiPolygon", "coordinates": [
+				[[[200, 0], [300, 0], [250, 100]]],
+				[[[400, 0], [500, 0], [450, 100]]]
+			]}
+		}
+	]
+}`
+
+func TestCallout(t *testing.T) {
+	m, err := Parse(strings.NewReader(infernoJSON), "ignored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "de_inferno" || len(m.Zones) != 3 {
+		t.Error("Parse failed, got ", m)
+	}
+	for _, c := range []struct {
+		position r3.Vector
+		expected string
+	}{
+		// the first zone listed wins
+		{r3.Vector{X: 50, Y: 50, Z: 100}, "Car"},
+		{r3.Vector{X: 10, Y: 90, Z: 100}, "Banana"},
+		{r3.Vector{X: 10, Y: 90, Z: -50}, "Under Banana"},
+		{r3.Vector{X: 10, Y: 90, Z: 300}, ""},
+		{r3.Vector{X: 110, Y: 50, Z: 100}, ""},
+	} {
+		if res, ok := m.Callout(c.position); res != c.expected || ok != (c.expected != "") {
+			t.Error("Callout failed at ", c.position, ", got ", res, ok, " instead of ", c.expected)
+		}
+	}
+
+	m, err = Parse(strings.NewReader(nukeGeoJSON), "de_nuke")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "de_nuke" || len(m.Zones) != 3 {
+		t.Error("Parse failed on GeoJSON, got ", m)
+	}
+	for _, c := range []struct {
+		position r3.Vector
+		expected string
+	}{
+		{r3.Vector{X: 10, Y: 10}, "Outside"},
+		// in the hole
+		{r3.Vector{X: 50, Y: 50}, ""},
+		{r3.Vector{X: 250, Y: 20}, "Silo"},
+		{r3.Vector{X: 450, Y: 20}, "Silo"},
+		{r3.Vector{X: 450, Y: 20, Z: -200}, ""},
+	} {
+		if res, ok := m.Callout(c.position); res != c.expected || ok != (c.expected != "") {
+			t.Error("Callout failed on GeoJSON at ", c.position, ", got ", res, ok, " instead of ", c.expected)
+		}
+	}
+
+	for _, s := range []string{
+		`{"zones": []}`,
+		`{"zones": [{"name": "A", "polygon": [[0, 0], [1, 1]]}]}`,
+		`{"zones": [{"polygon": [[0, 0], [1, 0], [1, 1]]}]}`,
+		`{"zones": [{"name": "A", "polygon": [[0, 0], [1, 0], [1]]}]}`,
+		`{"zones": [{"name": "A", "polygon": [[0, 0], [1, 0], [1, 1]], "minZ": 5, "maxZ": 1}]}`,
+		`{"type": "FeatureCollection", "features": [{"properties": {"name": "A"}, "geometry": {"type": "Point", "coordinates": [0, 0]}}]}`,
+		`not json`,
+	} {
+		if _, err := Parse(strings.NewReader(s), "de_x"); err == nil {
+			t.Error("Parse succeeded on malformed zones ", s)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zones")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"inferno.json":    infernoJSON,
+		"de_nuke.geojson": nukeGeoJSON,
+		"de_dust2.dds":    "",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reg, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reg) != 2 {
+		t.Error("LoadDir failed, got ", reg)
+	}
+	// named by the document, or else by the file
+	if _, ok := reg.Map("De_Inferno"); !ok {
+		t.Error("Map failed on a map named by its document")
+	}
+	if _, ok := reg.Map("de_nuke"); !ok {
+		t.Error("Map failed on a map named by its file")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDir(dir); err == nil {
+		t.Error("LoadDir succeeded with a malformed zone file")
+	}
+	if _, err := LoadDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("LoadDir succeeded on a folder without zones")
+	}
+}